@@ -0,0 +1,199 @@
+package adapter
+
+import (
+	"fmt"
+	"net/url"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+type URLKind int
+
+const (
+	NoMatch URLKind = iota
+	ForumURL
+	ThreadURL
+)
+
+func (k URLKind) String() string {
+	switch k {
+	case ForumURL:
+		return "forum"
+	case ThreadURL:
+		return "thread"
+	}
+	return "unmatched"
+}
+
+// The contents of a single page, as parsed by an adapter without touching
+// the database.
+type Page struct {
+	Threads   []model.Thread
+	Comments  []model.Comment
+	SubForums []*url.URL
+}
+
+// A SiteAdapter knows how to scrape one kind of forum engine. Adapters
+// register themselves from their package's init function, so commands can
+// pick one by URL without knowing which engines exist.
+type SiteAdapter interface {
+	// Short name for the forum engine, e.g. "xenforo".
+	Name() string
+
+	// Human-readable descriptions of the URLs the adapter handles.
+	URLShapes() []string
+
+	// Reports whether the URL is a forum or a thread this adapter handles.
+	Matches(u *url.URL) URLKind
+
+	// Stores threads with activity since cutoff, and their comments, from
+	// the forum at u, optionally descending into sub-forums.
+	ScrapeForum(db *database.ScraperDB, u *url.URL, cutoff time.Time, subforums bool) error
+
+	// Returns comments since cutoff from a thread already in the database.
+	// The caller decides whether to store them.
+	ScrapeThread(db *database.ScraperDB, thread model.Thread, cutoff time.Time) ([]model.Comment, error)
+
+	// Fetches and parses a single page.
+	ParsePage(u *url.URL) (Page, error)
+}
+
+// Implemented by adapters that keep the progress of forum scrapes in the
+// database, so a scrape that was interrupted can be continued.
+type Resumer interface {
+	ResumeCrawl(db *database.ScraperDB, crawl database.Crawl) error
+}
+
+// Implemented by adapters that store a thread's comments themselves, the same
+// way their forum scrapes do, e.g. to notice comments removed from the pages
+// they fetched.
+type ThreadStorer interface {
+	StoreThread(db *database.ScraperDB, thread model.Thread, cutoff time.Time) error
+}
+
+// Scrapes a thread already in the database and stores its comments since
+// cutoff, through the adapter's own store path when it has one.
+func StoreThread(db *database.ScraperDB, a SiteAdapter, thread model.Thread, cutoff time.Time) (err error) {
+	if storer, ok := a.(ThreadStorer); ok {
+		return storer.StoreThread(db, thread, cutoff)
+	}
+	var comments []model.Comment
+	if comments, err = a.ScrapeThread(db, thread, cutoff); err == nil {
+		err = db.AddComments(thread.SiteId, thread.Id, comments)
+	}
+	return
+}
+
+// Implemented by adapters that can extract a comment again from the HTML kept
+// when it was scraped, so extraction fixes can be applied to old comments.
+type Reextractor interface {
+	Reextract(thread model.Thread, commentURL *url.URL, html string) (model.Comment, error)
+}
+
+// Implemented by adapters whose requests for a site go to other hosts than
+// the site's own, such as an API host.
+type RequestHoster interface {
+	RequestHosts(hostname string) []string
+}
+
+// Returns the hosts requests go to when scraping the site at hostname: the
+// site's own, and any others its adapter reports. Requests are counted and
+// limited by the host they go to.
+func RequestHosts(hostname string) (hosts []string) {
+	hosts = []string{hostname}
+	u := &url.URL{Scheme: "https", Host: hostname, Path: "/"}
+	if a, _, err := ForURL(u); err == nil {
+		if hoster, ok := a.(RequestHoster); ok {
+			for _, h := range hoster.RequestHosts(hostname) {
+				if !slices.Contains(hosts, h) {
+					hosts = append(hosts, h)
+				}
+			}
+		}
+	}
+	return
+}
+
+var (
+	registryMutex sync.Mutex
+	registry      = make(map[string]SiteAdapter)
+	hostAdapters  = make(map[string]string)
+)
+
+// Makes an adapter available to ForURL. Panics if the name is already taken.
+func Register(a SiteAdapter) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	if _, dup := registry[a.Name()]; dup {
+		panic(fmt.Sprintf("Adapter %q registered twice", a.Name()))
+	}
+	registry[a.Name()] = a
+}
+
+// Returns the registered adapters sorted by name.
+func All() (adapters []SiteAdapter) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	for _, a := range registry {
+		adapters = append(adapters, a)
+	}
+	sort.Slice(adapters, func(i, j int) bool { return adapters[i].Name() < adapters[j].Name() })
+	return
+}
+
+func ByName(name string) (a SiteAdapter, err error) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	var ok bool
+	if a, ok = registry[name]; !ok {
+		err = fmt.Errorf("No adapter named %q", name)
+	}
+	return
+}
+
+// Makes ForURL use the named adapter for URLs at the host, e.g. for a site
+// that more than one adapter matches.
+func SetHostAdapter(hostname, name string) (err error) {
+	if _, err = ByName(name); err == nil {
+		registryMutex.Lock()
+		defer registryMutex.Unlock()
+
+		hostAdapters[hostname] = name
+	}
+	return
+}
+
+func hostAdapter(hostname string) (name string, found bool) {
+	registryMutex.Lock()
+	defer registryMutex.Unlock()
+
+	name, found = hostAdapters[hostname]
+	return
+}
+
+// Returns the adapter set for the URL's host, or else the first adapter, by
+// name, that handles the URL.
+func ForURL(u *url.URL) (a SiteAdapter, kind URLKind, err error) {
+	if name, found := hostAdapter(u.Hostname()); found {
+		if a, err = ByName(name); err == nil {
+			if kind = a.Matches(u); kind == NoMatch {
+				a, err = nil, fmt.Errorf("The %s adapter set for %s does not handle %q", name, u.Hostname(), u)
+			}
+		}
+		return
+	}
+	for _, candidate := range All() {
+		if kind = candidate.Matches(u); kind != NoMatch {
+			return candidate, kind, nil
+		}
+	}
+	return nil, NoMatch, fmt.Errorf("No adapter handles %q", u)
+}
@@ -0,0 +1,80 @@
+package adapter
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+type fakeAdapter struct {
+	name string
+	host string
+}
+
+func (f fakeAdapter) Name() string        { return f.name }
+func (f fakeAdapter) URLShapes() []string { return []string{"https://" + f.host + "/..."} }
+
+func (f fakeAdapter) Matches(u *url.URL) URLKind {
+	if u.Host != f.host {
+		return NoMatch
+	} else if strings.HasPrefix(u.Path, "/t/") {
+		return ThreadURL
+	}
+	return ForumURL
+}
+
+func (fakeAdapter) ScrapeForum(*database.ScraperDB, *url.URL, time.Time, bool) error {
+	return nil
+}
+
+func (fakeAdapter) ScrapeThread(*database.ScraperDB, model.Thread, time.Time) ([]model.Comment, error) {
+	return nil, nil
+}
+
+func (fakeAdapter) ParsePage(*url.URL) (Page, error) {
+	return Page{}, nil
+}
+
+func TestRegistry(t *testing.T) {
+	Register(fakeAdapter{"zeta", "zeta.com"})
+	Register(fakeAdapter{"alpha", "alpha.com"})
+
+	all := All()
+	require.Equal(t, 2, len(all))
+	require.Equal(t, "alpha", all[0].Name())
+
+	require.Panics(t, func() { Register(fakeAdapter{"alpha", "other.com"}) })
+
+	u, _ := url.Parse("https://zeta.com/t/some-topic/12")
+	a, kind, err := ForURL(u)
+	require.Nil(t, err)
+	require.Equal(t, "zeta", a.Name())
+	require.Equal(t, ThreadURL, kind)
+
+	u, _ = url.Parse("https://unknown.com/forums/x")
+	_, kind, err = ForURL(u)
+	require.NotNil(t, err)
+	require.Equal(t, NoMatch, kind)
+
+	a, err = ByName("alpha")
+	require.Nil(t, err)
+	require.Equal(t, "alpha", a.Name())
+
+	// An adapter set for a host is used over the first that matches
+	Register(fakeAdapter{"omega", "alpha.com"})
+	u, _ = url.Parse("https://alpha.com/t/some-topic/12")
+	a, _, err = ForURL(u)
+	require.Nil(t, err)
+	require.Equal(t, "alpha", a.Name())
+	require.Nil(t, SetHostAdapter("alpha.com", "omega"))
+	a, kind, err = ForURL(u)
+	require.Nil(t, err)
+	require.Equal(t, "omega", a.Name())
+	require.Equal(t, ThreadURL, kind)
+	require.NotNil(t, SetHostAdapter("alpha.com", "missing"))
+}
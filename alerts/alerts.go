@@ -0,0 +1,72 @@
+// Package alerts evaluates saved watches against newly scraped comments and
+// sends their matches to sinks.
+package alerts
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/zvonler/espy/database"
+)
+
+// The outcome of running one watch.
+type Result struct {
+	Watch   database.Watch
+	Matches int
+	Err     error
+}
+
+// Sends each watch's matches among the comments inserted since it last ran,
+// then advances its high-water mark. A watch whose sink fails keeps its mark,
+// so its matches are sent again next time.
+func Run(db *database.ScraperDB, watches []database.Watch) (results []Result) {
+	for _, w := range watches {
+		result := Result{Watch: w}
+		var sink Sink
+		var matches []database.WatchMatch
+		highWater := w.HighWater
+		if sink, result.Err = ParseSink(w.Sink); result.Err == nil {
+			if matches, highWater, result.Err = db.NewWatchMatches(w); result.Err == nil && len(matches) > 0 {
+				result.Err = sink.Send(w.Name, toAlerts(w.Name, matches))
+			}
+		}
+		if result.Err == nil {
+			result.Matches = len(matches)
+			if highWater != w.HighWater {
+				db.SetWatchHighWater(w.Id, highWater)
+			}
+		}
+		results = append(results, result)
+	}
+	return
+}
+
+// Runs every watch, logging the ones that fail. Called after scraping.
+func RunAll(db *database.ScraperDB) {
+	watches, err := db.Watches()
+	if err != nil {
+		log.Printf("Failed to load watches: %v\n", err)
+		return
+	}
+	for _, result := range Run(db, watches) {
+		if result.Err != nil {
+			log.Printf("Watch %s failed: %v\n", result.Watch.Name, result.Err)
+		} else if result.Matches > 0 {
+			fmt.Printf("Watch %s: %d new matches sent to %s\n", result.Watch.Name, result.Matches, result.Watch.Sink)
+		}
+	}
+}
+
+func toAlerts(watch string, matches []database.WatchMatch) (alerts []Alert) {
+	for _, m := range matches {
+		alerts = append(alerts, Alert{
+			Watch:     watch,
+			URL:       m.URL.String(),
+			Thread:    m.ThreadTitle,
+			Author:    m.Author,
+			Published: m.Published,
+			Content:   m.Content,
+		})
+	}
+	return
+}
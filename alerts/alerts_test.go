@@ -0,0 +1,196 @@
+package alerts
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+// Returns a database with one thread, and a function adding comments to it.
+func newDB(t *testing.T) (db *database.ScraperDB, add func(content ...string)) {
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	forumURL, _ := url.Parse("https://forum.example.com/forums/vehicles.2/")
+	threadURL, _ := url.Parse("https://forum.example.com/threads/battery-warranty.10/")
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+	threadId, err := db.InsertOrUpdateThread(siteId, forumId, model.Thread{URL: threadURL, Title: "Battery warranty", Author: "alice"})
+	require.Nil(t, err)
+
+	posts := 0
+	add = func(content ...string) {
+		var comments []model.Comment
+		for _, c := range content {
+			posts++
+			comments = append(comments, model.Comment{URL: threadURL.JoinPath(fmt.Sprintf("post-%d", posts)),
+				Author: "bob", Published: time.Unix(1696161600+int64(posts), 0), Content: c})
+		}
+		require.Nil(t, db.AddComments(siteId, threadId, comments))
+	}
+	return
+}
+
+func addWatch(t *testing.T, db *database.ScraperDB, name, sink string) {
+	_, err := db.AddWatch(database.Watch{Name: name, Kind: database.RegexWatch, Query: "(?i)battery", Sink: sink})
+	require.Nil(t, err)
+}
+
+func TestParseSink(t *testing.T) {
+	for _, spec := range []string{"stdout", "jsonl:/tmp/a.jsonl", "webhook:https://example.com/hook", "smtp:a@example.com,b@example.com"} {
+		_, err := ParseSink(spec)
+		require.Nil(t, err, spec)
+	}
+	for _, spec := range []string{"", "stdout:x", "jsonl:", "webhook:example.com", "pager:1"} {
+		_, err := ParseSink(spec)
+		require.NotNil(t, err, spec)
+	}
+}
+
+func TestRunSendsNewMatches(t *testing.T) {
+	db, add := newDB(t)
+	path := t.TempDir() + "/alerts.jsonl"
+	addWatch(t, db, "file", "jsonl:"+path)
+
+	var printed bytes.Buffer
+	stdout = &printed
+	t.Cleanup(func() { stdout = os.Stdout })
+	addWatch(t, db, "print", "stdout")
+
+	add("New battery", "Tires")
+	watches, err := db.Watches()
+	require.Nil(t, err)
+	results := Run(db, watches)
+	require.Nil(t, results[0].Err)
+	require.Equal(t, 1, results[0].Matches)
+	require.Contains(t, printed.String(), "[print] https://forum.example.com/threads/battery-warranty.10/post-1")
+
+	// Matches are only sent once
+	add("Battery again")
+	watches, _ = db.Watches()
+	Run(db, watches)
+	contents, err := os.ReadFile(path)
+	require.Nil(t, err)
+	lines := strings.Split(strings.TrimSpace(string(contents)), "\n")
+	require.Equal(t, 2, len(lines))
+	var alert Alert
+	require.Nil(t, json.Unmarshal([]byte(lines[1]), &alert))
+	require.True(t, alert.Published.Equal(time.Unix(1696161603, 0)))
+	alert.Published = time.Time{}
+	require.Equal(t, Alert{Watch: "file", URL: "https://forum.example.com/threads/battery-warranty.10/post-3",
+		Thread: "Battery warranty", Author: "bob", Content: "Battery again"}, alert)
+}
+
+func TestFailedSinkKeepsMatches(t *testing.T) {
+	db, add := newDB(t)
+	fail := true
+	var received []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if fail {
+			http.Error(w, "down", http.StatusBadGateway)
+			return
+		}
+		var body struct {
+			Watch  string
+			Alerts []Alert
+		}
+		require.Nil(t, json.NewDecoder(r.Body).Decode(&body))
+		for _, a := range body.Alerts {
+			received = append(received, body.Watch+": "+a.Content)
+		}
+	}))
+	defer srv.Close()
+	addWatch(t, db, "hook", "webhook:"+srv.URL)
+
+	add("Battery one")
+	watches, _ := db.Watches()
+	results := Run(db, watches)
+	require.NotNil(t, results[0].Err)
+
+	fail = false
+	add("Battery two")
+	watches, _ = db.Watches()
+	results = Run(db, watches)
+	require.Nil(t, results[0].Err)
+	require.Equal(t, []string{"hook: Battery one", "hook: Battery two"}, received)
+}
+
+// Accepts one message and returns what the client sent.
+func fakeSMTPServer(t *testing.T) (addr string, transcript chan string) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	require.Nil(t, err)
+	t.Cleanup(func() { l.Close() })
+	transcript = make(chan string, 1)
+
+	go func() {
+		conn, err := l.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		var received strings.Builder
+		reply := func(s string) { io.WriteString(conn, s+"\r\n") }
+		reply("220 localhost ESMTP")
+		for inData := false; ; {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				break
+			}
+			received.WriteString(line)
+			switch {
+			case inData && line == ".\r\n":
+				inData = false
+				reply("250 OK")
+			case inData:
+			case strings.HasPrefix(line, "EHLO"), strings.HasPrefix(line, "HELO"):
+				reply("250 localhost")
+			case strings.HasPrefix(line, "DATA"):
+				inData = true
+				reply("354 Go ahead")
+			case strings.HasPrefix(line, "QUIT"):
+				reply("221 Bye")
+				transcript <- received.String()
+				return
+			default:
+				reply("250 OK")
+			}
+		}
+	}()
+	return l.Addr().String(), transcript
+}
+
+func TestSMTPSink(t *testing.T) {
+	db, add := newDB(t)
+	addr, transcript := fakeSMTPServer(t)
+	addWatch(t, db, "mail", "smtp:alice@example.com")
+
+	add("Battery news")
+	watches, _ := db.Watches()
+	require.NotNil(t, Run(db, watches)[0].Err)
+
+	ConfigureSMTP(SMTPConfig{Addr: addr, From: "espy@example.com"})
+	t.Cleanup(func() { ConfigureSMTP(SMTPConfig{}) })
+	results := Run(db, watches)
+	require.Nil(t, results[0].Err)
+
+	sent := <-transcript
+	require.Contains(t, sent, "RCPT TO:<alice@example.com>")
+	require.Contains(t, sent, "Subject: espy: 1 new comments match mail")
+	require.Contains(t, sent, "    Battery news")
+}
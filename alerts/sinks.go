@@ -0,0 +1,182 @@
+package alerts
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/smtp"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// A comment that matched a watch, as sent to sinks.
+type Alert struct {
+	Watch     string    `json:"watch"`
+	URL       string    `json:"url"`
+	Thread    string    `json:"thread"`
+	Author    string    `json:"author"`
+	Published time.Time `json:"published"`
+	Content   string    `json:"content"`
+}
+
+// Somewhere a watch's new matches are sent.
+type Sink interface {
+	Send(watch string, alerts []Alert) error
+}
+
+// Parses a sink spec, one of:
+//
+//	stdout
+//	jsonl:<path>             appends one JSON object per match to a file
+//	webhook:<URL>            POSTs the matches as JSON
+//	smtp:<address>[,...]     emails the matches through the configured server
+func ParseSink(spec string) (sink Sink, err error) {
+	kind, arg, _ := strings.Cut(spec, ":")
+	switch {
+	case kind == "stdout" && arg == "":
+		sink = stdoutSink{}
+	case kind == "jsonl" && arg != "":
+		sink = jsonlSink{arg}
+	case kind == "webhook" && (strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://")):
+		sink = webhookSink{arg}
+	case kind == "smtp" && arg != "":
+		sink = smtpSink{strings.Split(arg, ",")}
+	default:
+		err = fmt.Errorf("Bad sink %q: expected stdout, jsonl:<path>, webhook:<URL> or smtp:<address>", spec)
+	}
+	return
+}
+
+/*---------------------------------------------------------------------------*/
+
+// Where the stdout sink writes, replaced by tests.
+var stdout io.Writer = os.Stdout
+
+type stdoutSink struct{}
+
+func (stdoutSink) Send(watch string, alerts []Alert) error {
+	for _, a := range alerts {
+		fmt.Fprintf(stdout, "[%s] %s %s\n%s: %q\n", watch, a.URL, a.Published, a.Author, a.Content)
+		fmt.Fprintln(stdout, "--------")
+	}
+	return nil
+}
+
+/*---------------------------------------------------------------------------*/
+
+type jsonlSink struct {
+	path string
+}
+
+func (s jsonlSink) Send(watch string, alerts []Alert) (err error) {
+	var f *os.File
+	if f, err = os.OpenFile(s.path, os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o644); err != nil {
+		return
+	}
+	enc := json.NewEncoder(f)
+	for _, a := range alerts {
+		if err = enc.Encode(a); err != nil {
+			break
+		}
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return
+}
+
+/*---------------------------------------------------------------------------*/
+
+type webhookSink struct {
+	url string
+}
+
+var webhookClient = &http.Client{Timeout: 30 * time.Second}
+
+func (s webhookSink) Send(watch string, alerts []Alert) (err error) {
+	var body []byte
+	if body, err = json.Marshal(struct {
+		Watch  string  `json:"watch"`
+		Alerts []Alert `json:"alerts"`
+	}{watch, alerts}); err != nil {
+		return
+	}
+
+	var resp *http.Response
+	if resp, err = webhookClient.Post(s.url, "application/json", bytes.NewReader(body)); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		err = fmt.Errorf("Webhook %s returned %s", s.url, resp.Status)
+	}
+	return
+}
+
+/*---------------------------------------------------------------------------*/
+
+// The mail server used by smtp sinks.
+type SMTPConfig struct {
+	// The server as host:port.
+	Addr string
+
+	// Authenticates with PLAIN auth when set.
+	Username string
+	Password string
+
+	From string
+}
+
+var (
+	smtpMutex  sync.Mutex
+	smtpConfig SMTPConfig
+)
+
+func ConfigureSMTP(c SMTPConfig) {
+	smtpMutex.Lock()
+	defer smtpMutex.Unlock()
+
+	smtpConfig = c
+}
+
+type smtpSink struct {
+	to []string
+}
+
+func (s smtpSink) Send(watch string, alerts []Alert) (err error) {
+	smtpMutex.Lock()
+	c := smtpConfig
+	smtpMutex.Unlock()
+
+	if c.Addr == "" || c.From == "" {
+		return fmt.Errorf("Sending mail needs smtp.addr and smtp.from to be configured")
+	}
+
+	var auth smtp.Auth
+	if c.Username != "" {
+		var host string
+		if host, _, err = net.SplitHostPort(c.Addr); err != nil {
+			return
+		}
+		auth = smtp.PlainAuth("", c.Username, c.Password, host)
+	}
+
+	var msg bytes.Buffer
+	fmt.Fprintf(&msg, "From: %s\r\n", c.From)
+	fmt.Fprintf(&msg, "To: %s\r\n", strings.Join(s.to, ", "))
+	fmt.Fprintf(&msg, "Subject: espy: %d new comments match %s\r\n", len(alerts), watch)
+	fmt.Fprintf(&msg, "Content-Type: text/plain; charset=utf-8\r\n\r\n")
+	for _, a := range alerts {
+		fmt.Fprintf(&msg, "%s\r\n%s in %q, %s:\r\n\r\n", a.URL, a.Author, a.Thread, a.Published.Format(time.RFC1123))
+		for _, line := range strings.Split(a.Content, "\n") {
+			fmt.Fprintf(&msg, "    %s\r\n", line)
+		}
+		fmt.Fprintf(&msg, "\r\n")
+	}
+	return smtp.SendMail(c.Addr, auth, c.From, s.to, msg.Bytes())
+}
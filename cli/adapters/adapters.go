@@ -0,0 +1,20 @@
+package adapters
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	adaptersCommand := &cobra.Command{
+		Use:   "adapters",
+		Short: "Commands for working with forum engine adapters",
+		Example: "  # List adapters and the URLs they handle\n" +
+			"  " + os.Args[0] + " adapters list",
+	}
+
+	adaptersCommand.AddCommand(initListCommand())
+
+	return adaptersCommand
+}
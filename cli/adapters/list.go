@@ -0,0 +1,42 @@
+package adapters
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/output"
+)
+
+func initListCommand() *cobra.Command {
+	listCommand := &cobra.Command{
+		Use:   "list",
+		Short: "Lists registered adapters and the URL shapes they handle",
+		Args:  cobra.NoArgs,
+		Run:   runListCommand,
+	}
+	return listCommand
+}
+
+func runListCommand(cmd *cobra.Command, args []string) {
+	adapters := adapter.All()
+
+	if !output.IsText() {
+		records := make([]output.Adapter, len(adapters))
+		for i, a := range adapters {
+			records[i] = output.Adapter{Name: a.Name(), URLShapes: a.URLShapes()}
+		}
+		if err := output.Print(records); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	for _, a := range adapters {
+		fmt.Println(a.Name())
+		for _, shape := range a.URLShapes() {
+			fmt.Printf("  %s\n", shape)
+		}
+	}
+}
@@ -17,7 +17,10 @@ func NewCommand() *cobra.Command {
 
 	authorCommand.AddCommand(initContentCommand())
 	authorCommand.AddCommand(initGrepCommand())
+	authorCommand.AddCommand(initInteractionsCommand())
 	authorCommand.AddCommand(initIntersectCommand())
+	authorCommand.AddCommand(initShowCommand())
+	authorCommand.AddCommand(initTopCommand())
 
 	return authorCommand
 }
@@ -5,28 +5,30 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
 	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
 )
 
 func initContentCommand() *cobra.Command {
 	contentCommand := &cobra.Command{
-		Use:   "content [-d DB] <username>",
+		Use:   "content <username>",
 		Short: "Prints the content of an author's comments",
 		Args:  cobra.ExactArgs(1),
 		Run:   runContentCommand,
 	}
 
-	contentCommand.Flags().StringVar(&dbPath, "database", "espy.db", "Database filename")
-
 	return contentCommand
 }
 
 func runContentCommand(cmd *cobra.Command, args []string) {
 	var err error
+	var sdb *database.ScraperDB
+	var comments []model.Comment
 
-	if sdb, err := database.OpenScraperDB(dbPath); err == nil {
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
 		defer sdb.Close()
-		if comments, err := sdb.FindAuthorComments(args[0]); err == nil {
+		if comments, err = sdb.FindAuthorComments(args[0]); err == nil {
 			for _, comment := range comments {
 				fmt.Println(comment.URL)
 				fmt.Println(comment.Content)
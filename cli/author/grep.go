@@ -9,24 +9,24 @@ import (
 
 	"github.com/ryanuber/columnize"
 	"github.com/spf13/cobra"
-	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
 )
 
 func initGrepCommand() *cobra.Command {
 	grepCommand := &cobra.Command{
-		Use:   "grep [-d DB] <regex>...",
-		Short: "Locates author usernames matching one or more regular expression(s)",
+		Use:   "grep <regex>...",
+		Short: "Locates authors with current or previous usernames matching one or more regular expression(s)",
 		Args:  cobra.MinimumNArgs(1),
 		Run:   runGrepCommand,
 	}
 
-	grepCommand.Flags().StringVar(&dbPath, "database", "espy.db", "Database filename")
-
 	return grepCommand
 }
 
 func runGrepCommand(cmd *cobra.Command, args []string) {
-	sdb, err := database.OpenScraperDB(dbPath)
+	sdb, err := configuration.OpenExistingDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -40,11 +40,11 @@ func runGrepCommand(cmd *cobra.Command, args []string) {
 			    c.author_id = a.id
 			AND s.id = a.site_id`
 
+	// Authors match on their current username or any they used before
 	exprs := make([]string, len(args))
 	anyArgs := make([]any, len(args))
 	for i := range args {
-		e := "AND a.username REGEXP ?"
-		exprs = append(exprs, e)
+		exprs[i] = "AND a.id IN (SELECT author_id FROM author_alias WHERE username REGEXP ?)"
 		anyArgs[i] = args[i]
 	}
 	stmt = stmt + " " + strings.Join(exprs, " ")
@@ -53,9 +53,7 @@ func runGrepCommand(cmd *cobra.Command, args []string) {
 		GROUP BY a.id, a.username
 		ORDER BY latest DESC, comments`
 
-	output := []string{
-		"AuthorID | Username | Site | Comments | Latest",
-	}
+	var summaries []output.AuthorSummary
 
 	sdb.ForEachRowOrPanic(
 		func(rows *sql.Rows) {
@@ -65,10 +63,29 @@ func runGrepCommand(cmd *cobra.Command, args []string) {
 			var comments uint
 			var latestTm int64
 			rows.Scan(&id, &username, &site, &comments, &latestTm)
-			latest := time.Unix(latestTm, 0)
-			output = append(output, fmt.Sprintf("%d | %s | %s | %d | %v", id, username, site, comments, latest))
+			summaries = append(summaries, output.AuthorSummary{
+				Id:       model.AuthorID(id),
+				Username: username,
+				Site:     site,
+				Comments: comments,
+				Latest:   time.Unix(latestTm, 0).UTC(),
+			})
 		},
 		stmt, anyArgs...)
 
-	fmt.Println(columnize.SimpleFormat(output))
+	if !output.IsText() {
+		if err = output.Print(summaries); err != nil {
+			log.Fatal(err)
+		}
+		return
+	}
+
+	lines := []string{
+		"AuthorID | Username | Site | Comments | Latest",
+	}
+	for _, a := range summaries {
+		lines = append(lines, fmt.Sprintf("%d | %s | %s | %d | %v", a.Id, a.Username, a.Site, a.Comments, a.Latest))
+	}
+
+	fmt.Println(columnize.SimpleFormat(lines))
 }
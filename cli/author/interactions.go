@@ -0,0 +1,49 @@
+package author
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+func initInteractionsCommand() *cobra.Command {
+	interactionsCommand := &cobra.Command{
+		Use:   "interactions <username>",
+		Short: "Lists the authors a user has quoted or been quoted by",
+		Args:  cobra.ExactArgs(1),
+		Run:   runInteractionsCommand,
+	}
+	return interactionsCommand
+}
+
+func runInteractionsCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var interactions []database.Interaction
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if interactions, err = sdb.AuthorInteractions(args[0]); err == nil {
+			if !output.IsText() {
+				records := make([]output.Interaction, len(interactions))
+				for i, in := range interactions {
+					records[i] = output.Interaction{Username: in.Username, Quoted: in.Quoted, QuotedBy: in.QuotedBy}
+				}
+				err = output.Print(records)
+			} else {
+				fmt.Printf("%-24s %8s %10s\n", "Author", "Quoted", "Quoted by")
+				for _, in := range interactions {
+					fmt.Printf("%-24s %8d %10d\n", in.Username, in.Quoted, in.QuotedBy)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
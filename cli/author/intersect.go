@@ -5,23 +5,17 @@ import (
 	"log"
 
 	"github.com/spf13/cobra"
-	"github.com/zvonler/espy/database"
-)
-
-var (
-	dbPath string
+	"github.com/zvonler/espy/configuration"
 )
 
 func initIntersectCommand() *cobra.Command {
 	intersectCommand := &cobra.Command{
-		Use:   "intersect [-d DB] <query1> <query2>",
+		Use:   "intersect <query1> <query2>",
 		Short: "Returns usernames that match both provided queries",
 		Args:  cobra.ExactArgs(2),
 		Run:   runIntersectCommand,
 	}
 
-	intersectCommand.Flags().StringVar(&dbPath, "database", "espy.db", "Database filename")
-
 	return intersectCommand
 }
 
@@ -29,7 +23,7 @@ func runIntersectCommand(cmd *cobra.Command, args []string) {
 	authorQuery := "SELECT username FROM author WHERE "
 	sql := fmt.Sprintf("%s %s INTERSECT %s %s", authorQuery, args[0], authorQuery, args[1])
 
-	sdb, err := database.OpenScraperDB(dbPath)
+	sdb, err := configuration.OpenExistingDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
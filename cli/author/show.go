@@ -0,0 +1,116 @@
+package author
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+func initShowCommand() *cobra.Command {
+	showCommand := &cobra.Command{
+		Use:   "show <username>",
+		Short: "Shows an author's profile and activity",
+		Long: "Shows the profile scraped for an author along with their activity in the database.\n" +
+			"Authors are matched by current or previous username.",
+		Args: cobra.ExactArgs(1),
+		Run:  runShowCommand,
+	}
+	return showCommand
+}
+
+func runShowCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var details []database.AuthorDetails
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if details, err = sdb.AuthorDetails(args[0]); err == nil {
+			if len(details) == 0 {
+				err = fmt.Errorf("no author named %q", args[0])
+			} else if !output.IsText() {
+				records := make([]output.Author, len(details))
+				for i, d := range details {
+					records[i] = authorRecord(d)
+				}
+				err = output.Print(records)
+			} else {
+				for i, d := range details {
+					if i > 0 {
+						fmt.Println()
+					}
+					printAuthorDetails(d)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func authorRecord(d database.AuthorDetails) output.Author {
+	r := output.Author{
+		Site:           d.Hostname,
+		Username:       d.Username,
+		ExternalId:     d.ExternalId,
+		Aliases:        d.Aliases,
+		Comments:       d.Comments,
+		Threads:        d.Threads,
+		ThreadsStarted: d.ThreadsStarted,
+		FirstComment:   d.FirstComment,
+		LastComment:    d.LastComment,
+	}
+	if p := d.Profile; p != nil {
+		r.Title = p.Title
+		r.Joined = p.Joined
+		r.Messages = p.Messages
+		r.ReactionScore = p.ReactionScore
+		r.Location = p.Location
+	}
+	return r
+}
+
+func printAuthorDetails(d database.AuthorDetails) {
+	const dateFormat = "2006-01-02"
+	date := func(t time.Time) string {
+		if t.IsZero() {
+			return "-"
+		}
+		return t.Format(dateFormat)
+	}
+
+	r := authorRecord(d)
+	fmt.Printf("%-16s %s\n", "Site:", r.Site)
+	fmt.Printf("%-16s %s\n", "Username:", r.Username)
+	if len(r.Aliases) > 0 {
+		fmt.Printf("%-16s %s\n", "Previously:", strings.Join(r.Aliases, ", "))
+	}
+	if r.ExternalId != "" {
+		fmt.Printf("%-16s %s\n", "User id:", r.ExternalId)
+	}
+	if p := d.Profile; p != nil {
+		if p.Title != "" {
+			fmt.Printf("%-16s %s\n", "Title:", p.Title)
+		}
+		fmt.Printf("%-16s %s\n", "Joined:", date(p.Joined))
+		fmt.Printf("%-16s %d\n", "Messages:", p.Messages)
+		fmt.Printf("%-16s %d\n", "Reaction score:", p.ReactionScore)
+		if p.Location != "" {
+			fmt.Printf("%-16s %s\n", "Location:", p.Location)
+		}
+		fmt.Printf("%-16s %s\n", "Profile updated:", date(d.Updated))
+	}
+	fmt.Printf("%-16s %d\n", "Comments:", d.Comments)
+	fmt.Printf("%-16s %d\n", "Threads:", d.Threads)
+	fmt.Printf("%-16s %d\n", "Threads started:", d.ThreadsStarted)
+	fmt.Printf("%-16s %s\n", "First comment:", date(d.FirstComment))
+	fmt.Printf("%-16s %s\n", "Last comment:", date(d.LastComment))
+}
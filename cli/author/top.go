@@ -0,0 +1,64 @@
+package author
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+var (
+	topBy    string
+	topLimit uint
+)
+
+func initTopCommand() *cobra.Command {
+	topCommand := &cobra.Command{
+		Use:   "top",
+		Short: "Lists the authors whose comments scored highest or drew the most reactions",
+		Args:  cobra.NoArgs,
+		Example: "" +
+			"  " + os.Args[0] + " author top --by score --limit 20",
+		Run: runTopCommand,
+	}
+
+	topCommand.Flags().StringVar(&topBy, "by", string(database.ByScore), "Rank authors by the total score or reactions of their comments")
+	topCommand.Flags().UintVar(&topLimit, "limit", 10, "Maximum number of authors to list")
+
+	return topCommand
+}
+
+func runTopCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var by database.Ranking
+	var authors []database.RankedAuthor
+
+	if by, err = database.ParseRanking(topBy); err == nil {
+		if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+			defer sdb.Close()
+			if authors, err = sdb.TopAuthors(by, topLimit); err == nil {
+				if !output.IsText() {
+					records := make([]output.RankedAuthor, len(authors))
+					for i, a := range authors {
+						records[i] = output.RankedAuthor{Username: a.Username, Site: a.Site, Comments: a.Comments, Total: a.Total}
+					}
+					err = output.Print(records)
+				} else {
+					fmt.Printf("%-24s %-24s %8s %8s\n", "Author", "Site", "Comments", "Total")
+					for _, a := range authors {
+						fmt.Printf("%-24s %-24s %8d %8d\n", a.Username, a.Site, a.Comments, a.Total)
+					}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
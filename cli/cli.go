@@ -1,22 +1,43 @@
 package cli
 
 import (
+	"errors"
 	"fmt"
 	"os"
 
 	"github.com/spf13/cobra"
 	"github.com/spf13/viper"
+	"github.com/zvonler/espy/cli/adapters"
 	"github.com/zvonler/espy/cli/author"
 	"github.com/zvonler/espy/cli/comment"
+	"github.com/zvonler/espy/cli/config"
+	"github.com/zvonler/espy/cli/daemon"
+	"github.com/zvonler/espy/cli/db"
 	"github.com/zvonler/espy/cli/forum"
 	"github.com/zvonler/espy/cli/parse"
+	"github.com/zvonler/espy/cli/runs"
 	"github.com/zvonler/espy/cli/scrape"
 	"github.com/zvonler/espy/cli/site"
 	"github.com/zvonler/espy/cli/thread"
+	"github.com/zvonler/espy/cli/watch"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/output"
+	"github.com/zvonler/espy/replay"
+	"github.com/zvonler/espy/scheduler"
+
+	// Site adapters register themselves when imported
+	_ "github.com/zvonler/espy/discourse_scraper"
+	_ "github.com/zvonler/espy/phpbb_scraper"
+	_ "github.com/zvonler/espy/reddit"
+	_ "github.com/zvonler/espy/xf_scraper"
 )
 
 var (
-	dbPath string
+	cfgFile   string
+	dbPath    string
+	format    string
+	recordDir string
+	replayDir string
 )
 
 func NewCommand() *cobra.Command {
@@ -25,18 +46,67 @@ func NewCommand() *cobra.Command {
 		Short:   "Espy CLI",
 		Long:    "Espy Command Line Interface",
 		Example: fmt.Sprintf("  %s <command> [flags...]", os.Args[0]),
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) (err error) {
+			if err = configuration.Load(cfgFile); err != nil {
+				return
+			}
+			if _, err = output.ParseFormat(viper.GetString("format")); err != nil {
+				return
+			}
+			if err = configuration.ApplySiteConfig(); err != nil {
+				return
+			}
+			if err = configuration.ApplyRedditConfig(); err != nil {
+				return
+			}
+			if err = configuration.ApplyRequestLimits(); err != nil {
+				return
+			}
+			if err = configuration.ApplyAlertConfig(); err != nil {
+				return
+			}
+			if recordDir != "" && replayDir != "" {
+				return errors.New("--record and --replay cannot be used together")
+			} else if recordDir != "" {
+				replay.Record(recordDir)
+			} else if replayDir != "" {
+				replay.Replay(replayDir)
+			}
+			return
+		},
 	}
 
+	espyCli.PersistentFlags().StringVar(&cfgFile, "config", "", "Config file (default "+configuration.DefaultConfigPath()+")")
 	espyCli.PersistentFlags().StringVar(&dbPath, "database", "espy.db", "Database filename")
 	viper.BindPFlag("database", espyCli.PersistentFlags().Lookup("database"))
+	espyCli.PersistentFlags().StringVar(&format, "format", string(output.Text), "Output format: text, json, ndjson, csv or tsv")
+	viper.BindPFlag("format", espyCli.PersistentFlags().Lookup("format"))
+	espyCli.PersistentFlags().StringVar(&recordDir, "record", "", "Save every fetched page under this directory")
+	espyCli.PersistentFlags().StringVar(&replayDir, "replay", "", "Serve fetched pages from a directory saved with --record")
+	espyCli.PersistentFlags().Bool("keep-html", false, "Store the HTML of scraped comments so 'db reextract' can re-run extraction")
+	viper.BindPFlag("keep-html", espyCli.PersistentFlags().Lookup("keep-html"))
+	espyCli.PersistentFlags().Duration("request-interval", scheduler.DefaultLimits.Interval, "Minimum time between requests to a host")
+	viper.BindPFlag("request-interval", espyCli.PersistentFlags().Lookup("request-interval"))
+	espyCli.PersistentFlags().Duration("request-jitter", scheduler.DefaultLimits.Jitter, "Maximum random delay added to request-interval")
+	viper.BindPFlag("request-jitter", espyCli.PersistentFlags().Lookup("request-jitter"))
+	espyCli.PersistentFlags().Int("max-in-flight", scheduler.DefaultLimits.MaxInFlight, "Maximum concurrent requests to a host")
+	viper.BindPFlag("max-in-flight", espyCli.PersistentFlags().Lookup("max-in-flight"))
+	espyCli.PersistentFlags().StringArray("host-limit", nil, "Limits for one host as host=interval/jitter/max-in-flight (repeatable)")
+	viper.BindPFlag("host-limit", espyCli.PersistentFlags().Lookup("host-limit"))
 
+	espyCli.AddCommand(adapters.NewCommand())
 	espyCli.AddCommand(author.NewCommand())
 	espyCli.AddCommand(comment.NewCommand())
+	espyCli.AddCommand(config.NewCommand())
+	espyCli.AddCommand(daemon.NewCommand())
+	espyCli.AddCommand(db.NewCommand())
 	espyCli.AddCommand(forum.NewCommand())
 	espyCli.AddCommand(parse.NewCommand())
+	espyCli.AddCommand(runs.NewCommand())
 	espyCli.AddCommand(scrape.NewCommand())
 	espyCli.AddCommand(site.NewCommand())
 	espyCli.AddCommand(thread.NewCommand())
+	espyCli.AddCommand(watch.NewCommand())
 
 	return espyCli
 }
@@ -11,6 +11,10 @@ func NewCommand() *cobra.Command {
 	}
 
 	commentCommand.AddCommand(initGrepCommand())
+	commentCommand.AddCommand(initHistoryCommand())
+	commentCommand.AddCommand(initLinksCommand())
+	commentCommand.AddCommand(initRemovedCommand())
+	commentCommand.AddCommand(initSearchCommand())
 
 	return commentCommand
 }
@@ -15,6 +15,7 @@ import (
 	"github.com/zvonler/espy/configuration"
 	"github.com/zvonler/espy/database"
 	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
 	"golang.org/x/term"
 )
 
@@ -117,10 +118,16 @@ func runGrepCommand(cmd *cobra.Command, args []string) {
 			}, stmt, anyArgs...)
 
 		isTty := term.IsTerminal(int(os.Stdout.Fd()))
-		if isTty {
+		if !output.IsText() {
+			err = output.Print(output.NewComments(comments))
+		} else if isTty {
 			paginateComments(comments)
 		} else {
 			printComments(comments)
 		}
 	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
 }
@@ -0,0 +1,89 @@
+package comment
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"os"
+
+	"github.com/bit101/go-ansi"
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+	"github.com/zvonler/espy/utils"
+)
+
+func initHistoryCommand() *cobra.Command {
+	historyCommand := &cobra.Command{
+		Use:   "history <URL>",
+		Short: "Shows how a comment's content changed between scrapes",
+		Args:  cobra.ExactArgs(1),
+		Example: "" +
+			"  " + os.Args[0] + " comment history https://site.com/threads/title.123/post-456",
+		Run: runHistoryCommand,
+	}
+
+	return historyCommand
+}
+
+func runHistoryCommand(cmd *cobra.Command, args []string) {
+	commentURL, err := url.Parse(args[0])
+	if err != nil {
+		log.Fatalf("Bad URL: %v", err)
+	}
+
+	var sdb *database.ScraperDB
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+
+		var revisions []database.CommentRevision
+		if revisions, err = sdb.CommentRevisions(commentURL); err == nil {
+			if !output.IsText() {
+				var records []output.CommentRevision
+				for i, rev := range revisions {
+					records = append(records, output.CommentRevision{
+						URL:      commentURL.String(),
+						Revision: uint(i + 1),
+						Seen:     rev.Seen.UTC(),
+						Content:  rev.Content,
+					})
+				}
+				err = output.Print(records)
+			} else {
+				printRevisions(revisions)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Prints the first revision in full, then each later one as a diff against
+// the one before it.
+func printRevisions(revisions []database.CommentRevision) {
+	for i, rev := range revisions {
+		ansi.Fprintf(os.Stdout, ansi.Cyan, "Revision %d ", i+1)
+		ansi.Fprintf(os.Stdout, ansi.Green, "seen %s\n", rev.Seen)
+		if i == 0 {
+			fmt.Println(rev.Content)
+		} else {
+			for _, line := range utils.LineDiff(revisions[i-1].Content, rev.Content) {
+				switch line.Op {
+				case utils.DiffDelete:
+					ansi.Fprintf(os.Stdout, ansi.Red, "%s %s\n", line.Op, line.Text)
+				case utils.DiffInsert:
+					ansi.Fprintf(os.Stdout, ansi.Green, "%s %s\n", line.Op, line.Text)
+				default:
+					fmt.Printf("%s %s\n", line.Op, line.Text)
+				}
+			}
+		}
+		ansi.Fprintln(os.Stdout, ansi.Blue, "--------")
+	}
+	if len(revisions) == 1 {
+		fmt.Println("No changes seen since first scraped")
+	}
+}
@@ -0,0 +1,95 @@
+package comment
+
+import (
+	"fmt"
+	"log"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+var (
+	domain   string
+	byDomain bool
+)
+
+func initLinksCommand() *cobra.Command {
+	linksCommand := &cobra.Command{
+		Use:   "links",
+		Short: "Lists links shared in comments",
+		Args:  cobra.NoArgs,
+		Example: "" +
+			"  # Lists links to youtube.com and its subdomains\n" +
+			"  " + os.Args[0] + " comment links --domain youtube.com\n" +
+			"  # Counts links to each site\n" +
+			"  " + os.Args[0] + " comment links --by-domain",
+		Run: runLinksCommand,
+	}
+
+	linksCommand.Flags().StringVar(&domain, "domain", "", "Only links to this host and its subdomains")
+	linksCommand.Flags().BoolVar(&byDomain, "by-domain", false, "Count links to each domain instead of listing them")
+
+	return linksCommand
+}
+
+func runLinksCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if byDomain {
+			err = printDomainCounts(sdb)
+		} else {
+			err = printLinks(sdb)
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printLinks(sdb *database.ScraperDB) (err error) {
+	var links []database.CommentLink
+	if links, err = sdb.CommentLinks(domain); err == nil {
+		if !output.IsText() {
+			records := make([]output.Link, len(links))
+			for i, l := range links {
+				records[i] = output.Link{
+					Comment:   l.Comment.String(),
+					Published: l.Published.UTC(),
+					URL:       l.URL.String(),
+					Text:      l.Text,
+				}
+			}
+			err = output.Print(records)
+		} else {
+			for _, l := range links {
+				fmt.Printf("%s %s %q\n", l.Comment, l.URL, l.Text)
+			}
+		}
+	}
+	return
+}
+
+func printDomainCounts(sdb *database.ScraperDB) (err error) {
+	var counts []database.DomainCount
+	if counts, err = sdb.LinkDomainCounts(); err == nil {
+		if !output.IsText() {
+			records := make([]output.DomainCount, len(counts))
+			for i, c := range counts {
+				records[i] = output.DomainCount{Domain: c.Domain, Count: c.Count}
+			}
+			err = output.Print(records)
+		} else {
+			for _, c := range counts {
+				fmt.Printf("%8d %s\n", c.Count, c.Domain)
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package comment
+
+import (
+	"log"
+	"os"
+	"time"
+
+	"github.com/bit101/go-ansi"
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
+	"github.com/zvonler/espy/utils"
+)
+
+var (
+	removedSince string
+)
+
+func initRemovedCommand() *cobra.Command {
+	removedCommand := &cobra.Command{
+		Use:   "removed",
+		Short: "Lists comments that disappeared from their thread when it was re-scraped",
+		Args:  cobra.NoArgs,
+		Example: "" +
+			"  " + os.Args[0] + " comment removed --since 7d",
+		Run: runRemovedCommand,
+	}
+
+	removedCommand.Flags().StringVar(&removedSince, "since", "30d", "Only comments removed since this date, time or age (e.g. 2023-10-01, 36h, 7d)")
+
+	return removedCommand
+}
+
+func runRemovedCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var since time.Time
+
+	if since, err = utils.ParseSince(removedSince, time.Now()); err == nil {
+		if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+			defer sdb.Close()
+			err = PrintRemovedComments(sdb, 0, since)
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Prints the comments removed since the given time, from one thread unless
+// threadId is zero.
+func PrintRemovedComments(sdb *database.ScraperDB, threadId model.ThreadID, since time.Time) (err error) {
+	var comments []database.RemovedComment
+	if comments, err = sdb.RemovedComments(threadId, since); err == nil {
+		if !output.IsText() {
+			records := make([]output.RemovedComment, len(comments))
+			for i, c := range comments {
+				records[i] = output.RemovedComment{Comment: output.NewComment(c.Comment), RemovedAt: c.RemovedAt.UTC()}
+			}
+			err = output.Print(records)
+		} else {
+			for _, c := range comments {
+				ansi.Fprintf(os.Stdout, ansi.Cyan, "%s ", c.URL)
+				ansi.Fprintf(os.Stdout, ansi.Green, "%s ", c.Published)
+				ansi.Fprintf(os.Stdout, ansi.Purple, "removed %s\n", c.RemovedAt)
+				ansi.Fprintf(os.Stdout, ansi.Red, "%s", c.Author)
+				ansi.Fprintf(os.Stdout, ansi.Default, ": %s\n", c.Content)
+				ansi.Fprintln(os.Stdout, ansi.Blue, "--------")
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,99 @@
+package comment
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/bit101/go-ansi"
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"golang.org/x/term"
+)
+
+var (
+	limit int
+)
+
+func initSearchCommand() *cobra.Command {
+	searchCommand := &cobra.Command{
+		Use:   "search <query>...",
+		Short: "Finds comments matching a full-text query, best matches first",
+		Long: "Finds comments matching a full-text query, best matches first.\n\n" +
+			"Queries use SQLite FTS5 syntax: \"exact phrase\", prefix*, AND, OR, NOT,\n" +
+			"NEAR(term1 term2, 10) and parentheses for grouping.",
+		Args: cobra.MinimumNArgs(1),
+		Example: "  # Finds comments mentioning a phrase near a word\n" +
+			"  " + os.Args[0] + " comment search 'NEAR(\"battery pack\" warranty, 5)'",
+		Run: runSearchCommand,
+	}
+
+	searchCommand.Flags().IntVar(&limit, "limit", 50, "Maximum number of comments to return")
+
+	return searchCommand
+}
+
+func paginateHits(hits []database.SearchHit) {
+	cmd := exec.Command("/usr/bin/less", "-FRX")
+	cmd.Stdout = os.Stdout
+
+	if stdin, err := cmd.StdinPipe(); err == nil {
+		go func() {
+			defer stdin.Close()
+
+			for _, h := range hits {
+				ansi.Fprintf(stdin, ansi.Cyan, "%s ", h.URL)
+				ansi.Fprintf(stdin, ansi.Green, "%s ", h.Published)
+				ansi.Fprintf(stdin, ansi.Purple, "(%.2f)\n", -h.Rank)
+				ansi.Fprintf(stdin, ansi.Red, "%s", h.Author)
+				ansi.Fprintf(stdin, ansi.Default, ": %s\n", h.Snippet)
+				ansi.Fprintln(stdin, ansi.Blue, "--------")
+			}
+		}()
+	} else {
+		log.Fatal(err)
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printHits(hits []database.SearchHit) {
+	for _, h := range hits {
+		fmt.Printf("%s %s (%.2f)\n%s: %q\n", h.URL, h.Published, -h.Rank, h.Author, h.Snippet)
+		fmt.Println("--------")
+	}
+}
+
+func runSearchCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var hits []database.SearchHit
+
+	isTty := term.IsTerminal(int(os.Stdout.Fd()))
+	hlStart, hlEnd := "[", "]"
+	if isTty {
+		hlStart, hlEnd = "\x1b[1;33m", "\x1b[0m"
+	}
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		query := strings.Join(args, " ")
+		if hits, err = sdb.SearchComments(query, limit, hlStart, hlEnd); err == nil {
+			if isTty {
+				paginateHits(hits)
+			} else {
+				printHits(hits)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,20 @@
+package config
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	configCommand := &cobra.Command{
+		Use:   "config",
+		Short: "Commands for working with espy's configuration",
+		Example: "  # Print the settings in effect after merging the config file, environment and flags\n" +
+			"  " + os.Args[0] + " config show",
+	}
+
+	configCommand.AddCommand(initShowCommand())
+
+	return configCommand
+}
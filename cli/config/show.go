@@ -0,0 +1,80 @@
+package config
+
+import (
+	"fmt"
+	"log"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/output"
+	"gopkg.in/yaml.v2"
+)
+
+func initShowCommand() *cobra.Command {
+	showCommand := &cobra.Command{
+		Use:   "show",
+		Short: "Prints the effective settings, with secrets masked",
+		Long: "" +
+			"Prints the settings in effect after merging the config file, ESPY_*\n" +
+			"environment variables and command-line flags. Passwords, secrets and\n" +
+			"cookies are masked.",
+		Args: cobra.NoArgs,
+		Run:  runShowCommand,
+	}
+	return showCommand
+}
+
+func runShowCommand(cmd *cobra.Command, args []string) {
+	settings := configuration.Settings()
+
+	var err error
+	if !output.IsText() {
+		var records []output.Setting
+		flatten("", settings, &records)
+		sort.Slice(records, func(i, j int) bool { return records[i].Key < records[j].Key })
+		err = output.Print(records)
+	} else {
+		var out []byte
+		if out, err = yaml.Marshal(settings); err == nil {
+			if path := configuration.ConfigFileUsed(); path != "" {
+				fmt.Printf("# Read from %s\n", path)
+			} else {
+				fmt.Printf("# No config file found at %s\n", configuration.DefaultConfigPath())
+			}
+			fmt.Print(string(out))
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Appends a record for each setting under prefix, naming nested settings
+// like "reddit.client-id" and list items like "sites.0.host".
+func flatten(prefix string, v any, records *[]output.Setting) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := v.(type) {
+	case map[string]any:
+		for key, value := range v {
+			flatten(join(key), value, records)
+		}
+	case map[any]any:
+		for key, value := range v {
+			flatten(join(fmt.Sprint(key)), value, records)
+		}
+	case []any:
+		for i, value := range v {
+			flatten(join(fmt.Sprint(i)), value, records)
+		}
+	default:
+		*records = append(*records, output.Setting{Key: prefix, Value: fmt.Sprint(v)})
+	}
+}
@@ -0,0 +1,60 @@
+package daemon
+
+import (
+	"context"
+	"log"
+	"os"
+	"os/signal"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/refresh"
+)
+
+func NewCommand() *cobra.Command {
+	daemonCommand := &cobra.Command{
+		Use:   "daemon",
+		Short: "Scrapes forums on the schedule in the config file until stopped",
+		Long: "" +
+			"Scrapes the forums and sites in the config file's daemon.schedule, each at its\n" +
+			"own interval, retrying failed scrapes with increasing delays up to\n" +
+			"daemon.max-backoff. Each scrape is recorded in the scrape_run table.\n" +
+			"SIGINT or SIGTERM lets scrapes in progress finish; a second one exits at once.",
+		Args: cobra.NoArgs,
+		Run:  runDaemonCommand,
+	}
+	return daemonCommand
+}
+
+func runDaemonCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var targets []refresh.Target
+	var d *refresh.Daemon
+
+	if targets, err = configuration.Schedule(); err == nil {
+		if sdb, err = configuration.OpenDatabase(); err == nil {
+			defer sdb.Close()
+			if d, err = refresh.New(sdb, targets); err == nil {
+				d.MaxBackoff = configuration.MaxBackoff()
+
+				ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+				go func() {
+					<-ctx.Done()
+					// Restores the default handling, so another signal exits
+					stop()
+					log.Println("Stopping after the scrapes in progress finish")
+				}()
+
+				log.Printf("Scraping %d scheduled targets\n", len(targets))
+				d.Run(ctx)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,22 @@
+package db
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	dbCommand := &cobra.Command{
+		Use:   "db",
+		Short: "Commands for maintaining the database",
+		Example: "  # Show schema migrations that would be applied\n" +
+			"  " + os.Args[0] + " db migrate --dry-run",
+	}
+
+	dbCommand.AddCommand(initMigrateCommand())
+	dbCommand.AddCommand(initReextractCommand())
+	dbCommand.AddCommand(initReindexCommand())
+
+	return dbCommand
+}
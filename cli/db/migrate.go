@@ -0,0 +1,62 @@
+package db
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+)
+
+var (
+	dryRun bool
+)
+
+func initMigrateCommand() *cobra.Command {
+	migrateCommand := &cobra.Command{
+		Use:   "migrate",
+		Short: "Applies pending schema migrations to the database",
+		Args:  cobra.NoArgs,
+		Run:   runMigrateCommand,
+	}
+
+	migrateCommand.Flags().BoolVar(&dryRun, "dry-run", false, "Print pending migrations without applying them")
+
+	return migrateCommand
+}
+
+func runMigrateCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var version uint
+	var pending []database.Migration
+
+	if sdb, err = configuration.OpenExistingDatabaseWithoutMigrating(); err == nil {
+		defer sdb.Close()
+		if version, err = sdb.SchemaVersion(); err == nil {
+			if pending, err = sdb.PendingMigrations(); err == nil {
+				fmt.Printf("Database %q is at schema version %d\n", sdb.Filename, version)
+				if len(pending) == 0 {
+					fmt.Println("No pending migrations")
+				}
+				for _, m := range pending {
+					fmt.Printf("Migration %d: %s\n", m.Version, m.Description)
+					if dryRun {
+						fmt.Println(strings.TrimSpace(m.Stmt))
+					}
+				}
+				if !dryRun && len(pending) > 0 {
+					if err = sdb.Migrate(); err == nil {
+						fmt.Printf("Applied %d migration(s)\n", len(pending))
+					}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
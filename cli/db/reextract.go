@@ -0,0 +1,87 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+func initReextractCommand() *cobra.Command {
+	reextractCommand := &cobra.Command{
+		Use:   "reextract",
+		Short: "Re-runs content extraction over comment HTML kept with --keep-html",
+		Args:  cobra.NoArgs,
+		Run:   runReextractCommand,
+	}
+
+	reextractCommand.Flags().BoolVar(&dryRun, "dry-run", false, "Print comments whose content would change without changing them")
+
+	return reextractCommand
+}
+
+func runReextractCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+
+		threads := make(map[model.ThreadID]model.Thread)
+		var processed, changed, skipped int
+		for _, commentId := range sdb.CommentHTMLIds() {
+			var stored database.CommentHTML
+			if stored, err = sdb.GetCommentHTML(commentId); err != nil {
+				break
+			}
+
+			thread, found := threads[stored.ThreadId]
+			if !found {
+				if thread, err = sdb.GetThreadById(stored.ThreadId); err != nil {
+					break
+				}
+				threads[stored.ThreadId] = thread
+			}
+
+			var reextractor adapter.Reextractor
+			if siteAdapter, _, err := adapter.ForURL(thread.URL); err == nil {
+				reextractor, _ = siteAdapter.(adapter.Reextractor)
+			}
+			if reextractor == nil {
+				skipped++
+				continue
+			}
+
+			var comment model.Comment
+			if comment, err = reextractor.Reextract(thread, stored.Comment.URL, stored.Comment.HTML); err != nil {
+				log.Printf("Failed to re-extract %s: %v\n", stored.Comment.URL, err)
+				skipped++
+				err = nil
+				continue
+			}
+
+			processed++
+			if comment.Content != stored.Comment.Content {
+				changed++
+				fmt.Println(stored.Comment.URL)
+			}
+			if !dryRun {
+				if err = sdb.ReplaceExtractedComment(thread.SiteId, commentId, comment); err != nil {
+					break
+				}
+			}
+		}
+
+		if err == nil {
+			fmt.Printf("Re-extracted %d comment(s), %d with changed content, %d skipped\n", processed, changed, skipped)
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,36 @@
+package db
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+)
+
+func initReindexCommand() *cobra.Command {
+	reindexCommand := &cobra.Command{
+		Use:   "reindex",
+		Short: "Creates or rebuilds the full-text index over comment content",
+		Args:  cobra.NoArgs,
+		Run:   runReindexCommand,
+	}
+	return reindexCommand
+}
+
+func runReindexCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if err = sdb.RebuildCommentIndex(); err == nil {
+			fmt.Printf("Rebuilt full-text index for %q\n", sdb.Filename)
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
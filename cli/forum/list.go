@@ -6,7 +6,9 @@ import (
 	"math"
 
 	"github.com/spf13/cobra"
-	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
 )
 
 func initListCommand() *cobra.Command {
@@ -15,19 +17,24 @@ func initListCommand() *cobra.Command {
 		Short: "Lists forums in the database",
 		Run:   runListCommand,
 	}
-
-	listCommand.Flags().StringVar(&dbPath, "database", "espy.db", "Database filename")
 	return listCommand
 }
 
 func runListCommand(cmd *cobra.Command, args []string) {
-	sdb, err := database.OpenScraperDB(dbPath)
+	sdb, err := configuration.OpenExistingDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer sdb.Close()
 
-	if forums, err := sdb.GetForums(); err == nil {
+	var forums []model.Forum
+	if forums, err = sdb.GetForums(); err == nil && !output.IsText() {
+		records := make([]output.Forum, len(forums))
+		for i, f := range forums {
+			records[i] = output.NewForum(f)
+		}
+		err = output.Print(records)
+	} else if err == nil {
 		colWidth := uint(math.Round(math.Ceil(math.Log10(float64(len(forums))))))
 		fmtString := fmt.Sprintf("%%0%dd: %%s\n", colWidth)
 		for _, f := range forums {
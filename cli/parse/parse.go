@@ -6,14 +6,11 @@ import (
 	"net/url"
 	"os"
 	"os/exec"
-	"strings"
-	"time"
 
 	"github.com/bit101/go-ansi"
 	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/adapter"
 	"github.com/zvonler/espy/model"
-	"github.com/zvonler/espy/reddit"
-	"github.com/zvonler/espy/xf_scraper"
 	"golang.org/x/term"
 )
 
@@ -30,7 +27,7 @@ func NewCommand() *cobra.Command {
 	return parseCommand
 }
 
-func paginateThreads(threads []xf_scraper.XFThread) {
+func paginateThreads(threads []model.Thread) {
 	cmd := exec.Command("/usr/bin/less", "-FRX")
 	cmd.Stdout = os.Stdout
 
@@ -58,14 +55,14 @@ func paginateThreads(threads []xf_scraper.XFThread) {
 	}
 }
 
-func printThreads(threads []xf_scraper.XFThread) {
+func printThreads(threads []model.Thread) {
 	for _, t := range threads {
 		fmt.Printf("%s\n", t.URL)
 		fmt.Println("--------")
 	}
 }
 
-func paginateComments(comments []xf_scraper.XFComment) {
+func paginateComments(comments []model.Comment) {
 	cmd := exec.Command("/usr/bin/less", "-FRX")
 	cmd.Stdout = os.Stdout
 
@@ -91,7 +88,7 @@ func paginateComments(comments []xf_scraper.XFComment) {
 	}
 }
 
-func printComments(comments []xf_scraper.XFComment) {
+func printComments(comments []model.Comment) {
 	for _, c := range comments {
 		fmt.Printf("%s %s\n%s: %q\n", c.URL, c.Published, c.Author, c.Content)
 		fmt.Println("--------")
@@ -106,36 +103,31 @@ func runParseCommand(cmd *cobra.Command, args []string) {
 
 	isTty := term.IsTerminal(int(os.Stdout.Fd()))
 
-	if strings.Contains(url.Host, "reddit.com") {
-		fs := reddit.NewForumScraper(url)
-		cutoff := time.Now().AddDate(0, 0, -7)
-		posts, err := fs.SubredditPostsSince(cutoff)
-		if err != nil {
-			log.Fatal(err)
-		}
-		for _, p := range posts {
-			fmt.Println(p.Title)
-		}
-	} else if strings.Contains(url.Path, "/forums/") {
-		fs := xf_scraper.NewForumScraper(url)
-		fs.Collector.Visit(url.String())
-		for _, thread := range fs.Threads {
-			fmt.Println(thread)
-		}
+	siteAdapter, _, err := adapter.ForURL(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	page, err := siteAdapter.ParsePage(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if len(page.Threads) > 0 {
 		if isTty {
-			paginateThreads(fs.Threads)
+			paginateThreads(page.Threads)
 		} else {
-			printThreads(fs.Threads)
+			printThreads(page.Threads)
 		}
-	} else if strings.Contains(url.Path, "/threads/") {
-		xfThread := xf_scraper.XFThread{model.Thread{URL: url}}
-		ts := xf_scraper.NewThreadScraper(0, xfThread)
-		ts.CommentScraper.Visit(url.String())
-
+	}
+	if len(page.Comments) > 0 {
 		if isTty {
-			paginateComments(ts.Comments)
+			paginateComments(page.Comments)
 		} else {
-			printComments(ts.Comments)
+			printComments(page.Comments)
 		}
 	}
+	for _, subForum := range page.SubForums {
+		fmt.Printf("Sub-forum: %s\n", subForum)
+	}
 }
@@ -0,0 +1,83 @@
+package runs
+
+import (
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+var (
+	target    string
+	sinceDays int
+	failed    bool
+	limit     uint
+)
+
+func initListCommand() *cobra.Command {
+	listCommand := &cobra.Command{
+		Use:   "list",
+		Short: "Lists recent scrapes, newest first",
+		Long: "" +
+			"Lists recent scrapes, newest first, with the number of requests made, the\n" +
+			"responses by status code (\"none\" counts requests that got no response),\n" +
+			"and the threads and comments added (+) and updated (~).",
+		Args: cobra.NoArgs,
+		Run:  runListCommand,
+	}
+
+	listCommand.Flags().StringVar(&target, "target", "", "Only list scrapes of targets containing this text")
+	listCommand.Flags().IntVar(&sinceDays, "since-days", 0, "Only list scrapes started in the last since-days days")
+	listCommand.Flags().BoolVar(&failed, "failed", false, "Only list scrapes that failed")
+	listCommand.Flags().UintVar(&limit, "limit", 50, "Maximum number of scrapes to list")
+
+	return listCommand
+}
+
+func runListCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var runs []database.ScrapeRun
+
+	var since time.Time
+	if sinceDays > 0 {
+		since = time.Now().AddDate(0, 0, -sinceDays)
+	}
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if runs, err = sdb.ScrapeRuns(target, since, failed, limit); err == nil && !output.IsText() {
+			records := make([]output.ScrapeRun, 0, len(runs))
+			for _, run := range runs {
+				records = append(records, newRecord(run))
+			}
+			err = output.Print(records)
+		} else if err == nil {
+			for _, run := range runs {
+				status := "ok"
+				if run.Finished.IsZero() {
+					status = "running"
+				} else if run.Error != "" {
+					status = "failed"
+				}
+				statuses := output.FormatStatuses(run.Statuses)
+				if run.FailedRequests > 0 {
+					statuses = strings.TrimSpace(fmt.Sprintf("%s none:%d", statuses, run.FailedRequests))
+				}
+				fmt.Printf("%d: %s %-7s %8s %5d reqs [%s] +%d/~%d threads +%d/~%d comments %s\n",
+					run.Id, run.Started.Format(time.DateTime), status, run.Duration().Round(time.Second),
+					run.Requests, statuses,
+					run.NewThreads, run.UpdatedThreads, run.NewComments, run.UpdatedComments, run.Target)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
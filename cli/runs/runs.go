@@ -0,0 +1,43 @@
+package runs
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+func NewCommand() *cobra.Command {
+	runsCommand := &cobra.Command{
+		Use:   "runs",
+		Short: "Commands for the history of scrapes",
+		Example: "  # Lists last week's failed scrapes of a site\n" +
+			"  " + os.Args[0] + " runs list --failed --since-days 7 --target forum.example.com",
+	}
+
+	runsCommand.AddCommand(initListCommand())
+	runsCommand.AddCommand(initShowCommand())
+
+	return runsCommand
+}
+
+func newRecord(run database.ScrapeRun) output.ScrapeRun {
+	return output.ScrapeRun{
+		Id:              uint(run.Id),
+		Target:          run.Target,
+		Cutoff:          run.Cutoff,
+		Started:         run.Started,
+		Finished:        run.Finished,
+		DurationSeconds: run.Duration().Seconds(),
+		Requests:        run.Requests,
+		FailedRequests:  run.FailedRequests,
+		Statuses:        run.Statuses,
+		Bytes:           run.Bytes,
+		NewThreads:      run.NewThreads,
+		UpdatedThreads:  run.UpdatedThreads,
+		NewComments:     run.NewComments,
+		UpdatedComments: run.UpdatedComments,
+		Error:           run.Error,
+	}
+}
@@ -0,0 +1,66 @@
+package runs
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+func initShowCommand() *cobra.Command {
+	showCommand := &cobra.Command{
+		Use:   "show <id>",
+		Short: "Prints the details of a scrape",
+		Args:  cobra.ExactArgs(1),
+		Run:   runShowCommand,
+	}
+	return showCommand
+}
+
+func runShowCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var run database.ScrapeRun
+
+	id, err := strconv.ParseUint(args[0], 10, 64)
+	if err != nil {
+		log.Fatalf("Bad run id %q", args[0])
+	}
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if run, err = sdb.GetScrapeRun(database.ScrapeRunID(id)); err == nil && !output.IsText() {
+			err = output.Print([]output.ScrapeRun{newRecord(run)})
+		} else if err == nil {
+			fmt.Printf("Run:      %d\n", run.Id)
+			fmt.Printf("Target:   %s\n", run.Target)
+			if !run.Cutoff.IsZero() {
+				fmt.Printf("Cutoff:   %s\n", run.Cutoff.Format(time.RFC3339))
+			}
+			fmt.Printf("Started:  %s\n", run.Started.Format(time.RFC3339))
+			if run.Finished.IsZero() {
+				fmt.Println("Finished: still running")
+			} else {
+				fmt.Printf("Finished: %s (%s)\n", run.Finished.Format(time.RFC3339), run.Duration())
+			}
+			fmt.Printf("Requests: %d, %d without a response, %d bytes read\n", run.Requests, run.FailedRequests, run.Bytes)
+			if len(run.Statuses) > 0 {
+				fmt.Printf("Statuses: %s\n", output.FormatStatuses(run.Statuses))
+			}
+			fmt.Printf("Threads:  %d new, %d updated\n", run.NewThreads, run.UpdatedThreads)
+			fmt.Printf("Comments: %d new, %d updated\n", run.NewComments, run.UpdatedComments)
+			if run.Error != "" {
+				fmt.Printf("Error:    %s\n", run.Error)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
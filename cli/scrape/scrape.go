@@ -5,46 +5,56 @@ import (
 	"log"
 	"net/url"
 	"os"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/zvonler/espy/database"
-	"github.com/zvonler/espy/model"
-	"github.com/zvonler/espy/reddit"
-	"github.com/zvonler/espy/xf_scraper"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/alerts"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/history"
 )
 
 var (
 	lookbackDays int
-	dbPath       string
 	noChanges    bool
+	resume       bool
 )
 
 func NewCommand() *cobra.Command {
 	scrapeCommand := &cobra.Command{
 		Use:   "scrape <URL>",
 		Short: "Scrape forums and threads",
-		Args:  cobra.ExactArgs(1),
+		Args: func(cmd *cobra.Command, args []string) error {
+			if resume {
+				return cobra.MaximumNArgs(1)(cmd, args)
+			}
+			return cobra.ExactArgs(1)(cmd, args)
+		},
 		Example: "" +
-			"  " + os.Args[0] + " scrape https://site.com/forum-url",
+			"  " + os.Args[0] + " scrape https://site.com/forum-url\n" +
+			"  " + os.Args[0] + " scrape --resume",
 		Run: runScrapeCommand,
 	}
 
 	scrapeCommand.Flags().IntVar(&lookbackDays, "lookback-days", 7, "Ignore activity earlier than lookback-days before now")
-	scrapeCommand.Flags().StringVar(&dbPath, "database", "espy.db", "Database filename")
 	scrapeCommand.Flags().BoolVar(&noChanges, "no-changes", false, "Make no changes to the database")
+	scrapeCommand.Flags().BoolVar(&resume, "resume", false, "Continue the latest unfinished forum scrape, of URL if given")
 
 	return scrapeCommand
 }
 
 func runScrapeCommand(cmd *cobra.Command, args []string) {
+	if resume {
+		resumeCrawl(args)
+		return
+	}
+
 	url, err := url.Parse(args[0])
 	if err != nil {
 		log.Fatalf("Bad URL: %v", err)
 	}
 
-	sdb, err := database.OpenScraperDB(dbPath)
+	sdb, err := configuration.OpenDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
@@ -52,32 +62,78 @@ func runScrapeCommand(cmd *cobra.Command, args []string) {
 
 	cutoff := time.Now().AddDate(0, 0, -lookbackDays)
 
-	if strings.Contains(url.Host, "reddit.com") {
-		fs := reddit.NewForumScraper(url)
-		fs.LoadThreadsWithActivitySince(sdb, cutoff)
-	} else if strings.Contains(url.Path, "/forums/") {
-		fs := xf_scraper.NewForumScraper(url)
-		fs.LoadThreadsWithActivitySince(sdb, cutoff, true)
-	} else if strings.Contains(url.Path, "/threads/") {
-		// If url already in thread table, create ThreadScraper
+	siteAdapter, kind, err := adapter.ForURL(url)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	if kind == adapter.ForumURL {
+		if _, err = history.Record(sdb, url.String(), url.Hostname(), cutoff, func() error {
+			return siteAdapter.ScrapeForum(sdb, url, cutoff, true)
+		}); err != nil {
+			log.Fatal(err)
+		}
+	} else if kind == adapter.ThreadURL {
+		// If url already in thread table, scrape its comments
 		if thread, err := sdb.GetThreadByURL(url); err == nil {
-			xfThread := xf_scraper.XFThread{model.Thread{URL: thread.URL}}
-			ts := xf_scraper.NewThreadScraper(thread.Id, xfThread)
-			ts.LoadCommentsSince(sdb, cutoff)
-			comments := make([]model.Comment, len(ts.Comments), len(ts.Comments))
-			for i := range ts.Comments {
-				comments[i] = ts.Comments[i].Comment
-			}
-			if !noChanges {
-				sdb.AddComments(thread.SiteId, thread.Id, comments)
-			} else {
+			if noChanges {
+				comments, err := siteAdapter.ScrapeThread(sdb, thread, cutoff)
+				if err != nil {
+					log.Fatal(err)
+				}
 				for _, c := range comments {
 					fmt.Println(c.URL.String())
 				}
+			} else if _, err = history.Record(sdb, url.String(), url.Hostname(), cutoff, func() error {
+				return adapter.StoreThread(sdb, siteAdapter, thread, cutoff)
+			}); err != nil {
+				log.Fatal(err)
 			}
 		} else {
 			// Else get forum from thread page?
 			panic("Can't load new thread without forum and site\n")
 		}
 	}
+
+	if !noChanges {
+		alerts.RunAll(sdb)
+	}
+}
+
+func resumeCrawl(args []string) {
+	var crawlURL *url.URL
+	if len(args) == 1 {
+		var err error
+		if crawlURL, err = url.Parse(args[0]); err != nil {
+			log.Fatalf("Bad URL: %v", err)
+		}
+	}
+
+	sdb, err := configuration.OpenDatabase()
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer sdb.Close()
+
+	crawl, err := sdb.FindUnfinishedCrawl(crawlURL)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	siteAdapter, _, err := adapter.ForURL(crawl.URL)
+	if err != nil {
+		log.Fatal(err)
+	}
+	resumer, ok := siteAdapter.(adapter.Resumer)
+	if !ok {
+		log.Fatalf("The %s adapter cannot resume scrapes", siteAdapter.Name())
+	}
+
+	fmt.Printf("Resuming crawl %d of %s started %s\n", crawl.Id, crawl.URL, crawl.Started.Format(time.RFC3339))
+	if _, err = history.Record(sdb, crawl.URL.String(), crawl.URL.Hostname(), crawl.Cutoff, func() error {
+		return resumer.ResumeCrawl(sdb, crawl)
+	}); err != nil {
+		log.Fatal(err)
+	}
+	alerts.RunAll(sdb)
 }
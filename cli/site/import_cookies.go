@@ -0,0 +1,73 @@
+package site
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/session"
+)
+
+func initImportCookiesCommand() *cobra.Command {
+	importCookiesCommand := &cobra.Command{
+		Use:   "import-cookies <hostname> <cookies.txt>",
+		Short: "Stores a site's cookies from a browser export so scrapes use its session",
+		Long: "" +
+			"Stores the cookies for hostname from a file in the Netscape cookies.txt\n" +
+			"format, as exported from a browser where you are signed in to the site.\n" +
+			"Later scrapes of the site send them, keeping any the site renews.",
+		Args: cobra.ExactArgs(2),
+		Run:  runImportCookiesCommand,
+	}
+	return importCookiesCommand
+}
+
+func runImportCookiesCommand(cmd *cobra.Command, args []string) {
+	hostname, path := args[0], args[1]
+
+	var err error
+	var file *os.File
+	var sdb *database.ScraperDB
+	var imported, stored []*http.Cookie
+
+	if file, err = os.Open(path); err == nil {
+		defer file.Close()
+		if imported, err = session.ReadCookieFile(file); err == nil {
+			if imported = session.CookiesForHost(imported, hostname); len(imported) == 0 {
+				err = fmt.Errorf("No cookies for %s in %s", hostname, path)
+			}
+		}
+	}
+	if err == nil {
+		if sdb, err = configuration.OpenDatabase(); err == nil {
+			defer sdb.Close()
+			if stored, _, err = sdb.GetSessionCookies(hostname); err == nil {
+				err = sdb.SetSessionCookies(hostname, mergeCookies(stored, imported))
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+	fmt.Printf("Imported %d cookies for %s\n", len(imported), hostname)
+}
+
+// Returns the stored cookies with those imported replacing any of the same
+// name.
+func mergeCookies(stored, imported []*http.Cookie) (merged []*http.Cookie) {
+	replaced := make(map[string]bool)
+	for _, c := range imported {
+		replaced[c.Name] = true
+	}
+	for _, c := range stored {
+		if !replaced[c.Name] {
+			merged = append(merged, c)
+		}
+	}
+	return append(merged, imported...)
+}
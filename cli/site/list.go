@@ -4,9 +4,12 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 
 	"github.com/spf13/cobra"
-	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
 )
 
 func initListCommand() *cobra.Command {
@@ -15,19 +18,25 @@ func initListCommand() *cobra.Command {
 		Short: "Lists sites in the database",
 		Run:   runListCommand,
 	}
-
-	listCommand.Flags().StringVar(&dbPath, "database", "espy.db", "Database filename")
 	return listCommand
 }
 
 func runListCommand(cmd *cobra.Command, args []string) {
-	sdb, err := database.OpenScraperDB(dbPath)
+	sdb, err := configuration.OpenExistingDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer sdb.Close()
 
-	if sitesById, err := sdb.GetSites(); err == nil {
+	var sitesById map[model.SiteID]string
+	if sitesById, err = sdb.GetSites(); err == nil && !output.IsText() {
+		records := make([]output.Site, 0, len(sitesById))
+		for id, hostname := range sitesById {
+			records = append(records, output.Site{Id: id, Hostname: hostname})
+		}
+		sort.Slice(records, func(i, j int) bool { return records[i].Id < records[j].Id })
+		err = output.Print(records)
+	} else if err == nil {
 		colWidth := uint(math.Round(math.Ceil(math.Log10(float64(len(sitesById))))))
 		fmtString := fmt.Sprintf("%%0%dd: %%s\n", colWidth)
 		for id, hostname := range sitesById {
@@ -4,16 +4,13 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var (
-	dbPath string
-)
-
 func NewCommand() *cobra.Command {
 	siteCommand := &cobra.Command{
 		Use:   "site",
 		Short: "Commands for working with sites",
 	}
 
+	siteCommand.AddCommand(initImportCookiesCommand())
 	siteCommand.AddCommand(initListCommand())
 	siteCommand.AddCommand(initUpdateCommand())
 
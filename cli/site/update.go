@@ -1,88 +1,116 @@
 package site
 
 import (
-	"database/sql"
 	"fmt"
 	"log"
-	"net/url"
 	"regexp"
+	"sort"
 	"strconv"
-	"strings"
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/alerts"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/history"
 	"github.com/zvonler/espy/model"
-	"github.com/zvonler/espy/reddit"
-	"github.com/zvonler/espy/xf_scraper"
+	"github.com/zvonler/espy/scheduler"
 )
 
 var (
 	lookbackDays int
+	concurrency  int
 )
 
 func initUpdateCommand() *cobra.Command {
 	updateCommand := &cobra.Command{
-		Use:   "update",
-		Short: "Updates all known forums at a site",
-		Run:   runUpdateCommand,
+		Use:   "update [site...]",
+		Short: "Updates all known forums at sites, or at every site if none are given",
+		Long: "" +
+			"Updates all known forums at the given sites, identified by id or hostname.\n" +
+			"Different sites are scraped concurrently; requests to each host are limited\n" +
+			"by --request-interval, --request-jitter, --max-in-flight and --host-limit.",
+		Run: runUpdateCommand,
 	}
 
-	updateCommand.Flags().StringVar(&dbPath, "database", "espy.db", "Database filename")
 	updateCommand.Flags().IntVar(&lookbackDays, "lookback-days", 7, "Ignore activity earlier than lookback-days before now")
+	updateCommand.Flags().IntVar(&concurrency, "concurrency", 4, "Maximum number of sites scraped at once")
 
 	return updateCommand
 }
 
 func runUpdateCommand(cmd *cobra.Command, args []string) {
-	sdb, err := database.OpenScraperDB(dbPath)
+	sdb, err := configuration.OpenDatabase()
 	if err != nil {
 		log.Fatal(err)
 	}
 	defer sdb.Close()
 
-	var siteId model.SiteID
+	hostnamesById, err := sdb.GetSites()
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	var siteIds []model.SiteID
+	if len(args) == 0 {
+		for siteId := range hostnamesById {
+			siteIds = append(siteIds, siteId)
+		}
+		sort.Slice(siteIds, func(i, j int) bool { return siteIds[i] < siteIds[j] })
+	}
 
 	var digitCheck = regexp.MustCompile(`^[0-9]+$`)
-	if digitCheck.MatchString(args[0]) {
-		id, err := strconv.Atoi(args[0])
-		if err != nil {
-			panic(err)
+	for _, arg := range args {
+		var siteId model.SiteID
+		if digitCheck.MatchString(arg) {
+			id, err := strconv.Atoi(arg)
+			if err != nil {
+				panic(err)
+			} else {
+				siteId = model.SiteID(id)
+			}
 		} else {
-			siteId = model.SiteID(id)
-		}
-	} else {
-		siteId, err = sdb.GetSiteId(args[0])
-		if err != nil {
-			log.Fatal(err)
+			siteId, err = sdb.GetSiteId(arg)
+			if err != nil {
+				log.Fatal(err)
+			}
 		}
+		siteIds = append(siteIds, siteId)
 	}
 
 	cutoff := time.Now().AddDate(0, 0, -lookbackDays)
 
-	var urls []*url.URL
-
-	stmt := "SELECT url FROM forum WHERE site_id = ?"
-	sdb.ForEachRowOrPanic(
-		func(rows *sql.Rows) {
-			var urlStr string
-			rows.Scan(&urlStr)
-			if url, err := url.Parse(urlStr); err != nil {
-				log.Fatal(err)
+	var jobs []scheduler.Job
+	for _, siteId := range siteIds {
+		urls, err := sdb.GetSiteForumURLs(siteId)
+		if err != nil {
+			log.Fatal(err)
+		}
+		for _, url := range urls {
+			url := url
+			if siteAdapter, kind, err := adapter.ForURL(url); err != nil || kind != adapter.ForumURL {
+				fmt.Printf("Skipping %s: no forum adapter\n", url)
 			} else {
-				urls = append(urls, url)
+				hostname := hostnamesById[siteId]
+				jobs = append(jobs, scheduler.Job{
+					Host: hostname,
+					Name: url.String(),
+					Run: func() error {
+						_, err := history.Record(sdb, url.String(), hostname, cutoff, func() error {
+							return siteAdapter.ScrapeForum(sdb, url, cutoff, false)
+						})
+						return err
+					},
+				})
 			}
-		},
-		stmt, siteId)
+		}
+	}
 
-	for i, url := range urls {
-		fmt.Printf("%d: %s\n", i, url)
-		if strings.Contains(url.Host, "reddit.com") {
-			fs := reddit.NewForumScraper(url)
-			fs.LoadThreadsWithActivitySince(sdb, cutoff)
-		} else if strings.Contains(url.Path, "/forums/") {
-			fs := xf_scraper.NewForumScraper(url)
-			fs.LoadThreadsWithActivitySince(sdb, cutoff, false)
+	for _, result := range scheduler.Run(jobs, concurrency) {
+		if result.Err != nil {
+			log.Printf("Failed to scrape %s: %v\n", result.Job.Name, result.Err)
 		}
 	}
+
+	alerts.RunAll(sdb)
 }
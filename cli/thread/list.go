@@ -4,11 +4,13 @@ import (
 	"fmt"
 	"log"
 	"math"
+	"sort"
 
 	"github.com/spf13/cobra"
 	"github.com/zvonler/espy/configuration"
 	"github.com/zvonler/espy/database"
 	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
 )
 
 func initListCommand() *cobra.Command {
@@ -28,7 +30,14 @@ func runListCommand(cmd *cobra.Command, args []string) {
 	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
 		defer sdb.Close()
 
-		if threadsById, err = sdb.GetThreads([]model.ThreadID{}); err == nil {
+		if threadsById, err = sdb.GetThreads([]model.ThreadID{}); err == nil && !output.IsText() {
+			records := make([]output.Thread, 0, len(threadsById))
+			for _, thread := range threadsById {
+				records = append(records, output.NewThread(thread))
+			}
+			sort.Slice(records, func(i, j int) bool { return records[i].Id < records[j].Id })
+			err = output.Print(records)
+		} else if err == nil {
 			colWidth := uint(math.Round(math.Ceil(math.Log10(float64(len(threadsById))))))
 			fmtString := fmt.Sprintf("%%0%dd: %%s (%%s)\n", colWidth)
 			for id, thread := range threadsById {
@@ -0,0 +1,57 @@
+package thread
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
+)
+
+func initMediaCommand() *cobra.Command {
+	mediaCommand := &cobra.Command{
+		Use:   "media <thread_id | thread_URL>",
+		Short: "Lists the images, videos, embeds and attachments posted in a thread",
+		Args:  cobra.ExactArgs(1),
+		Run:   runMediaCommand,
+	}
+	return mediaCommand
+}
+
+func runMediaCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var thread model.Thread
+	var media []database.CommentMedia
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if thread, err = sdb.FindThread(args[0]); err == nil {
+			if media, err = sdb.ThreadMedia(thread.Id); err == nil && !output.IsText() {
+				records := make([]output.Media, len(media))
+				for i, m := range media {
+					records[i] = output.Media{
+						Comment:   m.Comment.String(),
+						Author:    m.Author,
+						Published: m.Published.UTC(),
+						Kind:      string(m.Kind),
+						URL:       m.URL.String(),
+						Text:      m.Text,
+					}
+				}
+				err = output.Print(records)
+			} else if err == nil {
+				for _, m := range media {
+					fmt.Printf("%-10s %s %s (%s)\n", m.Kind, m.URL, m.Author, m.Comment)
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
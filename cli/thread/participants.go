@@ -8,6 +8,7 @@ import (
 	"github.com/zvonler/espy/configuration"
 	"github.com/zvonler/espy/database"
 	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
 )
 
 func initParticipantsCommand() *cobra.Command {
@@ -29,7 +30,13 @@ func runParticipantsCommand(cmd *cobra.Command, args []string) {
 	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
 		defer sdb.Close()
 		if thread, err = sdb.FindThread(args[0]); err == nil {
-			if usernames, err = sdb.ThreadParticipants(thread.Id); err == nil {
+			if usernames, err = sdb.ThreadParticipants(thread.Id); err == nil && !output.IsText() {
+				records := make([]output.Participant, len(usernames))
+				for i, username := range usernames {
+					records[i] = output.Participant{Username: username}
+				}
+				err = output.Print(records)
+			} else if err == nil {
 				for _, username := range usernames {
 					fmt.Println(username)
 				}
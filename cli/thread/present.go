@@ -11,6 +11,7 @@ import (
 	"github.com/zvonler/espy/configuration"
 	"github.com/zvonler/espy/database"
 	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
 	"golang.org/x/term"
 )
 
@@ -80,7 +81,9 @@ func runPresentCommand(cmd *cobra.Command, args []string) {
 		defer sdb.Close()
 		if thread, err = sdb.FindThread(args[0]); err == nil {
 			if comments, err = sdb.ThreadComments(thread.Id); err == nil {
-				if isTty {
+				if !output.IsText() {
+					err = output.Print(output.NewComments(comments))
+				} else if isTty {
 					paginateComments(thread, comments)
 				} else {
 					printComments(thread, comments)
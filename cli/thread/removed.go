@@ -0,0 +1,39 @@
+package thread
+
+import (
+	"log"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/cli/comment"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+func initRemovedCommand() *cobra.Command {
+	removedCommand := &cobra.Command{
+		Use:   "removed <thread_id | thread_URL>",
+		Short: "Lists comments that disappeared from a thread when it was re-scraped",
+		Args:  cobra.ExactArgs(1),
+		Run:   runRemovedCommand,
+	}
+	return removedCommand
+}
+
+func runRemovedCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var thread model.Thread
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if thread, err = sdb.FindThread(args[0]); err == nil {
+			err = comment.PrintRemovedComments(sdb, thread.Id, time.Time{})
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,70 @@
+package thread
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
+)
+
+var (
+	dot bool
+)
+
+func initRepliesGraphCommand() *cobra.Command {
+	repliesGraphCommand := &cobra.Command{
+		Use:   "replies-graph <thread_id | thread_URL>",
+		Short: "Shows who quoted whom in a thread",
+		Args:  cobra.ExactArgs(1),
+		Run:   runRepliesGraphCommand,
+	}
+
+	repliesGraphCommand.Flags().BoolVar(&dot, "dot", false, "Print the graph in Graphviz DOT format")
+
+	return repliesGraphCommand
+}
+
+func runRepliesGraphCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var thread model.Thread
+	var edges []database.ReplyEdge
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if thread, err = sdb.FindThread(args[0]); err == nil {
+			if edges, err = sdb.ThreadReplyEdges(thread.Id); err == nil {
+				if dot {
+					printDot(thread, edges)
+				} else if !output.IsText() {
+					records := make([]output.ReplyEdge, len(edges))
+					for i, e := range edges {
+						records[i] = output.ReplyEdge{From: e.From, To: e.To, Count: e.Count}
+					}
+					err = output.Print(records)
+				} else {
+					for _, e := range edges {
+						fmt.Printf("%s -> %s (%d)\n", e.From, e.To, e.Count)
+					}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func printDot(thread model.Thread, edges []database.ReplyEdge) {
+	fmt.Printf("digraph %s {\n", strconv.Quote(thread.Title))
+	for _, e := range edges {
+		fmt.Printf("\t%s -> %s [weight=%d, label=%d];\n", strconv.Quote(e.From), strconv.Quote(e.To), e.Count, e.Count)
+	}
+	fmt.Println("}")
+}
@@ -5,10 +5,9 @@ import (
 	"time"
 
 	"github.com/spf13/cobra"
-	"github.com/spf13/viper"
-	"github.com/zvonler/espy/database"
-	"github.com/zvonler/espy/model"
-	"github.com/zvonler/espy/xf_scraper"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/history"
 )
 
 var (
@@ -30,18 +29,16 @@ func initScrapeCommand() *cobra.Command {
 
 func runScrapeCommand(cmd *cobra.Command, args []string) {
 	cutoff := time.Now().AddDate(0, 0, -lookbackDays)
-	dbPath := viper.GetString("database")
-	if sdb, err := database.OpenScraperDB(dbPath); err == nil {
+	if sdb, err := configuration.OpenDatabase(); err == nil {
 		defer sdb.Close()
 		if thread, err := sdb.FindThread(args[0]); err == nil {
-			xfThread := xf_scraper.XFThread{model.Thread{URL: thread.URL}}
-			ts := xf_scraper.NewThreadScraper(thread.Id, xfThread)
-			ts.LoadCommentsSince(sdb, cutoff)
-			comments := make([]model.Comment, len(ts.Comments), len(ts.Comments))
-			for i := range ts.Comments {
-				comments[i] = ts.Comments[i].Comment
+			if siteAdapter, _, err := adapter.ForURL(thread.URL); err != nil {
+				log.Fatal(err)
+			} else if _, err = history.Record(sdb, thread.URL.String(), thread.URL.Hostname(), cutoff, func() error {
+				return adapter.StoreThread(sdb, siteAdapter, thread, cutoff)
+			}); err != nil {
+				log.Fatal(err)
 			}
-			sdb.AddComments(thread.SiteId, thread.Id, comments)
 		}
 	} else {
 		log.Fatal(err)
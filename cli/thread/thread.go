@@ -23,11 +23,15 @@ func NewCommand() *cobra.Command {
 	threadCommand.AddCommand(initContentCommand())
 	threadCommand.AddCommand(initGrepCommand())
 	threadCommand.AddCommand(initListCommand())
+	threadCommand.AddCommand(initMediaCommand())
 	threadCommand.AddCommand(initOpenCommand())
 	threadCommand.AddCommand(initParticipantsCommand())
 	threadCommand.AddCommand(initPresentCommand())
+	threadCommand.AddCommand(initRemovedCommand())
+	threadCommand.AddCommand(initRepliesGraphCommand())
 	threadCommand.AddCommand(initScrapeCommand())
 	threadCommand.AddCommand(initTagCommand())
+	threadCommand.AddCommand(initTopCommand())
 	threadCommand.AddCommand(initWordcloudCommand())
 
 	return threadCommand
@@ -0,0 +1,98 @@
+package thread
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/output"
+)
+
+var (
+	topBy    string
+	topLimit uint
+)
+
+func initTopCommand() *cobra.Command {
+	topCommand := &cobra.Command{
+		Use:   "top <thread_id | thread_URL>",
+		Short: "Lists a thread's highest scored or most reacted to comments",
+		Args:  cobra.ExactArgs(1),
+		Example: "" +
+			"  " + os.Args[0] + " thread top 42 --by reactions",
+		Run: runTopCommand,
+	}
+
+	topCommand.Flags().StringVar(&topBy, "by", string(database.ByScore), "Rank comments by score or reactions")
+	topCommand.Flags().UintVar(&topLimit, "limit", 10, "Maximum number of comments to list")
+
+	return topCommand
+}
+
+func runTopCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var by database.Ranking
+	var thread model.Thread
+	var comments []database.RankedComment
+
+	if by, err = database.ParseRanking(topBy); err == nil {
+		if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+			defer sdb.Close()
+			if thread, err = sdb.FindThread(args[0]); err == nil {
+				comments, err = sdb.TopComments(thread.Id, by, topLimit)
+			}
+		}
+	}
+
+	if err == nil {
+		if !output.IsText() {
+			records := make([]output.RankedComment, len(comments))
+			for i, c := range comments {
+				records[i] = output.RankedComment{
+					URL:           c.URL.String(),
+					Author:        c.Author,
+					Published:     c.Published.UTC(),
+					Score:         c.Score,
+					ReactionCount: c.ReactionCount,
+					Reactions:     reactionStrings(c.Reactions),
+				}
+			}
+			err = output.Print(records)
+		} else {
+			for _, c := range comments {
+				var value string
+				if by == database.ByReactions {
+					value = fmt.Sprintf("%d", *c.ReactionCount)
+					if len(c.Reactions) > 0 {
+						value += " (" + strings.Join(reactionStrings(c.Reactions), ", ") + ")"
+					}
+				} else {
+					value = fmt.Sprintf("%d", *c.Score)
+				}
+				fmt.Printf("%s %s %s\n", value, c.Author, c.URL)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+// Formats reactions as their kind, followed by the count when it's known.
+func reactionStrings(reactions []model.Reaction) (strs []string) {
+	for _, r := range reactions {
+		if r.Count > 0 {
+			strs = append(strs, fmt.Sprintf("%s:%d", r.Kind, r.Count))
+		} else {
+			strs = append(strs, r.Kind)
+		}
+	}
+	return
+}
@@ -24,7 +24,7 @@ import (
 
 var path = flag.String("input", "input.yaml", "path to flat YAML like {\"word\":42,...}")
 var config = flag.String("config", "config.yaml", "path to config file")
-var output = flag.String("output", "output.png", "path to output image")
+var outputPath = flag.String("output", "output.png", "path to output image")
 
 var DefaultColors = []color.RGBA{
 	{0x1b, 0x1b, 0x1b, 0xff},
@@ -206,7 +206,7 @@ func runWordcloudCommand(cmd *cobra.Command, args []string) {
 	)
 
 	img := w.Draw()
-	outputFile, err := os.Create(*output)
+	outputFile, err := os.Create(*outputPath)
 	if err != nil {
 		panic(err)
 	}
@@ -0,0 +1,91 @@
+package watch
+
+import (
+	"fmt"
+	"log"
+	"strconv"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/alerts"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+var (
+	search bool
+	site   string
+	forum  string
+	thread string
+	author string
+	tag    string
+	sink   string
+)
+
+func initAddCommand() *cobra.Command {
+	addCommand := &cobra.Command{
+		Use:   "add <name> <query>",
+		Short: "Adds a watch for comments scraped from now on",
+		Long: "Adds a watch whose query is a regular expression, or a full-text search with --search.\n" +
+			"Sinks are stdout, jsonl:<path>, webhook:<URL> or smtp:<address>[,<address>...].",
+		Args: cobra.ExactArgs(2),
+		Run:  runAddCommand,
+	}
+
+	addCommand.Flags().BoolVar(&search, "search", false, "Treat the query as a full-text search")
+	addCommand.Flags().StringVar(&site, "site", "", "Only match comments from the site with this hostname")
+	addCommand.Flags().StringVar(&forum, "forum", "", "Only match comments in the forum with this id or URL")
+	addCommand.Flags().StringVar(&thread, "thread", "", "Only match comments in the thread with this id or URL")
+	addCommand.Flags().StringVar(&author, "author", "", "Only match comments by this author or their aliases")
+	addCommand.Flags().StringVar(&tag, "tag", "", "Only match comments in threads with this tag")
+	addCommand.Flags().StringVar(&sink, "sink", "stdout", "Where to send matches")
+
+	return addCommand
+}
+
+func runAddCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+
+	w := database.Watch{Name: args[0], Kind: database.RegexWatch, Query: args[1],
+		Site: site, Author: author, Tag: tag, Sink: sink}
+	if search {
+		w.Kind = database.SearchWatch
+	}
+
+	if _, err = alerts.ParseSink(sink); err == nil {
+		if sdb, err = configuration.OpenDatabase(); err == nil {
+			defer sdb.Close()
+			if w.ForumId, err = findForum(sdb, forum); err == nil && thread != "" {
+				var t model.Thread
+				if t, err = sdb.FindThread(thread); err == nil {
+					w.ThreadId = t.Id
+				}
+			}
+			if err == nil {
+				_, err = sdb.AddWatch(w)
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func findForum(sdb *database.ScraperDB, arg string) (forumId model.ForumID, err error) {
+	if arg == "" {
+		return
+	}
+	var forums []model.Forum
+	if forums, err = sdb.GetForums(); err == nil {
+		id, _ := strconv.ParseUint(arg, 10, 64)
+		for _, f := range forums {
+			if uint64(f.Id) == id || f.URL.String() == arg {
+				return f.Id, nil
+			}
+		}
+		err = fmt.Errorf("No forum matches %q", arg)
+	}
+	return
+}
@@ -0,0 +1,72 @@
+package watch
+
+import (
+	"fmt"
+	"log"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+func initListCommand() *cobra.Command {
+	listCommand := &cobra.Command{
+		Use:   "list",
+		Short: "Lists watches",
+		Args:  cobra.NoArgs,
+		Run:   runListCommand,
+	}
+	return listCommand
+}
+
+func runListCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var watches []database.Watch
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if watches, err = sdb.Watches(); err == nil && !output.IsText() {
+			records := make([]output.Watch, 0, len(watches))
+			for _, w := range watches {
+				records = append(records, output.Watch{Name: w.Name, Kind: string(w.Kind), Query: w.Query,
+					Scope: scope(w), Sink: w.Sink, HighWater: uint(w.HighWater), Created: w.Created})
+			}
+			err = output.Print(records)
+		} else if err == nil {
+			for _, w := range watches {
+				fmt.Printf("%s: %s %q -> %s", w.Name, w.Kind, w.Query, w.Sink)
+				if s := scope(w); s != "" {
+					fmt.Printf(" (%s)", s)
+				}
+				fmt.Println()
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
+
+func scope(w database.Watch) string {
+	var limits []string
+	if w.Site != "" {
+		limits = append(limits, "site "+w.Site)
+	}
+	if w.ForumId != 0 {
+		limits = append(limits, fmt.Sprintf("forum %d", w.ForumId))
+	}
+	if w.ThreadId != 0 {
+		limits = append(limits, fmt.Sprintf("thread %d", w.ThreadId))
+	}
+	if w.Author != "" {
+		limits = append(limits, "author "+w.Author)
+	}
+	if w.Tag != "" {
+		limits = append(limits, "tag "+w.Tag)
+	}
+	return strings.Join(limits, ", ")
+}
@@ -0,0 +1,37 @@
+package watch
+
+import (
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+)
+
+func initRmCommand() *cobra.Command {
+	rmCommand := &cobra.Command{
+		Use:   "rm <name>...",
+		Short: "Removes watches",
+		Args:  cobra.MinimumNArgs(1),
+		Run:   runRmCommand,
+	}
+	return rmCommand
+}
+
+func runRmCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		for _, name := range args {
+			if err = sdb.RemoveWatch(name); err != nil {
+				break
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,57 @@
+package watch
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/spf13/cobra"
+	"github.com/zvonler/espy/alerts"
+	"github.com/zvonler/espy/configuration"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/output"
+)
+
+func initRunCommand() *cobra.Command {
+	runCommand := &cobra.Command{
+		Use:   "run [name...]",
+		Short: "Sends new matches for the named watches, or all of them",
+		Run:   runRunCommand,
+	}
+	return runCommand
+}
+
+func runRunCommand(cmd *cobra.Command, args []string) {
+	var err error
+	var sdb *database.ScraperDB
+	var watches []database.Watch
+
+	if sdb, err = configuration.OpenExistingDatabase(); err == nil {
+		defer sdb.Close()
+		if watches, err = sdb.Watches(args...); err == nil {
+			results := alerts.Run(sdb, watches)
+			if !output.IsText() {
+				records := make([]output.WatchResult, 0, len(results))
+				for _, r := range results {
+					record := output.WatchResult{Watch: r.Watch.Name, Matches: r.Matches}
+					if r.Err != nil {
+						record.Error = r.Err.Error()
+					}
+					records = append(records, record)
+				}
+				err = output.Print(records)
+			} else {
+				for _, r := range results {
+					if r.Err != nil {
+						fmt.Printf("%s: %v\n", r.Watch.Name, r.Err)
+					} else {
+						fmt.Printf("%s: %d new matches sent to %s\n", r.Watch.Name, r.Matches, r.Watch.Sink)
+					}
+				}
+			}
+		}
+	}
+
+	if err != nil {
+		log.Fatal(err)
+	}
+}
@@ -0,0 +1,23 @@
+package watch
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func NewCommand() *cobra.Command {
+	watchCommand := &cobra.Command{
+		Use:   "watch",
+		Short: "Commands for alerting on new comments that match saved queries",
+		Example: "  # Posts new comments mentioning a recall to a webhook after each scrape\n" +
+			"  " + os.Args[0] + " watch add recalls 'recall|TSB' --sink webhook:https://example.com/hook",
+	}
+
+	watchCommand.AddCommand(initAddCommand())
+	watchCommand.AddCommand(initListCommand())
+	watchCommand.AddCommand(initRmCommand())
+	watchCommand.AddCommand(initRunCommand())
+
+	return watchCommand
+}
@@ -0,0 +1,22 @@
+package configuration
+
+import (
+	"github.com/spf13/viper"
+	"github.com/zvonler/espy/alerts"
+)
+
+// Applies the settings in the config file's "smtp" section, the mail server
+// that watches with smtp sinks send through.
+func ApplyAlertConfig() (err error) {
+	for _, key := range []string{"addr", "username", "password", "from"} {
+		viper.SetDefault("smtp."+key, "")
+	}
+
+	alerts.ConfigureSMTP(alerts.SMTPConfig{
+		Addr:     viper.GetString("smtp.addr"),
+		Username: viper.GetString("smtp.username"),
+		Password: viper.GetString("smtp.password"),
+		From:     viper.GetString("smtp.from"),
+	})
+	return
+}
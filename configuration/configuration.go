@@ -2,22 +2,55 @@ package configuration
 
 import (
 	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
 
 	"github.com/spf13/viper"
 	"github.com/zvonler/espy/database"
 	"github.com/zvonler/espy/utils"
 )
 
+// Opens the configured database, creating it if needed, for commands that
+// scrape into it.
+func OpenDatabase() (sdb *database.ScraperDB, err error) {
+	if sdb, err = database.OpenScraperDB(DatabasePath()); err == nil {
+		sdb.KeepHTML = viper.GetBool("keep-html")
+	}
+	return
+}
+
 func OpenExistingDatabase() (sdb *database.ScraperDB, err error) {
-	dbPath := viper.GetString("database")
+	return openExisting(database.OpenScraperDB)
+}
+
+// Opens the configured database without applying pending migrations.
+func OpenExistingDatabaseWithoutMigrating() (sdb *database.ScraperDB, err error) {
+	return openExisting(database.OpenScraperDBWithoutMigrating)
+}
+
+func openExisting(open func(string) (*database.ScraperDB, error)) (sdb *database.ScraperDB, err error) {
+	dbPath := DatabasePath()
 
 	var exists bool
 	if exists, err = utils.PathExists(dbPath); err == nil {
 		if exists {
-			sdb, err = database.OpenScraperDB(dbPath)
+			sdb, err = open(dbPath)
 		} else {
 			err = fmt.Errorf("Database %q does not exist", dbPath)
 		}
 	}
 	return
 }
+
+// Returns the configured database filename, with a leading "~/" expanded to
+// the home directory.
+func DatabasePath() string {
+	dbPath := viper.GetString("database")
+	if rest, found := strings.CutPrefix(dbPath, "~/"); found {
+		if home, err := os.UserHomeDir(); err == nil {
+			dbPath = filepath.Join(home, rest)
+		}
+	}
+	return dbPath
+}
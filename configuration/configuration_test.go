@@ -0,0 +1,115 @@
+package configuration
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/reddit"
+	"github.com/zvonler/espy/refresh"
+	"github.com/zvonler/espy/scheduler"
+)
+
+func writeConfig(t *testing.T, contents string) (path string) {
+	path = filepath.Join(t.TempDir(), "config.yaml")
+	require.Nil(t, os.WriteFile(path, []byte(contents), 0o600))
+	t.Cleanup(viper.Reset)
+	return
+}
+
+func TestLoad(t *testing.T) {
+	path := writeConfig(t, `
+database: ~/espy.db
+request-interval: 2s
+reddit:
+  client-id: abc
+  client-secret: shh
+sites:
+  - host: forum.example.com
+    max-in-flight: 2
+    cookie: xf_user=1
+`)
+	t.Setenv("ESPY_REQUEST_INTERVAL", "4s")
+	require.Nil(t, Load(path))
+
+	// The environment overrides the file
+	require.Equal(t, 4*time.Second, viper.GetDuration("request-interval"))
+	require.Equal(t, "abc", viper.GetString("reddit.client-id"))
+	home, _ := os.UserHomeDir()
+	require.Equal(t, filepath.Join(home, "espy.db"), DatabasePath())
+
+	sites, err := Sites()
+	require.Nil(t, err)
+	require.Equal(t, 1, len(sites))
+	require.Equal(t, "forum.example.com", sites[0].Host)
+	require.Nil(t, sites[0].RequestInterval)
+	defaults := scheduler.Limits{Interval: time.Second, Jitter: time.Second, MaxInFlight: 1}
+	require.Equal(t, scheduler.Limits{Interval: time.Second, Jitter: time.Second, MaxInFlight: 2}, sites[0].limits(defaults))
+
+	settings := Settings()
+	require.Equal(t, "********", settings["reddit"].(map[string]any)["client-secret"])
+	require.Equal(t, "********", settings["sites"].([]any)[0].(map[string]any)["cookie"])
+
+	require.NotNil(t, Load(filepath.Join(t.TempDir(), "missing.yaml")))
+}
+
+func TestHostLimitsApplyToRequestHosts(t *testing.T) {
+	path := writeConfig(t, `
+reddit:
+  client-id: abc
+  client-secret: shh
+`)
+	require.Nil(t, Load(path))
+	viper.Set("host-limit", []string{"reddit.com=10s/0s/1"})
+	require.Nil(t, ApplyRedditConfig())
+	t.Cleanup(func() { reddit.Configure(reddit.Config{}) })
+	require.Nil(t, ApplyRequestLimits())
+	t.Cleanup(func() {
+		for _, h := range []string{"reddit.com", "oauth.reddit.com", "www.reddit.com"} {
+			scheduler.SetHostLimits(h, scheduler.DefaultLimits)
+		}
+	})
+
+	// Authenticated clients request the API from oauth.reddit.com
+	want := scheduler.Limits{Interval: 10 * time.Second, MaxInFlight: 1}
+	require.Equal(t, want, scheduler.LimitsFor("reddit.com"))
+	require.Equal(t, want, scheduler.LimitsFor("oauth.reddit.com"))
+}
+
+func TestSitesRequireHost(t *testing.T) {
+	path := writeConfig(t, "sites:\n  - adapter: xenforo\n")
+	require.Nil(t, Load(path))
+	_, err := Sites()
+	require.NotNil(t, err)
+}
+
+func TestSchedule(t *testing.T) {
+	path := writeConfig(t, `
+daemon:
+  schedule:
+    - site: forum.example.com
+      interval: 1h
+    - forum: https://forum.example.com/forums/vehicles.2/
+      interval: 15m
+      lookback-days: 2
+      subforums: true
+`)
+	require.Nil(t, Load(path))
+
+	targets, err := Schedule()
+	require.Nil(t, err)
+	require.Equal(t, []refresh.Target{
+		{Site: "forum.example.com", Interval: time.Hour, Lookback: 7 * 24 * time.Hour},
+		{Forum: "https://forum.example.com/forums/vehicles.2/", Interval: 15 * time.Minute,
+			Lookback: 2 * 24 * time.Hour, SubForums: true},
+	}, targets)
+	require.Equal(t, 24*time.Hour, MaxBackoff())
+
+	path = writeConfig(t, "daemon:\n  schedule:\n    - site: forum.example.com\n")
+	require.Nil(t, Load(path))
+	_, err = Schedule()
+	require.NotNil(t, err)
+}
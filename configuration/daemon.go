@@ -0,0 +1,62 @@
+package configuration
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zvonler/espy/refresh"
+)
+
+// An entry in the config file's "daemon.schedule" list, e.g.
+//
+//	daemon:
+//	  max-backoff: 12h
+//	  schedule:
+//	    - site: forum.example.com
+//	      interval: 1h
+//	    - forum: https://forum.example.com/forums/vehicles.2/
+//	      interval: 15m
+//	      lookback-days: 2
+//	      subforums: true
+//
+// A site entry scrapes every forum of the site already in the database.
+type ScheduleEntry struct {
+	Site         string
+	Forum        string
+	Interval     time.Duration
+	LookbackDays int  `mapstructure:"lookback-days"`
+	SubForums    bool `mapstructure:"subforums"`
+}
+
+// Returns the daemon's scrape targets.
+func Schedule() (targets []refresh.Target, err error) {
+	var entries []ScheduleEntry
+	if err = viper.UnmarshalKey("daemon.schedule", &entries); err != nil {
+		return nil, fmt.Errorf("Bad daemon schedule: %w", err)
+	}
+	for _, e := range entries {
+		lookbackDays := e.LookbackDays
+		if lookbackDays == 0 {
+			lookbackDays = 7
+		}
+		t := refresh.Target{
+			Site:      e.Site,
+			Forum:     e.Forum,
+			Interval:  e.Interval,
+			Lookback:  time.Duration(lookbackDays) * 24 * time.Hour,
+			SubForums: e.SubForums,
+		}
+		if err = t.Validate(); err != nil {
+			return nil, err
+		}
+		targets = append(targets, t)
+	}
+	return
+}
+
+// The longest a failing target waits before it is scraped again.
+func MaxBackoff() time.Duration {
+	viper.SetDefault("daemon.max-backoff", 24*time.Hour)
+	return viper.GetDuration("daemon.max-backoff")
+}
@@ -0,0 +1,93 @@
+package configuration
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/spf13/viper"
+	"github.com/zvonler/espy/utils"
+)
+
+// Settings come from the config file, then ESPY_* environment variables, then
+// command-line flags, each overriding the one before. Environment variables
+// are named after the setting with "." and "-" replaced by "_", e.g.
+// ESPY_REQUEST_INTERVAL or ESPY_REDDIT_CLIENT_ID.
+const envPrefix = "ESPY"
+
+// Returns the config file read when none is given, which is
+// ~/.config/espy/config.yaml unless XDG_CONFIG_HOME is set.
+func DefaultConfigPath() (path string) {
+	if dir, err := os.UserConfigDir(); err == nil {
+		path = filepath.Join(dir, "espy", "config.yaml")
+	}
+	return
+}
+
+// Reads settings from the config file at path. Without a path, the file
+// named by ESPY_CONFIG is read, or else the default config file if it exists.
+func Load(path string) (err error) {
+	viper.SetEnvPrefix(envPrefix)
+	viper.SetEnvKeyReplacer(strings.NewReplacer(".", "_", "-", "_"))
+	viper.AutomaticEnv()
+
+	if path == "" {
+		path = os.Getenv(envPrefix + "_CONFIG")
+	}
+	if path == "" {
+		path = DefaultConfigPath()
+		if exists, _ := utils.PathExists(path); !exists {
+			return
+		}
+	}
+
+	viper.SetConfigFile(path)
+	if err = viper.ReadInConfig(); err != nil {
+		err = fmt.Errorf("Reading config file %q: %w", path, err)
+	}
+	return
+}
+
+// Returns the config file settings were read from, or "" if there was none.
+func ConfigFileUsed() string {
+	return viper.ConfigFileUsed()
+}
+
+// Returns the effective settings, with secrets such as passwords and cookies
+// masked.
+func Settings() map[string]any {
+	return maskSecrets(viper.AllSettings()).(map[string]any)
+}
+
+func maskSecrets(v any) any {
+	switch v := v.(type) {
+	case map[string]any:
+		masked := make(map[string]any, len(v))
+		for key, value := range v {
+			if isSecret(key) && value != "" {
+				masked[key] = "********"
+			} else {
+				masked[key] = maskSecrets(value)
+			}
+		}
+		return masked
+	case []any:
+		masked := make([]any, len(v))
+		for i, value := range v {
+			masked[i] = maskSecrets(value)
+		}
+		return masked
+	}
+	return v
+}
+
+func isSecret(key string) bool {
+	key = strings.ToLower(key)
+	for _, word := range []string{"secret", "password", "cookie", "token"} {
+		if strings.Contains(key, word) {
+			return true
+		}
+	}
+	return false
+}
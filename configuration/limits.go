@@ -0,0 +1,80 @@
+package configuration
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/scheduler"
+)
+
+// Applies the configured request limits to the scheduler. Per-host limits come
+// from the sites in the config file, then from --host-limit, which are given
+// as "host=interval/jitter/max-in-flight", e.g. "forum.example.com=5s/2s/1";
+// omitted fields take the default values. Must be called after the site and
+// Reddit settings are applied, which decide the hosts a site's scrapes request.
+func ApplyRequestLimits() (err error) {
+	defaults := scheduler.Limits{
+		Interval:    viper.GetDuration("request-interval"),
+		Jitter:      viper.GetDuration("request-jitter"),
+		MaxInFlight: viper.GetInt("max-in-flight"),
+	}
+	scheduler.SetDefaultLimits(defaults)
+
+	var sites []Site
+	if sites, err = Sites(); err != nil {
+		return
+	}
+	// A site's limits apply to every host its scrapes request
+	setLimits := func(hostname string, limits scheduler.Limits) {
+		for _, h := range adapter.RequestHosts(hostname) {
+			scheduler.SetHostLimits(h, limits)
+		}
+	}
+	for _, s := range sites {
+		setLimits(s.Host, s.limits(defaults))
+	}
+
+	for _, spec := range viper.GetStringSlice("host-limit") {
+		var hostname string
+		var limits scheduler.Limits
+		if hostname, limits, err = parseHostLimits(spec, defaults); err != nil {
+			return
+		}
+		setLimits(hostname, limits)
+	}
+	return
+}
+
+func parseHostLimits(spec string, defaults scheduler.Limits) (hostname string, limits scheduler.Limits, err error) {
+	hostname, fields, found := strings.Cut(spec, "=")
+	if !found || hostname == "" {
+		err = fmt.Errorf("Bad host limit %q: expected host=interval/jitter/max-in-flight", spec)
+		return
+	}
+
+	limits = defaults
+	for i, field := range strings.Split(fields, "/") {
+		if field == "" {
+			continue
+		}
+		switch i {
+		case 0:
+			limits.Interval, err = time.ParseDuration(field)
+		case 1:
+			limits.Jitter, err = time.ParseDuration(field)
+		case 2:
+			limits.MaxInFlight, err = strconv.Atoi(field)
+		default:
+			err = fmt.Errorf("too many fields")
+		}
+		if err != nil {
+			err = fmt.Errorf("Bad host limit %q: %w", spec, err)
+			return
+		}
+	}
+	return
+}
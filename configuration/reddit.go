@@ -0,0 +1,27 @@
+package configuration
+
+import (
+	"github.com/spf13/viper"
+	"github.com/zvonler/espy/reddit"
+)
+
+// Applies the settings in the config file's "reddit" section to the Reddit
+// scraper.
+func ApplyRedditConfig() (err error) {
+	// Defaults make the settings visible to 'config show' and the environment
+	for _, key := range []string{"client-id", "client-secret", "username", "password", "user-agent", "base-url"} {
+		viper.SetDefault("reddit."+key, "")
+	}
+	viper.SetDefault("reddit.anonymous", false)
+
+	reddit.Configure(reddit.Config{
+		ClientID:     viper.GetString("reddit.client-id"),
+		ClientSecret: viper.GetString("reddit.client-secret"),
+		Username:     viper.GetString("reddit.username"),
+		Password:     viper.GetString("reddit.password"),
+		UserAgent:    viper.GetString("reddit.user-agent"),
+		BaseURL:      viper.GetString("reddit.base-url"),
+		Anonymous:    viper.GetBool("reddit.anonymous"),
+	})
+	return
+}
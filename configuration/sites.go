@@ -0,0 +1,102 @@
+package configuration
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/spf13/viper"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/scheduler"
+	"github.com/zvonler/espy/session"
+)
+
+// Settings for a single host, from the config file's "sites" list, e.g.
+//
+//	sites:
+//	  - host: forum.example.com
+//	    adapter: xenforo
+//	    request-interval: 5s
+//	    user-agent: "espy (+https://example.com/contact)"
+//	    username: alice
+//	    password: secret
+//
+// Omitted request limits take the default values. With a username and
+// password, scrapes sign in to the site when its stored session has expired.
+type Site struct {
+	Host            string
+	Adapter         string
+	UserAgent       string         `mapstructure:"user-agent"`
+	Cookie          string         `mapstructure:"cookie"`
+	Username        string         `mapstructure:"username"`
+	Password        string         `mapstructure:"password"`
+	RequestInterval *time.Duration `mapstructure:"request-interval"`
+	RequestJitter   *time.Duration `mapstructure:"request-jitter"`
+	MaxInFlight     *int           `mapstructure:"max-in-flight"`
+}
+
+// Returns the configured sites.
+func Sites() (sites []Site, err error) {
+	if err = viper.UnmarshalKey("sites", &sites); err != nil {
+		return nil, fmt.Errorf("Bad sites configuration: %w", err)
+	}
+	for _, s := range sites {
+		if s.Host == "" {
+			return nil, fmt.Errorf("Bad sites configuration: a site has no host")
+		}
+	}
+	return
+}
+
+func (s Site) limits(defaults scheduler.Limits) (limits scheduler.Limits) {
+	limits = defaults
+	if s.RequestInterval != nil {
+		limits.Interval = *s.RequestInterval
+	}
+	if s.RequestJitter != nil {
+		limits.Jitter = *s.RequestJitter
+	}
+	if s.MaxInFlight != nil {
+		limits.MaxInFlight = *s.MaxInFlight
+	}
+	return
+}
+
+func (s Site) headers() (headers http.Header) {
+	headers = make(http.Header)
+	if s.UserAgent != "" {
+		headers.Set("User-Agent", s.UserAgent)
+	}
+	return
+}
+
+// Parses the cookie setting, given as a Cookie header like "a=1; b=2".
+func (s Site) cookies() []*http.Cookie {
+	req := http.Request{Header: http.Header{"Cookie": {s.Cookie}}}
+	return req.Cookies()
+}
+
+// Applies the configured sites' user agents, cookies, logins and adapters.
+// Their request limits are applied by ApplyRequestLimits.
+func ApplySiteConfig() (err error) {
+	var sites []Site
+	if sites, err = Sites(); err != nil {
+		return
+	}
+	for _, s := range sites {
+		scheduler.SetHostHeaders(s.Host, s.headers())
+		if s.Cookie != "" {
+			session.SetCookies(&url.URL{Scheme: "https", Host: s.Host, Path: "/"}, s.cookies())
+		}
+		if s.Username != "" {
+			session.SetCredentials(s.Host, session.Credentials{Username: s.Username, Password: s.Password})
+		}
+		if s.Adapter != "" {
+			if err = adapter.SetHostAdapter(s.Host, s.Adapter); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,294 @@
+package database
+
+import (
+	"database/sql"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+// Statements that merge the author ?2 into the author ?1. A comment of ?2's
+// that would collide with one of ?1's is left with ?2, which is then kept.
+var mergeAuthorStmts = []string{
+	`UPDATE OR IGNORE comment SET author_id = ?1 WHERE author_id = ?2`,
+	`UPDATE thread SET author_id = ?1 WHERE author_id = ?2`,
+	`UPDATE comment_quote SET quoted_author_id = ?1 WHERE quoted_author_id = ?2`,
+	`UPDATE author_profile SET author_id = ?1 WHERE author_id = ?2`,
+	`INSERT OR IGNORE INTO author_alias
+		(author_id, username, first_seen)
+	SELECT ?1, username, first_seen FROM author_alias WHERE author_id = ?2`,
+	`DELETE FROM author_alias WHERE author_id = ?2 AND NOT EXISTS (SELECT 1 FROM comment WHERE author_id = ?2)`,
+	`DELETE FROM author WHERE id = ?2 AND NOT EXISTS (SELECT 1 FROM comment WHERE author_id = ?2)`,
+}
+
+// Returns the id of the author the site identifies by externalId, who is now
+// called username. An author seen under another username is renamed, and one
+// seen only by username before is given the id. Without an externalId,
+// authors are identified by username alone.
+func (sdb *ScraperDB) getOrInsertIdentifiedAuthor(username, externalId string, siteId model.SiteID) (id model.AuthorID, err error) {
+	if externalId == "" {
+		return sdb.getOrInsertAuthor(username, siteId)
+	}
+
+	var current string
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&id, &current)
+		},
+		`SELECT id, username FROM author WHERE site_id = ? AND external_id = ?`,
+		siteId, externalId)
+	if err != nil {
+		return
+	}
+
+	if id == 0 {
+		var namedId model.AuthorID
+		var namedExternalId sql.NullString
+		sdb.ForSingleRowOrPanic(
+			func(rows *sql.Rows) {
+				err = rows.Scan(&namedId, &namedExternalId)
+			},
+			`SELECT id, external_id FROM author WHERE site_id = ? AND username = ?`,
+			siteId, username)
+		if err != nil {
+			return
+		}
+
+		if namedId != 0 && !namedExternalId.Valid {
+			id = namedId
+			sdb.ExecOrPanic(`UPDATE author SET external_id = ? WHERE id = ?`, externalId, id)
+		} else {
+			if namedId != 0 {
+				// Another member used to have this username
+				sdb.releaseUsername(namedId)
+			}
+			sdb.WriteRowOrPanic(
+				func(rows *sql.Rows) {
+					err = rows.Scan(&id)
+				},
+				`INSERT INTO author
+					(site_id, username, external_id)
+				VALUES
+					(?, ?, ?)
+				RETURNING id`,
+				siteId, username, externalId)
+		}
+	} else if current != username {
+		err = sdb.renameAuthor(siteId, id, username)
+	}
+
+	if err == nil {
+		sdb.addAuthorAlias(id, username)
+	}
+	return
+}
+
+// Like getOrInsertIdentifiedAuthor, for usernames that may be out of date,
+// such as those in quotes. An author already known by externalId is returned
+// without being renamed.
+func (sdb *ScraperDB) getOrInsertQuotedAuthor(username, externalId string, siteId model.SiteID) (id model.AuthorID, err error) {
+	if externalId != "" {
+		sdb.ForSingleRowOrPanic(
+			func(rows *sql.Rows) {
+				err = rows.Scan(&id)
+			},
+			`SELECT id FROM author WHERE site_id = ? AND external_id = ?`,
+			siteId, externalId)
+		if err != nil || id != 0 {
+			return
+		}
+	}
+	return sdb.getOrInsertIdentifiedAuthor(username, externalId, siteId)
+}
+
+func (sdb *ScraperDB) renameAuthor(siteId model.SiteID, id model.AuthorID, username string) (err error) {
+	var otherId model.AuthorID
+	var otherExternalId sql.NullString
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&otherId, &otherExternalId)
+		},
+		`SELECT id, external_id FROM author WHERE site_id = ? AND username = ?`,
+		siteId, username)
+	if err != nil {
+		return
+	}
+
+	if otherId != 0 {
+		// An author seen only by this username is taken to be the same
+		// member, and one with another id used to have the username.
+		if !otherExternalId.Valid {
+			sdb.mergeAuthors(id, otherId)
+		}
+		sdb.releaseUsername(otherId)
+	}
+	sdb.ExecOrPanic(`UPDATE author SET username = ? WHERE id = ?`, username, id)
+	return
+}
+
+// Moves everything recorded for other to keeper and deletes other.
+func (sdb *ScraperDB) mergeAuthors(keeper, other model.AuthorID) {
+	if err := sdb.withTransaction(func(tx *ScraperDB) error {
+		for _, stmt := range mergeAuthorStmts {
+			tx.ExecOrPanic(stmt, keeper, other)
+		}
+		return nil
+	}); err != nil {
+		panic(err)
+	}
+}
+
+// Renames an author whose username now belongs to someone else. The author
+// keeps the username as an alias until they are seen under their new one.
+func (sdb *ScraperDB) releaseUsername(id model.AuthorID) {
+	sdb.ExecOrPanic(`UPDATE author SET username = username || ' #' || id WHERE id = ?`, id)
+}
+
+func (sdb *ScraperDB) addAuthorAlias(id model.AuthorID, username string) {
+	sdb.ExecOrPanic(
+		`INSERT INTO author_alias
+			(author_id, username, first_seen)
+		VALUES
+			(?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		id, username, time.Now().Unix())
+}
+
+// Returns the ids of the authors who are or were called username.
+const authorIdsByAliasStmt = `
+	SELECT id FROM author WHERE username = ?1
+	UNION
+	SELECT author_id FROM author_alias WHERE username = ?1`
+
+// Stores a member's profile against the author they are now.
+func (sdb *ScraperDB) setAuthorProfile(siteId model.SiteID, p model.AuthorProfile) (err error) {
+	var authorId model.AuthorID
+	if authorId, err = sdb.getOrInsertIdentifiedAuthor(p.Username, p.MemberId, siteId); err != nil {
+		return
+	}
+
+	var joined any
+	if !p.Joined.IsZero() {
+		joined = p.Joined.Unix()
+	}
+	sdb.ExecOrPanic(
+		`INSERT INTO author_profile
+			(site_id, member_id, author_id, title, joined, message_count, reaction_score, location, updated)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO UPDATE SET
+			author_id = excluded.author_id,
+			title = excluded.title,
+			joined = COALESCE(excluded.joined, joined),
+			message_count = excluded.message_count,
+			reaction_score = excluded.reaction_score,
+			location = excluded.location,
+			updated = excluded.updated`,
+		siteId, p.MemberId, authorId, p.Title, joined, p.Messages, p.ReactionScore, p.Location, time.Now().Unix())
+	return
+}
+
+// Everything known about an author at one site.
+type AuthorDetails struct {
+	Id         model.AuthorID
+	SiteId     model.SiteID
+	Hostname   string
+	Username   string
+	ExternalId string
+
+	// Other usernames the author has used, oldest first.
+	Aliases []string
+
+	// Nil if the site has shown no profile for the author.
+	Profile *model.AuthorProfile
+	Updated time.Time
+
+	Comments       uint
+	Threads        uint
+	ThreadsStarted uint
+	FirstComment   time.Time
+	LastComment    time.Time
+}
+
+// Returns the details of every author who is or was called username, one per
+// site.
+func (sdb *ScraperDB) AuthorDetails(username string) (details []AuthorDetails, err error) {
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var d AuthorDetails
+			var externalId sql.NullString
+			if err = rows.Scan(&d.Id, &d.SiteId, &d.Hostname, &d.Username, &externalId); err != nil {
+				panic(err)
+			}
+			d.ExternalId = externalId.String
+			details = append(details, d)
+		},
+		`SELECT a.id, a.site_id, s.hostname, a.username, a.external_id
+		FROM author a
+			JOIN site s ON s.id = a.site_id
+		WHERE a.id IN (`+authorIdsByAliasStmt+`)
+		ORDER BY s.hostname, a.id`,
+		username)
+
+	for i := range details {
+		d := &details[i]
+		sdb.ForEachRowOrPanic(
+			func(rows *sql.Rows) {
+				var alias string
+				rows.Scan(&alias)
+				d.Aliases = append(d.Aliases, alias)
+			},
+			`SELECT username FROM author_alias
+			WHERE author_id = ? AND username != ?
+			ORDER BY first_seen, rowid`,
+			d.Id, d.Username)
+
+		sdb.ForSingleRowOrPanic(
+			func(rows *sql.Rows) {
+				var joined sql.NullInt64
+				var updated int64
+				p := model.AuthorProfile{Username: d.Username}
+				err = rows.Scan(&p.MemberId, &p.Title, &joined, &p.Messages, &p.ReactionScore, &p.Location, &updated)
+				if joined.Valid {
+					p.Joined = time.Unix(joined.Int64, 0)
+				}
+				d.Profile = &p
+				d.Updated = time.Unix(updated, 0)
+			},
+			`SELECT
+				member_id, COALESCE(title, ''), joined, COALESCE(message_count, 0),
+				COALESCE(reaction_score, 0), COALESCE(location, ''), updated
+			FROM author_profile
+			WHERE author_id = ?`,
+			d.Id)
+		if err != nil {
+			return
+		}
+
+		sdb.ForSingleRowOrPanic(
+			func(rows *sql.Rows) {
+				var first, last sql.NullInt64
+				if err = rows.Scan(&d.Comments, &d.Threads, &first, &last); err == nil && first.Valid {
+					d.FirstComment = time.Unix(first.Int64, 0)
+					d.LastComment = time.Unix(last.Int64, 0)
+				}
+			},
+			`SELECT COUNT(*), COUNT(DISTINCT thread_id), MIN(published), MAX(published)
+			FROM comment WHERE author_id = ?`,
+			d.Id)
+		if err != nil {
+			return
+		}
+
+		sdb.ForSingleRowOrPanic(
+			func(rows *sql.Rows) {
+				err = rows.Scan(&d.ThreadsStarted)
+			},
+			`SELECT COUNT(*) FROM thread WHERE author_id = ?`,
+			d.Id)
+		if err != nil {
+			return
+		}
+	}
+	return
+}
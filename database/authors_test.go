@@ -0,0 +1,129 @@
+package database
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestAuthorDetails(t *testing.T) {
+	tt := newTestThread(t, "alice")
+
+	profile := model.AuthorProfile{MemberId: "1", Username: "alice", Title: "Member",
+		Joined: time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC), Messages: 10, ReactionScore: 3}
+	post := func(id string, author string, published int64, p *model.AuthorProfile) model.Comment {
+		c := tt.post(id, author)
+		c.Published, c.AuthorProfile = time.Unix(published, 0), p
+		if p != nil {
+			c.AuthorExternalId = p.MemberId
+		}
+		return c
+	}
+	tt.add(t,
+		post("101", "alice", 1696161600, &profile),
+		post("102", "dave", 1696165200, nil),
+	)
+
+	// Alice is renamed and the profile is seen again under the new name
+	renamed := profile
+	renamed.Username = "alicia"
+	renamed.Messages = 11
+	tt.add(t, post("103", "alicia", 1696235400, &renamed))
+
+	for _, name := range []string{"alice", "alicia"} {
+		details, err := tt.db.AuthorDetails(name)
+		require.Nil(t, err)
+		require.Equal(t, 1, len(details))
+		d := details[0]
+		require.Equal(t, "forum.example.com", d.Hostname)
+		require.Equal(t, "alicia", d.Username)
+		require.Equal(t, "1", d.ExternalId)
+		require.Equal(t, []string{"alice"}, d.Aliases)
+		require.Equal(t, uint(11), d.Profile.Messages)
+		require.True(t, profile.Joined.Equal(d.Profile.Joined))
+		require.Equal(t, uint(2), d.Comments)
+		require.Equal(t, uint(1), d.Threads)
+		require.Equal(t, uint(1), d.ThreadsStarted)
+		require.Equal(t, time.Unix(1696161600, 0), d.FirstComment)
+		require.Equal(t, time.Unix(1696235400, 0), d.LastComment)
+
+		comments, err := tt.db.FindAuthorComments(name)
+		require.Nil(t, err)
+		require.Equal(t, 2, len(comments))
+		require.Equal(t, "alicia", comments[0].Author)
+	}
+
+	// Authors without a profile still show their activity
+	details, err := tt.db.AuthorDetails("dave")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(details))
+	require.Nil(t, details[0].Profile)
+	require.Empty(t, details[0].Aliases)
+	require.Equal(t, uint(1), details[0].Comments)
+	require.Equal(t, uint(0), details[0].ThreadsStarted)
+
+	details, err = tt.db.AuthorDetails("nobody")
+	require.Nil(t, err)
+	require.Empty(t, details)
+}
+
+func TestAuthorIdentities(t *testing.T) {
+	tt := newTestThread(t, "bob")
+	post := func(id string, author string, externalId string) model.Comment {
+		c := tt.post(id, author)
+		c.AuthorExternalId = externalId
+		return c
+	}
+
+	// Bob's thread was stored without an id, so it stays Bob's once the id
+	// is seen. A quote under the old name doesn't rename Bob back.
+	tt.add(t, post("1", "bob", "2"))
+	tt.add(t, post("22", "robert", "2"))
+	quoting := post("333", "carol", "3")
+	quoting.Quotes = []model.Quote{{Author: "bob", MemberId: "2"}}
+	tt.add(t, quoting)
+
+	details, err := tt.db.AuthorDetails("bob")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(details))
+	require.Equal(t, "robert", details[0].Username)
+	require.Equal(t, uint(2), details[0].Comments)
+	require.Equal(t, uint(1), details[0].ThreadsStarted)
+
+	interactions, err := tt.db.AuthorInteractions("robert")
+	require.Nil(t, err)
+	require.Equal(t, []Interaction{{"carol", 0, 1}}, interactions)
+
+	// A comment stored under a name alone is merged into the member who
+	// takes that name
+	tt.add(t, post("4444", "rob", ""))
+	tt.add(t, post("55555", "rob", "2"))
+	details, err = tt.db.AuthorDetails("rob")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(details))
+	require.Equal(t, "2", details[0].ExternalId)
+	require.Equal(t, []string{"bob", "robert"}, details[0].Aliases)
+	require.Equal(t, uint(4), details[0].Comments)
+
+	// Another member taking a username leaves the old holder under it as an
+	// alias only
+	tt.add(t, post("666666", "robert", "9"))
+	details, err = tt.db.AuthorDetails("robert")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(details))
+	require.Equal(t, "2", details[0].ExternalId)
+	require.Equal(t, "9", details[1].ExternalId)
+	require.Equal(t, "robert", details[1].Username)
+
+	// Or the current username, which the old holder gives up
+	tt.add(t, post("7777777", "rob", "10"))
+	details, err = tt.db.AuthorDetails("rob")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(details))
+	require.NotEqual(t, "rob", details[0].Username)
+	require.Equal(t, "2", details[0].ExternalId)
+	require.Equal(t, "rob", details[1].Username)
+	require.Equal(t, "10", details[1].ExternalId)
+}
@@ -0,0 +1,188 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/utils"
+)
+
+// A Crawl is a forum scrape whose frontier of pages still to visit is kept in
+// the database, so it can continue after the process is interrupted.
+type CrawlID uint
+
+type Crawl struct {
+	Id        CrawlID
+	URL       *url.URL
+	Cutoff    time.Time
+	SubForums bool
+	Started   time.Time
+}
+
+type CrawlTaskKind string
+
+const (
+	CrawlForumPage CrawlTaskKind = "forum_page"
+	CrawlThread    CrawlTaskKind = "thread"
+)
+
+type CrawlTaskState string
+
+const (
+	CrawlPending CrawlTaskState = "pending"
+	CrawlDone    CrawlTaskState = "done"
+	CrawlFailed  CrawlTaskState = "failed"
+)
+
+type CrawlTask struct {
+	Id       uint
+	Kind     CrawlTaskKind
+	URL      *url.URL
+	Page     uint
+	ForumId  model.ForumID
+	ThreadId model.ThreadID
+}
+
+var ErrNoUnfinishedCrawl = errors.New("No unfinished crawl found")
+
+func (sdb *ScraperDB) StartCrawl(u *url.URL, cutoff time.Time, subforums bool) (crawl Crawl, err error) {
+	crawl = Crawl{URL: u, Cutoff: cutoff, SubForums: subforums, Started: time.Now()}
+	sdb.WriteRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&crawl.Id)
+		},
+		`INSERT INTO crawl
+			(url, cutoff, subforums, started)
+		VALUES
+			(?, ?, ?, ?)
+		RETURNING id`,
+		utils.TrimmedURL(u).String(), cutoff.Unix(), subforums, crawl.Started.Unix())
+	return
+}
+
+// Returns the most recently started crawl that has not finished, limited to
+// crawls of u if it is not nil. Tasks that failed are made pending again so
+// they are retried.
+func (sdb *ScraperDB) FindUnfinishedCrawl(u *url.URL) (crawl Crawl, err error) {
+	stmt := `SELECT id, url, cutoff, subforums, started FROM crawl WHERE finished IS NULL`
+	params := []any{}
+	if u != nil {
+		stmt += ` AND url = ?`
+		params = append(params, utils.TrimmedURL(u).String())
+	}
+	stmt += ` ORDER BY started DESC, id DESC LIMIT 1`
+
+	err = ErrNoUnfinishedCrawl
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			var urlStr string
+			var cutoff, started int64
+			if err = rows.Scan(&crawl.Id, &urlStr, &cutoff, &crawl.SubForums, &started); err == nil {
+				crawl.URL, err = url.Parse(urlStr)
+				crawl.Cutoff = time.Unix(cutoff, 0)
+				crawl.Started = time.Unix(started, 0)
+			}
+		},
+		stmt, params...)
+
+	if err == nil {
+		sdb.ExecOrPanic(
+			`UPDATE crawl_task SET state = ?, error = NULL WHERE crawl_id = ? AND state = ?`,
+			CrawlPending, crawl.Id, CrawlFailed)
+	}
+	return
+}
+
+// Adds a task to the crawl's frontier. Tasks already in the crawl, such as a
+// thread that moved to another page while crawling, are not added twice.
+func (sdb *ScraperDB) AddCrawlTask(crawlId CrawlID, task CrawlTask) {
+	var forumId, threadId any
+	if task.ForumId != 0 {
+		forumId = task.ForumId
+	}
+	if task.ThreadId != 0 {
+		threadId = task.ThreadId
+	}
+	sdb.ExecOrPanic(
+		`INSERT INTO crawl_task
+			(crawl_id, kind, url, page, forum_id, thread_id, updated)
+		VALUES
+			(?, ?, ?, ?, ?, ?, ?)
+		ON CONFLICT DO NOTHING`,
+		crawlId, task.Kind, task.URL.String(), task.Page, forumId, threadId, time.Now().Unix())
+}
+
+// Returns the next pending task. Forum pages are visited before threads so the
+// listing is read as close together in time as possible.
+func (sdb *ScraperDB) NextCrawlTask(crawlId CrawlID) (task CrawlTask, ok bool) {
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			var urlStr string
+			var forumId, threadId sql.NullInt64
+			if err := rows.Scan(&task.Id, &task.Kind, &urlStr, &task.Page, &forumId, &threadId); err != nil {
+				panic(err)
+			}
+			var err error
+			if task.URL, err = url.Parse(urlStr); err != nil {
+				panic(err)
+			}
+			task.ForumId = model.ForumID(forumId.Int64)
+			task.ThreadId = model.ThreadID(threadId.Int64)
+			ok = true
+		},
+		`SELECT id, kind, url, page, forum_id, thread_id
+		FROM crawl_task
+		WHERE crawl_id = ? AND state = ?
+		ORDER BY kind = ? DESC, id
+		LIMIT 1`,
+		crawlId, CrawlPending, CrawlForumPage)
+	return
+}
+
+func (sdb *ScraperDB) SetCrawlTaskState(taskId uint, state CrawlTaskState, taskErr error) {
+	var errText any
+	if taskErr != nil {
+		errText = taskErr.Error()
+	}
+	sdb.ExecOrPanic(
+		`UPDATE crawl_task SET state = ?, error = ?, updated = ? WHERE id = ?`,
+		state, errText, time.Now().Unix(), taskId)
+}
+
+// Returns the number of tasks in each state for the crawl.
+func (sdb *ScraperDB) CrawlTaskCounts(crawlId CrawlID) (counts map[CrawlTaskState]uint) {
+	counts = make(map[CrawlTaskState]uint)
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var state CrawlTaskState
+			var count uint
+			rows.Scan(&state, &count)
+			counts[state] = count
+		},
+		`SELECT state, COUNT(*) FROM crawl_task WHERE crawl_id = ? GROUP BY state`,
+		crawlId)
+	return
+}
+
+// Marks the crawl finished and records the scrape time of every forum it
+// visited.
+func (sdb *ScraperDB) FinishCrawl(crawlId CrawlID) {
+	now := time.Now()
+	var forumIds []model.ForumID
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var forumId model.ForumID
+			rows.Scan(&forumId)
+			forumIds = append(forumIds, forumId)
+		},
+		`SELECT DISTINCT forum_id FROM crawl_task WHERE crawl_id = ? AND kind = ? AND forum_id IS NOT NULL`,
+		crawlId, CrawlForumPage)
+
+	for _, forumId := range forumIds {
+		sdb.SetForumLastScraped(forumId, now)
+	}
+	sdb.ExecOrPanic(`UPDATE crawl SET finished = ? WHERE id = ?`, now.Unix(), crawlId)
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCrawlQueue(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := OpenScraperDB(tmpDir + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL, _ := url.Parse("https://forum.example.com/forums/vehicles.2/")
+	threadURL, _ := url.Parse("https://forum.example.com/threads/battery-warranty.10/")
+	_, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+
+	_, err = db.FindUnfinishedCrawl(nil)
+	require.Equal(t, ErrNoUnfinishedCrawl, err)
+
+	cutoff := time.Unix(1696161600, 0)
+	crawl, err := db.StartCrawl(forumURL, cutoff, true)
+	require.Nil(t, err)
+
+	db.AddCrawlTask(crawl.Id, CrawlTask{Kind: CrawlThread, URL: threadURL, ThreadId: 10})
+	db.AddCrawlTask(crawl.Id, CrawlTask{Kind: CrawlForumPage, URL: forumURL, Page: 1, ForumId: forumId})
+	db.AddCrawlTask(crawl.Id, CrawlTask{Kind: CrawlForumPage, URL: forumURL, Page: 2, ForumId: forumId})
+	// A thread seen again on a later page is not queued twice
+	db.AddCrawlTask(crawl.Id, CrawlTask{Kind: CrawlThread, URL: threadURL, ThreadId: 10})
+	require.Equal(t, uint(3), db.CrawlTaskCounts(crawl.Id)[CrawlPending])
+
+	// Forum pages come before threads
+	task, ok := db.NextCrawlTask(crawl.Id)
+	require.True(t, ok)
+	require.Equal(t, CrawlForumPage, task.Kind)
+	require.Equal(t, uint(1), task.Page)
+	require.Equal(t, forumId, task.ForumId)
+	db.SetCrawlTaskState(task.Id, CrawlDone, nil)
+
+	task, ok = db.NextCrawlTask(crawl.Id)
+	require.True(t, ok)
+	require.Equal(t, uint(2), task.Page)
+	db.SetCrawlTaskState(task.Id, CrawlFailed, errors.New("Timeout"))
+
+	// The process is interrupted before the thread is scraped
+	task, ok = db.NextCrawlTask(crawl.Id)
+	require.True(t, ok)
+	require.Equal(t, CrawlThread, task.Kind)
+	require.Equal(t, threadURL.String(), task.URL.String())
+
+	resumed, err := db.FindUnfinishedCrawl(forumURL)
+	require.Nil(t, err)
+	require.Equal(t, crawl.Id, resumed.Id)
+	require.Equal(t, cutoff, resumed.Cutoff)
+	require.True(t, resumed.SubForums)
+
+	// Resuming retries failed tasks
+	counts := db.CrawlTaskCounts(crawl.Id)
+	require.Equal(t, uint(2), counts[CrawlPending])
+	require.Equal(t, uint(1), counts[CrawlDone])
+
+	db.FinishCrawl(crawl.Id)
+	_, err = db.FindUnfinishedCrawl(nil)
+	require.Equal(t, ErrNoUnfinishedCrawl, err)
+
+	lastScraped, err := db.GetForumLastScraped(forumId)
+	require.Nil(t, err)
+	require.False(t, lastScraped.IsZero())
+}
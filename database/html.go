@@ -0,0 +1,114 @@
+package database
+
+import (
+	"bytes"
+	"compress/gzip"
+	"database/sql"
+	"io"
+	"net/url"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+func (sdb *ScraperDB) setCommentHTML(commentId model.CommentID, html string) (err error) {
+	var buf bytes.Buffer
+	zw := gzip.NewWriter(&buf)
+	if _, err = zw.Write([]byte(html)); err == nil {
+		err = zw.Close()
+	}
+	if err == nil {
+		sdb.ExecOrPanic(
+			`INSERT INTO comment_html
+				(comment_id, html, captured)
+			VALUES
+				(?, ?, ?)
+			ON CONFLICT DO UPDATE SET
+				html = excluded.html,
+				captured = excluded.captured`,
+			commentId, buf.Bytes(), time.Now().Unix())
+	}
+	return
+}
+
+// Returns the ids of comments with stored HTML, in id order.
+func (sdb *ScraperDB) CommentHTMLIds() (ids []model.CommentID) {
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var id model.CommentID
+			rows.Scan(&id)
+			ids = append(ids, id)
+		},
+		`SELECT comment_id FROM comment_html ORDER BY comment_id`)
+	return
+}
+
+// A comment's stored HTML and what was extracted from it.
+type CommentHTML struct {
+	Comment  model.Comment
+	ThreadId model.ThreadID
+	Captured time.Time
+}
+
+// Returns the stored HTML of a comment in Comment.HTML, along with the
+// content currently stored for it.
+func (sdb *ScraperDB) GetCommentHTML(commentId model.CommentID) (ch CommentHTML, err error) {
+	var compressed []byte
+	err = ErrCommentNotFound
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			var urlStr string
+			var published, captured int64
+			if err = rows.Scan(&urlStr, &ch.Comment.Author, &published, &ch.Comment.Content,
+				&ch.ThreadId, &compressed, &captured); err == nil {
+				ch.Comment.URL, err = url.Parse(urlStr)
+				ch.Comment.Published = time.Unix(published, 0)
+				ch.Captured = time.Unix(captured, 0)
+			}
+		},
+		`SELECT
+			c.url, a.username, c.published, c.content, c.thread_id, h.html, h.captured
+		FROM comment_html h
+			JOIN comment c ON c.id = h.comment_id
+			JOIN author a ON a.id = c.author_id
+		WHERE h.comment_id = ?`,
+		commentId)
+
+	if err == nil {
+		var zr *gzip.Reader
+		if zr, err = gzip.NewReader(bytes.NewReader(compressed)); err == nil {
+			var html []byte
+			if html, err = io.ReadAll(zr); err == nil {
+				ch.Comment.HTML = string(html)
+			}
+		}
+	}
+	return
+}
+
+// Replaces what was extracted for a comment with a fresh extraction of the
+// same HTML. Unlike AddComments, a change of content is a correction of the
+// stored revision rather than a new one.
+func (sdb *ScraperDB) ReplaceExtractedComment(siteId model.SiteID, commentId model.CommentID, c model.Comment) error {
+	return sdb.withTransaction(func(tx *ScraperDB) (err error) {
+		var latestRevision int64
+		if err = tx.conn().QueryRow(
+			`SELECT COALESCE(MAX(id), 0) FROM comment_revision WHERE comment_id = ?`, commentId).Scan(&latestRevision); err == nil {
+			_, err = tx.conn().Exec(`UPDATE comment SET content = ? WHERE id = ?`, c.Content, commentId)
+		}
+		if err == nil {
+			// Drop the revision the update trigger added
+			_, err = tx.conn().Exec(`DELETE FROM comment_revision WHERE comment_id = ? AND id > ?`, commentId, latestRevision)
+		}
+		if err == nil {
+			_, err = tx.conn().Exec(`UPDATE comment_revision SET content = ? WHERE id = ?`, c.Content, latestRevision)
+		}
+		if err == nil {
+			if err = tx.setCommentQuotes(siteId, commentId, c.Quotes); err == nil {
+				tx.setCommentLinks(commentId, c.Links)
+				tx.setCommentMedia(commentId, c.Media)
+			}
+		}
+		return
+	})
+}
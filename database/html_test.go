@@ -0,0 +1,68 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestCommentHTML(t *testing.T) {
+	tt := newTestThread(t, "alice")
+	comments := []model.Comment{tt.post("101", "alice"), tt.post("102", "bob")}
+	comments[0].Content, comments[0].HTML = "Mine  failed", "<p>Mine&nbsp; failed</p>"
+	comments[1].Content, comments[1].HTML = "Same", "<p>Same</p>"
+	first, second := comments[0].URL, comments[1].URL
+
+	// HTML is only kept when asked for
+	tt.add(t, comments[1:]...)
+	require.Empty(t, tt.db.CommentHTMLIds())
+
+	tt.db.KeepHTML = true
+	tt.add(t, comments...)
+	ids := tt.db.CommentHTMLIds()
+	require.Equal(t, 2, len(ids))
+
+	// The second comment was stored first
+	stored, err := tt.db.GetCommentHTML(ids[1])
+	require.Nil(t, err)
+	require.Equal(t, tt.threadId, stored.ThreadId)
+	require.Equal(t, first.String(), stored.Comment.URL.String())
+	require.Equal(t, "Mine  failed", stored.Comment.Content)
+	require.Equal(t, "<p>Mine&nbsp; failed</p>", stored.Comment.HTML)
+
+	// A better extraction corrects the content without adding a revision
+	fixed := stored.Comment
+	fixed.Content = "Mine failed"
+	fixed.Links = []model.Link{{URL: second, Text: "link"}}
+	require.Nil(t, tt.db.ReplaceExtractedComment(tt.siteId, ids[1], fixed))
+
+	revisions, err := tt.db.CommentRevisions(first)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(revisions))
+	require.Equal(t, "Mine failed", revisions[0].Content)
+
+	links, err := tt.db.CommentLinks("")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(links))
+
+	// A correction whose links can't be stored leaves the comment as it was
+	_, err = tt.db.DB.Exec(`
+		CREATE TRIGGER fail_link BEFORE INSERT ON comment_link BEGIN
+			SELECT RAISE(ABORT, 'link rejected');
+		END`)
+	require.Nil(t, err)
+	refixed := fixed
+	refixed.Content = "Mine has failed"
+	require.Panics(t, func() { tt.db.ReplaceExtractedComment(tt.siteId, ids[1], refixed) })
+	revisions, err = tt.db.CommentRevisions(first)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(revisions))
+	require.Equal(t, "Mine failed", revisions[0].Content)
+	links, err = tt.db.CommentLinks("")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(links))
+
+	_, err = tt.db.GetCommentHTML(model.CommentID(999))
+	require.Equal(t, ErrCommentNotFound, err)
+}
@@ -0,0 +1,130 @@
+package database
+
+import (
+	"database/sql"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+// Returns the host a link is counted under, without any "www." prefix.
+func linkDomain(u *url.URL) string {
+	return strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")
+}
+
+func (sdb *ScraperDB) setCommentLinks(commentId model.CommentID, links []model.Link) {
+	sdb.ExecOrPanic(`DELETE FROM comment_link WHERE comment_id = ?`, commentId)
+	for _, l := range links {
+		sdb.ExecOrPanic(
+			`INSERT INTO comment_link
+				(comment_id, url, domain, text)
+			VALUES
+				(?, ?, ?, ?)`,
+			commentId, l.URL.String(), linkDomain(l.URL), l.Text)
+	}
+}
+
+func (sdb *ScraperDB) setCommentMedia(commentId model.CommentID, media []model.Media) {
+	sdb.ExecOrPanic(`DELETE FROM comment_media WHERE comment_id = ?`, commentId)
+	for _, m := range media {
+		sdb.ExecOrPanic(
+			`INSERT INTO comment_media
+				(comment_id, kind, url, text)
+			VALUES
+				(?, ?, ?, ?)`,
+			commentId, m.Kind, m.URL.String(), m.Text)
+	}
+}
+
+type CommentLink struct {
+	model.Link
+	Comment   *url.URL
+	Published time.Time
+}
+
+// Returns links from all comments, newest first, limited to a domain and its
+// subdomains unless domain is empty.
+func (sdb *ScraperDB) CommentLinks(domain string) (links []CommentLink, err error) {
+	domain = strings.TrimPrefix(strings.ToLower(domain), "www.")
+	stmt := `
+		SELECT
+			c.url, c.published, l.url, l.text
+		FROM comment_link l
+			JOIN comment c ON c.id = l.comment_id
+		WHERE
+			? = '' OR l.domain = ? OR l.domain LIKE '%.' || ?
+		ORDER BY c.published DESC, l.rowid`
+
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var l CommentLink
+			var commentURL, linkURL string
+			var published int64
+			if err = rows.Scan(&commentURL, &published, &linkURL, &l.Text); err != nil {
+				panic(err)
+			}
+			l.Comment, _ = url.Parse(commentURL)
+			l.URL, _ = url.Parse(linkURL)
+			l.Published = time.Unix(published, 0)
+			links = append(links, l)
+		},
+		stmt, domain, domain, domain)
+	return
+}
+
+type DomainCount struct {
+	Domain string
+	Count  uint
+}
+
+// Returns how many links point to each domain, most linked first.
+func (sdb *ScraperDB) LinkDomainCounts() (counts []DomainCount, err error) {
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var dc DomainCount
+			if err = rows.Scan(&dc.Domain, &dc.Count); err != nil {
+				panic(err)
+			}
+			counts = append(counts, dc)
+		},
+		`SELECT domain, COUNT(*) n FROM comment_link GROUP BY domain ORDER BY n DESC, domain`)
+	return
+}
+
+type CommentMedia struct {
+	model.Media
+	Comment   *url.URL
+	Author    string
+	Published time.Time
+}
+
+// Returns the media in a thread's comments, in the order they were posted.
+func (sdb *ScraperDB) ThreadMedia(threadId model.ThreadID) (media []CommentMedia, err error) {
+	stmt := `
+		SELECT
+			c.url, a.username, c.published, m.kind, m.url, m.text
+		FROM comment_media m
+			JOIN comment c ON c.id = m.comment_id
+			JOIN author a ON a.id = c.author_id
+		WHERE
+			c.thread_id = ?
+		ORDER BY c.published, m.rowid`
+
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var m CommentMedia
+			var commentURL, mediaURL string
+			var published int64
+			if err = rows.Scan(&commentURL, &m.Author, &published, &m.Kind, &mediaURL, &m.Text); err != nil {
+				panic(err)
+			}
+			m.Comment, _ = url.Parse(commentURL)
+			m.URL, _ = url.Parse(mediaURL)
+			m.Published = time.Unix(published, 0)
+			media = append(media, m)
+		},
+		stmt, threadId)
+	return
+}
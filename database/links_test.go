@@ -0,0 +1,52 @@
+package database
+
+import (
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestLinksAndMedia(t *testing.T) {
+	tt := newTestThread(t, "alice")
+	mustParse := func(s string) *url.URL {
+		u, err := url.Parse(s)
+		require.Nil(t, err)
+		return u
+	}
+
+	comment := tt.post("101", "alice")
+	comment.Content = "Look"
+	comment.Links = []model.Link{
+		{URL: mustParse("https://www.YouTube.com/watch?v=abc"), Text: "video"},
+		{URL: mustParse("https://m.youtube.com/watch?v=def"), Text: "mobile"},
+		{URL: mustParse("https://example.org/terms"), Text: "terms"},
+	}
+	comment.Media = []model.Media{
+		{Kind: model.Image, URL: mustParse("https://forum.example.com/pack.jpg"), Text: "Pack"},
+	}
+	tt.add(t, comment)
+	// Re-scraping does not duplicate links or media
+	tt.add(t, comment)
+
+	links, err := tt.db.CommentLinks("")
+	require.Nil(t, err)
+	require.Equal(t, 3, len(links))
+
+	links, err = tt.db.CommentLinks("www.youtube.com")
+	require.Nil(t, err)
+	require.Equal(t, 2, len(links))
+	require.Equal(t, "video", links[0].Text)
+	require.Equal(t, comment.URL.String(), links[0].Comment.String())
+
+	counts, err := tt.db.LinkDomainCounts()
+	require.Nil(t, err)
+	require.Equal(t, []DomainCount{{"example.org", 1}, {"m.youtube.com", 1}, {"youtube.com", 1}}, counts)
+
+	media, err := tt.db.ThreadMedia(tt.threadId)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(media))
+	require.Equal(t, model.Image, media[0].Kind)
+	require.Equal(t, "alice", media[0].Author)
+}
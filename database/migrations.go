@@ -0,0 +1,508 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+)
+
+// A Migration is a single, ordered step in the evolution of the database
+// schema. Migrations are applied in order of Version, each in its own
+// transaction, and are recorded in the schema_version table once applied.
+type Migration struct {
+	Version     uint
+	Description string
+	Stmt        string
+
+	// If set, called to apply the migration instead of executing Stmt.
+	apply func(*sql.Tx, Migration) error
+}
+
+// New migrations must be appended with the next Version number. Applied
+// migrations must never be edited, since existing databases will not re-run
+// them.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "Create initial tables",
+		// Databases created before migrations existed already have these
+		// tables, so they are created only when missing.
+		Stmt: `
+CREATE TABLE IF NOT EXISTS site (
+	id INTEGER NOT NULL PRIMARY KEY,
+	hostname STRING UNIQUE
+);
+
+CREATE TABLE IF NOT EXISTS forum (
+	id INTEGER NOT NULL PRIMARY KEY,
+	site_id INTEGER NOT NULL,
+	url TEXT UNIQUE,
+	last_scraped INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS author (
+	id INTEGER NOT NULL PRIMARY KEY,
+	site_id INTEGER NOT NULL,
+	username TEXT,
+
+	UNIQUE(site_id, username)
+);
+
+CREATE TABLE IF NOT EXISTS thread (
+	id INTEGER NOT NULL PRIMARY KEY,
+	forum_id INTEGER NOT NULL,
+	author_id INTEGER NOT NULL,
+	title TEXT,
+	url TEXT UNIQUE,
+	replies INTEGER,
+	views INTEGER,
+	latest_activity INTEGER,
+	start_date INTEGER
+);
+
+CREATE TABLE IF NOT EXISTS comment (
+	id INTEGER NOT NULL PRIMARY KEY,
+	url TEXT UNIQUE,
+	thread_id INTEGER NOT NULL,
+	author_id INTEGER NOT NULL,
+	published INTEGER,
+	content TEXT,
+
+	UNIQUE(thread_id, author_id, published)
+);
+
+CREATE TABLE IF NOT EXISTS thread_tag (
+	thread_id INTEGER NOT NULL,
+	tag_id INTEGER NOT NULL,
+
+	UNIQUE(thread_id, tag_id)
+);
+
+CREATE TABLE IF NOT EXISTS tag (
+	id INTEGER NOT NULL PRIMARY KEY,
+	name TEXT UNIQUE
+);`,
+	},
+	{
+		Version:     2,
+		Description: "Index comments by thread and author, and threads by forum",
+		Stmt: `
+CREATE INDEX IF NOT EXISTS comment_thread_idx ON comment (thread_id, published);
+CREATE INDEX IF NOT EXISTS comment_author_idx ON comment (author_id);
+CREATE INDEX IF NOT EXISTS thread_forum_idx ON thread (forum_id);`,
+	},
+	{
+		Version:     3,
+		Description: "Create full-text index over comment content",
+		// Skipped without FTS5; OpenScraperDB creates the index once the
+		// database is opened by a build with it.
+		Stmt:  commentIndexStmt,
+		apply: createCommentIndex,
+	},
+	{
+		Version:     4,
+		Description: "Track the highest post number scraped from each thread",
+		Stmt:        `ALTER TABLE thread ADD COLUMN highest_post_number INTEGER NOT NULL DEFAULT 0;`,
+	},
+	{
+		Version:     5,
+		Description: "Persist forum crawl frontiers so interrupted scrapes can resume",
+		Stmt: `
+CREATE TABLE crawl (
+	id INTEGER NOT NULL PRIMARY KEY,
+	url TEXT NOT NULL,
+	cutoff INTEGER NOT NULL,
+	subforums INTEGER NOT NULL,
+	started INTEGER NOT NULL,
+	finished INTEGER
+);
+
+CREATE TABLE crawl_task (
+	id INTEGER NOT NULL PRIMARY KEY,
+	crawl_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	url TEXT NOT NULL,
+	page INTEGER NOT NULL DEFAULT 0,
+	forum_id INTEGER,
+	thread_id INTEGER,
+	state TEXT NOT NULL DEFAULT 'pending',
+	error TEXT,
+	updated INTEGER,
+
+	UNIQUE(crawl_id, kind, url, page)
+);
+
+CREATE INDEX crawl_task_state_idx ON crawl_task (crawl_id, state);`,
+	},
+	{
+		Version:     6,
+		Description: "Record each distinct content seen for a comment",
+		// Existing comments get a first revision dated when they were
+		// published, since when they were scraped was not recorded.
+		Stmt: `
+CREATE TABLE comment_revision (
+	id INTEGER NOT NULL PRIMARY KEY,
+	comment_id INTEGER NOT NULL,
+	content TEXT,
+	seen INTEGER NOT NULL
+);
+
+CREATE INDEX comment_revision_comment_idx ON comment_revision (comment_id, id);
+
+INSERT INTO comment_revision (comment_id, content, seen)
+	SELECT id, content, published FROM comment;
+
+CREATE TRIGGER comment_revision_insert AFTER INSERT ON comment BEGIN
+	INSERT INTO comment_revision (comment_id, content, seen)
+		VALUES (new.id, new.content, CAST(strftime('%s', 'now') AS INTEGER));
+END;
+
+CREATE TRIGGER comment_revision_update AFTER UPDATE OF content ON comment
+	WHEN old.content IS NOT new.content
+BEGIN
+	INSERT INTO comment_revision (comment_id, content, seen)
+		VALUES (new.id, new.content, CAST(strftime('%s', 'now') AS INTEGER));
+END;`,
+	},
+	{
+		Version:     7,
+		Description: "Track when comments were last seen and when they disappeared",
+		Stmt: `
+ALTER TABLE comment ADD COLUMN last_seen INTEGER;
+ALTER TABLE comment ADD COLUMN removed_at INTEGER;
+
+CREATE INDEX comment_removed_idx ON comment (removed_at) WHERE removed_at IS NOT NULL;`,
+	},
+	{
+		Version:     8,
+		Description: "Record the posts and authors quoted in each comment",
+		// The quoted comment is found by joining quoted_url to comment.url,
+		// since it may be scraped after the comment quoting it.
+		Stmt: `
+CREATE TABLE comment_quote (
+	comment_id INTEGER NOT NULL,
+	quoted_url TEXT,
+	quoted_author_id INTEGER,
+	quoted_post_id TEXT,
+	quoted_member_id TEXT
+);
+
+CREATE INDEX comment_quote_comment_idx ON comment_quote (comment_id);
+CREATE INDEX comment_quote_url_idx ON comment_quote (quoted_url);
+CREATE INDEX comment_quote_author_idx ON comment_quote (quoted_author_id);`,
+	},
+	{
+		Version:     9,
+		Description: "Record the links and media in each comment",
+		Stmt: `
+CREATE TABLE comment_link (
+	comment_id INTEGER NOT NULL,
+	url TEXT NOT NULL,
+	domain TEXT,
+	text TEXT
+);
+
+CREATE INDEX comment_link_comment_idx ON comment_link (comment_id);
+CREATE INDEX comment_link_domain_idx ON comment_link (domain);
+
+CREATE TABLE comment_media (
+	comment_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	url TEXT NOT NULL,
+	text TEXT
+);
+
+CREATE INDEX comment_media_comment_idx ON comment_media (comment_id);`,
+	},
+	{
+		Version:     10,
+		Description: "Keep the gzipped HTML comments were extracted from",
+		Stmt: `
+CREATE TABLE comment_html (
+	comment_id INTEGER NOT NULL PRIMARY KEY,
+	html BLOB NOT NULL,
+	captured INTEGER NOT NULL
+);`,
+	},
+	{
+		Version:     11,
+		Description: "Store author profiles keyed by the site's member id",
+		Stmt: `
+CREATE TABLE author_profile (
+	site_id INTEGER NOT NULL,
+	member_id TEXT NOT NULL,
+	author_id INTEGER NOT NULL,
+	title TEXT,
+	joined INTEGER,
+	message_count INTEGER,
+	reaction_score INTEGER,
+	location TEXT,
+	updated INTEGER NOT NULL,
+
+	PRIMARY KEY(site_id, member_id)
+);
+
+CREATE INDEX author_profile_author_idx ON author_profile (author_id);
+
+CREATE TABLE author_profile_name (
+	site_id INTEGER NOT NULL,
+	member_id TEXT NOT NULL,
+	author_id INTEGER NOT NULL,
+	first_seen INTEGER NOT NULL,
+
+	UNIQUE(site_id, member_id, author_id)
+);`,
+	},
+	{
+		Version:     12,
+		Description: "Identify authors by the site's user id and keep their usernames as aliases",
+		Stmt: `
+ALTER TABLE author ADD COLUMN external_id TEXT;
+
+CREATE TABLE author_alias (
+	author_id INTEGER NOT NULL,
+	username TEXT NOT NULL,
+	first_seen INTEGER NOT NULL,
+
+	UNIQUE(author_id, username)
+);
+
+INSERT OR IGNORE INTO author_alias
+	(author_id, username, first_seen)
+SELECT n.author_id, a.username, n.first_seen
+FROM author_profile_name n
+	JOIN author a ON a.id = n.author_id;
+
+INSERT OR IGNORE INTO author_alias
+	(author_id, username, first_seen)
+SELECT id, username, 0
+FROM author
+WHERE username IS NOT NULL;`,
+		apply: mergeProfiledAuthors,
+	},
+	{
+		Version:     13,
+		Description: "Record comment scores and reactions, keeping their history",
+		Stmt: `
+ALTER TABLE comment ADD COLUMN score INTEGER;
+ALTER TABLE comment ADD COLUMN reaction_count INTEGER;
+
+CREATE TABLE comment_reaction (
+	comment_id INTEGER NOT NULL,
+	kind TEXT NOT NULL,
+	count INTEGER NOT NULL,
+
+	UNIQUE(comment_id, kind)
+);
+
+CREATE TABLE comment_score (
+	id INTEGER NOT NULL PRIMARY KEY,
+	comment_id INTEGER NOT NULL,
+	score INTEGER,
+	reaction_count INTEGER,
+	seen INTEGER NOT NULL
+);
+
+CREATE INDEX comment_score_comment_idx ON comment_score (comment_id, id);
+
+CREATE TRIGGER comment_score_update AFTER UPDATE OF score, reaction_count ON comment
+	WHEN old.score IS NOT new.score OR old.reaction_count IS NOT new.reaction_count
+BEGIN
+	INSERT INTO comment_score (comment_id, score, reaction_count, seen)
+		VALUES (new.id, new.score, new.reaction_count, CAST(strftime('%s', 'now') AS INTEGER));
+END;`,
+	},
+	{
+		Version:     14,
+		Description: "Link threaded replies to the comment they reply to",
+		Stmt: `
+ALTER TABLE comment ADD COLUMN parent_id INTEGER;
+
+CREATE INDEX comment_parent_idx ON comment (parent_id) WHERE parent_id IS NOT NULL;`,
+	},
+	{
+		Version:     15,
+		Description: "Keep the cookies of signed-in sessions between runs",
+		Stmt: `
+CREATE TABLE site_session (
+	hostname TEXT NOT NULL PRIMARY KEY,
+	cookies TEXT NOT NULL,
+	updated INTEGER NOT NULL
+);`,
+	},
+	{
+		Version:     16,
+		Description: "Save watches that alert on new comments matching a query",
+		Stmt: `
+CREATE TABLE watch (
+	id INTEGER NOT NULL PRIMARY KEY,
+	name TEXT NOT NULL UNIQUE,
+	kind TEXT NOT NULL,
+	query TEXT NOT NULL,
+	site TEXT,
+	forum_id INTEGER,
+	thread_id INTEGER,
+	author TEXT,
+	tag TEXT,
+	sink TEXT NOT NULL,
+	high_water INTEGER NOT NULL,
+	created INTEGER NOT NULL
+);`,
+	},
+	{
+		Version:     17,
+		Description: "Record the scrapes run by the daemon",
+		Stmt: `
+CREATE TABLE scrape_run (
+	id INTEGER NOT NULL PRIMARY KEY,
+	target TEXT NOT NULL,
+	started INTEGER NOT NULL,
+	finished INTEGER,
+	pages INTEGER NOT NULL DEFAULT 0,
+	new_threads INTEGER NOT NULL DEFAULT 0,
+	new_comments INTEGER NOT NULL DEFAULT 0,
+	error TEXT
+);
+
+CREATE INDEX scrape_run_target_idx ON scrape_run (target, started);`,
+	},
+	{
+		Version:     18,
+		Description: "Record the cutoff, responses and content changes of every scrape",
+		Stmt: `
+ALTER TABLE scrape_run RENAME COLUMN pages TO requests;
+ALTER TABLE scrape_run ADD COLUMN cutoff INTEGER;
+ALTER TABLE scrape_run ADD COLUMN failed_requests INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_run ADD COLUMN statuses TEXT;
+ALTER TABLE scrape_run ADD COLUMN bytes INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_run ADD COLUMN updated_threads INTEGER NOT NULL DEFAULT 0;
+ALTER TABLE scrape_run ADD COLUMN updated_comments INTEGER NOT NULL DEFAULT 0;
+
+CREATE INDEX scrape_run_started_idx ON scrape_run (started);`,
+	},
+}
+
+// Merges the authors recorded for each profile into the author the profile
+// currently names, then replaces author_profile_name with the author's
+// external id.
+func mergeProfiledAuthors(tx *sql.Tx, m Migration) (err error) {
+	if _, err = tx.Exec(m.Stmt); err != nil {
+		return
+	}
+
+	type merge struct{ keeper, other uint }
+	var merges []merge
+	var rows *sql.Rows
+	if rows, err = tx.Query(`
+		SELECT p.author_id, n.author_id
+		FROM author_profile_name n
+			JOIN author_profile p ON p.site_id = n.site_id AND p.member_id = n.member_id
+		WHERE n.author_id != p.author_id`); err != nil {
+		return
+	}
+	for rows.Next() {
+		var mg merge
+		if err = rows.Scan(&mg.keeper, &mg.other); err != nil {
+			break
+		}
+		merges = append(merges, mg)
+	}
+	rows.Close()
+	if err != nil {
+		return
+	}
+
+	for _, mg := range merges {
+		for _, stmt := range mergeAuthorStmts {
+			if _, err = tx.Exec(stmt, mg.keeper, mg.other); err != nil {
+				return
+			}
+		}
+	}
+
+	_, err = tx.Exec(`
+		UPDATE author SET external_id = (SELECT member_id FROM author_profile p WHERE p.author_id = author.id);
+		DROP TABLE author_profile_name;
+		CREATE UNIQUE INDEX author_external_idx ON author (site_id, external_id);`)
+	return
+}
+
+func (sdb *ScraperDB) initSchemaVersionTable() (err error) {
+	_, err = sdb.DB.Exec(`
+		CREATE TABLE IF NOT EXISTS schema_version (
+			version INTEGER NOT NULL PRIMARY KEY,
+			description TEXT,
+			applied INTEGER
+		)`)
+	return
+}
+
+// Returns the highest migration version applied to the database, or zero if
+// no migrations have been applied. The database is left unchanged.
+func (sdb *ScraperDB) SchemaVersion() (version uint, err error) {
+	var exists bool
+	if err = sdb.DB.QueryRow(
+		`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'schema_version'`).Scan(&exists); err == nil && exists {
+		err = sdb.DB.QueryRow(`SELECT COALESCE(MAX(version), 0) FROM schema_version`).Scan(&version)
+	}
+	return
+}
+
+// Returns the migrations that have not yet been applied, in the order they
+// would be applied.
+func (sdb *ScraperDB) PendingMigrations() (pending []Migration, err error) {
+	var version uint
+	if version, err = sdb.SchemaVersion(); err == nil {
+		for _, m := range migrations {
+			if m.Version > version {
+				pending = append(pending, m)
+			}
+		}
+	}
+	return
+}
+
+// Applies all pending migrations. Each migration runs in its own transaction,
+// so a failure leaves the database at the last successfully applied version.
+func (sdb *ScraperDB) Migrate() (err error) {
+	var pending []Migration
+	if err = sdb.initSchemaVersionTable(); err != nil {
+		return
+	}
+	if pending, err = sdb.PendingMigrations(); err == nil {
+		for _, m := range pending {
+			if err = sdb.applyMigration(m); err != nil {
+				break
+			}
+		}
+	}
+	return
+}
+
+func (sdb *ScraperDB) applyMigration(m Migration) (err error) {
+	var tx *sql.Tx
+	if tx, err = sdb.DB.Begin(); err != nil {
+		return
+	}
+
+	if m.apply != nil {
+		err = m.apply(tx, m)
+	} else {
+		_, err = tx.Exec(m.Stmt)
+	}
+
+	if err == nil {
+		_, err = tx.Exec(
+			`INSERT INTO schema_version
+				(version, description, applied)
+			VALUES
+				(?, ?, ?)`,
+			m.Version, m.Description, time.Now().Unix())
+	}
+
+	if err != nil {
+		tx.Rollback()
+		return fmt.Errorf("Migration %d (%s) failed: %w", m.Version, m.Description, err)
+	}
+	return tx.Commit()
+}
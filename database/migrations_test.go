@@ -0,0 +1,112 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestMigrations(t *testing.T) {
+	tmpDir := t.TempDir()
+
+	db, err := OpenScraperDB(tmpDir + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	version, err := db.SchemaVersion()
+	require.Nil(t, err)
+	require.Equal(t, migrations[len(migrations)-1].Version, version)
+
+	pending, err := db.PendingMigrations()
+	require.Nil(t, err)
+	require.Empty(t, pending)
+
+	// Migrating an up-to-date database is a no-op
+	require.Nil(t, db.Migrate())
+}
+
+func TestMigrateLegacyDatabase(t *testing.T) {
+	tmpDir := t.TempDir()
+	path := tmpDir + "/legacy.db"
+
+	// Databases created before migrations existed have tables but no schema_version
+	legacy, err := OpenScraperDBWithoutMigrating(path)
+	require.Nil(t, err)
+	_, err = legacy.DB.Exec(`
+		CREATE TABLE site (
+			id INTEGER NOT NULL PRIMARY KEY,
+			hostname STRING UNIQUE
+		);
+		INSERT INTO site (hostname) VALUES ('some-forum.com');`)
+	require.Nil(t, err)
+
+	pending, err := legacy.PendingMigrations()
+	require.Nil(t, err)
+	require.Equal(t, len(migrations), len(pending))
+
+	// Reading the version, as migrate --dry-run does, doesn't create the table
+	version, err := legacy.SchemaVersion()
+	require.Nil(t, err)
+	require.Equal(t, uint(0), version)
+	var tables int
+	require.Nil(t, legacy.DB.QueryRow(
+		`SELECT COUNT(*) FROM sqlite_master WHERE name = 'schema_version'`).Scan(&tables))
+	require.Equal(t, 0, tables)
+	legacy.Close()
+
+	db, err := OpenScraperDB(path)
+	require.Nil(t, err)
+	defer db.Close()
+
+	pending, err = db.PendingMigrations()
+	require.Nil(t, err)
+	require.Empty(t, pending)
+
+	siteId, err := db.GetSiteId("some-forum.com")
+	require.Nil(t, err)
+	require.Greater(t, siteId, model.SiteID(0))
+}
+
+func TestMigrateProfiledAuthors(t *testing.T) {
+	db, err := OpenScraperDBWithoutMigrating(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	// A member seen as alice and then alicia had an author for each name
+	require.Nil(t, db.initSchemaVersionTable())
+	for _, m := range migrations {
+		if m.Version < 12 {
+			require.Nil(t, db.applyMigration(m))
+		}
+	}
+	_, err = db.DB.Exec(`
+		INSERT INTO site (id, hostname) VALUES (1, 'forum.example.com');
+		INSERT INTO author (id, site_id, username) VALUES (1, 1, 'alice'), (2, 1, 'alicia'), (3, 1, 'bob');
+		INSERT INTO thread (id, forum_id, author_id, url) VALUES (1, 1, 1, 'https://forum.example.com/threads/t.1/');
+		INSERT INTO comment (thread_id, url, author_id, published, content) VALUES
+			(1, 'https://forum.example.com/posts/1', 1, 1, 'one'),
+			(1, 'https://forum.example.com/posts/2', 2, 2, 'two'),
+			(1, 'https://forum.example.com/posts/3', 3, 3, 'three');
+		INSERT INTO author_profile (site_id, member_id, author_id, updated) VALUES (1, '1', 2, 0);
+		INSERT INTO author_profile_name (site_id, member_id, author_id, first_seen) VALUES (1, '1', 1, 10), (1, '1', 2, 20);`)
+	require.Nil(t, err)
+
+	require.Nil(t, db.Migrate())
+
+	details, err := db.AuthorDetails("alice")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(details))
+	require.Equal(t, model.AuthorID(2), details[0].Id)
+	require.Equal(t, "alicia", details[0].Username)
+	require.Equal(t, "1", details[0].ExternalId)
+	require.Equal(t, []string{"alice"}, details[0].Aliases)
+	require.Equal(t, uint(2), details[0].Comments)
+	require.Equal(t, uint(1), details[0].ThreadsStarted)
+
+	details, err = db.AuthorDetails("bob")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(details))
+	require.Equal(t, "", details[0].ExternalId)
+	require.Empty(t, details[0].Aliases)
+}
@@ -0,0 +1,117 @@
+package database
+
+import (
+	"database/sql"
+
+	"github.com/zvonler/espy/model"
+)
+
+func (sdb *ScraperDB) setCommentQuotes(siteId model.SiteID, commentId model.CommentID, quotes []model.Quote) (err error) {
+	sdb.ExecOrPanic(`DELETE FROM comment_quote WHERE comment_id = ?`, commentId)
+	for _, q := range quotes {
+		var quotedURL, quotedAuthorId any
+		if q.URL != nil {
+			quotedURL = q.URL.String()
+		}
+		if q.Author != "" {
+			var authorId model.AuthorID
+			if authorId, err = sdb.getOrInsertQuotedAuthor(q.Author, q.MemberId, siteId); err != nil {
+				return
+			}
+			quotedAuthorId = authorId
+		}
+		sdb.ExecOrPanic(
+			`INSERT INTO comment_quote
+				(comment_id, quoted_url, quoted_author_id, quoted_post_id, quoted_member_id)
+			VALUES
+				(?, ?, ?, ?, ?)`,
+			commentId, quotedURL, quotedAuthorId, q.PostId, q.MemberId)
+	}
+	return
+}
+
+// An edge in a thread's reply graph: From quoted To in Count comments.
+type ReplyEdge struct {
+	From  string
+	To    string
+	Count uint
+}
+
+// Returns who quoted whom in a thread, most frequent first. The quoted author
+// comes from the quoted comment when it has been scraped, and otherwise from
+// the quote's attribution.
+func (sdb *ScraperDB) ThreadReplyEdges(threadId model.ThreadID) (edges []ReplyEdge, err error) {
+	stmt := `
+		SELECT
+			a.username, COALESCE(qca.username, qa.username) quoted, COUNT(DISTINCT c.id) n
+		FROM comment c
+			JOIN author a ON a.id = c.author_id
+			JOIN comment_quote q ON q.comment_id = c.id
+			LEFT JOIN comment qc ON qc.url = q.quoted_url
+			LEFT JOIN author qca ON qca.id = qc.author_id
+			LEFT JOIN author qa ON qa.id = q.quoted_author_id
+		WHERE
+				c.thread_id = ?
+			AND quoted IS NOT NULL
+		GROUP BY a.username, quoted
+		ORDER BY n DESC, a.username, quoted`
+
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var e ReplyEdge
+			if err = rows.Scan(&e.From, &e.To, &e.Count); err != nil {
+				panic(err)
+			}
+			edges = append(edges, e)
+		},
+		stmt, threadId)
+	return
+}
+
+// How often an author quoted, and was quoted by, another author.
+type Interaction struct {
+	Username string
+	Quoted   uint
+	QuotedBy uint
+}
+
+// Returns the authors that username has quoted or been quoted by, across all
+// sites, most interactions first.
+func (sdb *ScraperDB) AuthorInteractions(username string) (interactions []Interaction, err error) {
+	stmt := `
+		WITH edge AS (
+			SELECT
+				c.id comment_id, a.username quoting,
+				COALESCE(qca.username, qa.username) quoted
+			FROM comment c
+				JOIN author a ON a.id = c.author_id
+				JOIN comment_quote q ON q.comment_id = c.id
+				LEFT JOIN comment qc ON qc.url = q.quoted_url
+				LEFT JOIN author qca ON qca.id = qc.author_id
+				LEFT JOIN author qa ON qa.id = q.quoted_author_id
+		)
+		SELECT
+			other, SUM(quoted_n), SUM(quoted_by_n)
+		FROM (
+			SELECT quoted other, COUNT(DISTINCT comment_id) quoted_n, 0 quoted_by_n
+			FROM edge WHERE quoting = ?1 AND quoted IS NOT NULL AND quoted != ?1
+			GROUP BY quoted
+			UNION ALL
+			SELECT quoting other, 0, COUNT(DISTINCT comment_id)
+			FROM edge WHERE quoted = ?1 AND quoting != ?1
+			GROUP BY quoting
+		)
+		GROUP BY other
+		ORDER BY SUM(quoted_n) + SUM(quoted_by_n) DESC, other`
+
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var i Interaction
+			if err = rows.Scan(&i.Username, &i.Quoted, &i.QuotedBy); err != nil {
+				panic(err)
+			}
+			interactions = append(interactions, i)
+		},
+		stmt, username)
+	return
+}
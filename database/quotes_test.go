@@ -0,0 +1,51 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestQuotes(t *testing.T) {
+	tt := newTestThread(t, "alice")
+	post := func(id string, author string, quotes ...model.Quote) model.Comment {
+		c := tt.post(id, author)
+		c.Quotes = quotes
+		return c
+	}
+	quote := func(id string, author string) model.Quote {
+		return model.Quote{URL: tt.url.JoinPath("post-" + id), Author: author, PostId: id}
+	}
+
+	// Bob's reply is scraped before the post it quotes, and carol quotes a
+	// post in another thread
+	tt.add(t,
+		post("102", "bob", quote("101", "alice")),
+		post("1003", "carol", quote("101", "alice"), quote("102", "bob"), model.Quote{Author: "dave", PostId: "7"}),
+	)
+	tt.add(t, post("101", "alice"))
+
+	edges, err := tt.db.ThreadReplyEdges(tt.threadId)
+	require.Nil(t, err)
+	require.Equal(t, []ReplyEdge{
+		{"bob", "alice", 1},
+		{"carol", "alice", 1},
+		{"carol", "bob", 1},
+		{"carol", "dave", 1},
+	}, edges)
+
+	interactions, err := tt.db.AuthorInteractions("alice")
+	require.Nil(t, err)
+	require.Equal(t, []Interaction{{"bob", 0, 1}, {"carol", 0, 1}}, interactions)
+
+	interactions, err = tt.db.AuthorInteractions("bob")
+	require.Nil(t, err)
+	require.Equal(t, []Interaction{{"alice", 1, 0}, {"carol", 0, 1}}, interactions)
+
+	// Re-scraping an edited comment replaces its quotes
+	tt.add(t, post("102", "bob"))
+	interactions, err = tt.db.AuthorInteractions("bob")
+	require.Nil(t, err)
+	require.Equal(t, []Interaction{{"carol", 0, 1}}, interactions)
+}
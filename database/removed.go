@@ -0,0 +1,71 @@
+package database
+
+import (
+	"database/sql"
+	"net/url"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+// Marks as removed the comments of a thread published between earliest and
+// latest, inclusive, that have not been seen since notSeenSince. Scrapers call
+// this with the time range of each page they fetched, after storing the
+// page's comments, so a post that vanished from the page is noticed. Returns
+// the number of comments newly marked.
+func (sdb *ScraperDB) MarkRemovedComments(threadId model.ThreadID, earliest, latest, notSeenSince time.Time) (count int64) {
+	sdb.writeMutex.Lock()
+	defer sdb.writeMutex.Unlock()
+
+	result, err := sdb.DB.Exec(
+		`UPDATE comment SET removed_at = ?
+		WHERE
+				thread_id = ?
+			AND published BETWEEN ? AND ?
+			AND removed_at IS NULL
+			AND (last_seen IS NULL OR last_seen < ?)`,
+		time.Now().Unix(), threadId, earliest.Unix(), latest.Unix(), notSeenSince.Unix())
+	if err != nil {
+		panic(err)
+	}
+	count, _ = result.RowsAffected()
+	return
+}
+
+type RemovedComment struct {
+	model.Comment
+	ThreadId  model.ThreadID
+	RemovedAt time.Time
+}
+
+// Returns comments removed since the given time, most recently removed
+// first, limited to one thread unless threadId is zero.
+func (sdb *ScraperDB) RemovedComments(threadId model.ThreadID, since time.Time) (comments []RemovedComment, err error) {
+	stmt := `
+		SELECT
+			c.url, a.username, c.published, c.content, c.thread_id, c.removed_at
+		FROM comment c
+			JOIN author a ON a.id = c.author_id
+		WHERE
+				c.removed_at >= ?
+			AND (? = 0 OR c.thread_id = ?)
+		ORDER BY c.removed_at DESC, c.published`
+
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var c RemovedComment
+			var urlStr string
+			var published, removedAt int64
+			if err = rows.Scan(&urlStr, &c.Author, &published, &c.Content, &c.ThreadId, &removedAt); err != nil {
+				panic(err)
+			}
+			if c.URL, err = url.Parse(urlStr); err != nil {
+				panic(err)
+			}
+			c.Published = time.Unix(published, 0)
+			c.RemovedAt = time.Unix(removedAt, 0)
+			comments = append(comments, c)
+		},
+		stmt, since.Unix(), threadId, threadId)
+	return
+}
@@ -0,0 +1,49 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+)
+
+type CommentRevision struct {
+	Seen    time.Time
+	Content string
+}
+
+var ErrCommentNotFound = errors.New("Comment not found")
+
+// Returns the distinct contents seen for the comment at u, oldest first.
+func (sdb *ScraperDB) CommentRevisions(u *url.URL) (revisions []CommentRevision, err error) {
+	var found bool
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			found = true
+		},
+		`SELECT id FROM comment WHERE url = ?`,
+		u.String())
+	if !found {
+		return nil, ErrCommentNotFound
+	}
+
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var rev CommentRevision
+			var seen int64
+			var content sql.NullString
+			if err = rows.Scan(&content, &seen); err != nil {
+				panic(err)
+			}
+			rev.Content = content.String
+			rev.Seen = time.Unix(seen, 0)
+			revisions = append(revisions, rev)
+		},
+		`SELECT r.content, r.seen
+		FROM comment_revision r
+			JOIN comment c ON c.id = r.comment_id
+		WHERE c.url = ?
+		ORDER BY r.id`,
+		u.String())
+	return
+}
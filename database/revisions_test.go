@@ -0,0 +1,38 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCommentRevisions(t *testing.T) {
+	tt := newTestThread(t, "alice")
+	comment := tt.post("1", "alice")
+	commentURL := comment.URL
+
+	_, err := tt.db.CommentRevisions(commentURL)
+	require.Equal(t, ErrCommentNotFound, err)
+
+	comment.Content = "Original"
+	tt.add(t, comment)
+
+	// Re-scraping unchanged content adds no revision
+	tt.add(t, comment)
+	revisions, err := tt.db.CommentRevisions(commentURL)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(revisions))
+	require.Equal(t, "Original", revisions[0].Content)
+
+	comment.Content = "Edited"
+	tt.add(t, comment)
+	revisions, err = tt.db.CommentRevisions(commentURL)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(revisions))
+	require.Equal(t, "Edited", revisions[1].Content)
+
+	comments, err := tt.db.ThreadComments(tt.threadId)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(comments))
+	require.Equal(t, "Edited", comments[0].Content)
+}
@@ -0,0 +1,204 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+type ScrapeRunID uint
+
+// A single scrape of a forum, thread or site, from the scrape commands or the
+// daemon.
+type ScrapeRun struct {
+	Id       ScrapeRunID
+	Target   string
+	Cutoff   time.Time
+	Started  time.Time
+	Finished time.Time // Zero while the run is in progress
+
+	// Requests made to the target's host during the run, the number of
+	// them that got no response, the number of responses with each status
+	// code, and the response bytes read.
+	Requests       uint
+	FailedRequests uint
+	Statuses       map[int]uint
+	Bytes          uint64
+
+	ContentChanges
+
+	// Empty if the run succeeded.
+	Error string
+}
+
+func (run ScrapeRun) Duration() time.Duration {
+	if run.Finished.IsZero() {
+		return 0
+	}
+	return run.Finished.Sub(run.Started)
+}
+
+var ErrNoScrapeRun = errors.New("No scrape run found")
+
+func (sdb *ScraperDB) StartScrapeRun(target string, cutoff, started time.Time) (id ScrapeRunID, err error) {
+	sdb.WriteRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&id)
+		},
+		`INSERT INTO scrape_run
+			(target, cutoff, started)
+		VALUES
+			(?, ?, ?)
+		RETURNING id`,
+		target, cutoff.Unix(), started.Unix())
+	return
+}
+
+// Records the outcome of a run started with StartScrapeRun.
+func (sdb *ScraperDB) FinishScrapeRun(run ScrapeRun) (err error) {
+	var statuses []byte
+	if statuses, err = json.Marshal(run.Statuses); err == nil {
+		sdb.ExecOrPanic(
+			`UPDATE scrape_run SET
+				finished = ?, requests = ?, failed_requests = ?, statuses = ?, bytes = ?,
+				new_threads = ?, updated_threads = ?, new_comments = ?, updated_comments = ?,
+				error = NULLIF(?, '')
+			WHERE id = ?`,
+			run.Finished.Unix(), run.Requests, run.FailedRequests, string(statuses), run.Bytes,
+			run.NewThreads, run.UpdatedThreads, run.NewComments, run.UpdatedComments,
+			run.Error, run.Id)
+	}
+	return
+}
+
+const scrapeRunColumns = `
+	id, target, COALESCE(cutoff, 0), started, COALESCE(finished, 0),
+	requests, failed_requests, COALESCE(statuses, '{}'), bytes,
+	new_threads, updated_threads, new_comments, updated_comments, COALESCE(error, '')`
+
+func scanScrapeRun(rows *sql.Rows) (run ScrapeRun, err error) {
+	var cutoff, started, finished int64
+	var statuses string
+	if err = rows.Scan(&run.Id, &run.Target, &cutoff, &started, &finished,
+		&run.Requests, &run.FailedRequests, &statuses, &run.Bytes,
+		&run.NewThreads, &run.UpdatedThreads, &run.NewComments, &run.UpdatedComments,
+		&run.Error); err == nil {
+		if cutoff != 0 {
+			run.Cutoff = time.Unix(cutoff, 0)
+		}
+		run.Started = time.Unix(started, 0)
+		if finished != 0 {
+			run.Finished = time.Unix(finished, 0)
+		}
+		err = json.Unmarshal([]byte(statuses), &run.Statuses)
+	}
+	return
+}
+
+// Returns the run with the given id, or ErrNoScrapeRun.
+func (sdb *ScraperDB) GetScrapeRun(id ScrapeRunID) (run ScrapeRun, err error) {
+	err = fmt.Errorf("%w: %d", ErrNoScrapeRun, id)
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			run, err = scanScrapeRun(rows)
+		},
+		"SELECT "+scrapeRunColumns+" FROM scrape_run WHERE id = ?", id)
+	return
+}
+
+// Returns the most recently started run of target, or ErrNoScrapeRun.
+func (sdb *ScraperDB) LastScrapeRun(target string) (run ScrapeRun, err error) {
+	err = ErrNoScrapeRun
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			run, err = scanScrapeRun(rows)
+		},
+		"SELECT "+scrapeRunColumns+" FROM scrape_run WHERE target = ? ORDER BY started DESC, id DESC LIMIT 1",
+		target)
+	return
+}
+
+// Returns up to limit runs started since the given time, newest first,
+// limited to targets containing target if it is not empty, and to runs with
+// errors if failed is set.
+func (sdb *ScraperDB) ScrapeRuns(target string, since time.Time, failed bool, limit uint) (runs []ScrapeRun, err error) {
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var run ScrapeRun
+			if err == nil {
+				if run, err = scanScrapeRun(rows); err == nil {
+					runs = append(runs, run)
+				}
+			}
+		},
+		`SELECT `+scrapeRunColumns+` FROM scrape_run
+		WHERE
+			instr(target, ?) > 0
+			AND started >= ?
+			AND (? = 0 OR error IS NOT NULL)
+		ORDER BY started DESC, id DESC
+		LIMIT ?`,
+		target, since.Unix(), failed, limit)
+	return
+}
+
+/*---------------------------------------------------------------------------*/
+
+// The newest thread, comment and comment revision ids at some moment, for
+// counting the changes made afterward.
+type ContentMark struct {
+	Thread   model.ThreadID
+	Comment  model.CommentID
+	Revision uint
+}
+
+func (sdb *ScraperDB) CurrentContentMark() (mark ContentMark) {
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			rows.Scan(&mark.Thread, &mark.Comment, &mark.Revision)
+		},
+		`SELECT
+			(SELECT COALESCE(MAX(id), 0) FROM thread),
+			(SELECT COALESCE(MAX(id), 0) FROM comment),
+			(SELECT COALESCE(MAX(id), 0) FROM comment_revision)`)
+	return
+}
+
+// Comments are updated when their content is edited; threads are updated
+// when they gain or edit comments.
+type ContentChanges struct {
+	NewThreads      uint
+	UpdatedThreads  uint
+	NewComments     uint
+	UpdatedComments uint
+}
+
+// Counts the changes to the site's threads and comments since mark was taken.
+func (sdb *ScraperDB) ContentChangesSince(mark ContentMark, hostname string) (changes ContentChanges) {
+	// Every new comment and every edit gets a revision
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			rows.Scan(&changes.NewThreads, &changes.UpdatedThreads, &changes.NewComments, &changes.UpdatedComments)
+		},
+		`WITH
+			site_thread AS (
+				SELECT t.id FROM thread t
+					JOIN forum f ON f.id = t.forum_id
+					JOIN site s ON s.id = f.site_id
+				WHERE s.hostname = ?),
+			revised AS (
+				SELECT DISTINCT r.comment_id, c.thread_id FROM comment_revision r
+					JOIN comment c ON c.id = r.comment_id
+				WHERE r.id > ? AND c.thread_id IN site_thread)
+		SELECT
+			(SELECT COUNT(*) FROM site_thread WHERE id > ?),
+			(SELECT COUNT(DISTINCT thread_id) FROM revised WHERE thread_id <= ?),
+			(SELECT COUNT(*) FROM revised WHERE comment_id > ?),
+			(SELECT COUNT(*) FROM revised WHERE comment_id <= ?)`,
+		hostname, mark.Revision, mark.Thread, mark.Thread, mark.Comment, mark.Comment)
+	return
+}
@@ -0,0 +1,194 @@
+package database
+
+import (
+	"database/sql"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+func (sdb *ScraperDB) setCommentReactions(commentId model.CommentID, reactions []model.Reaction) {
+	sdb.ExecOrPanic(`DELETE FROM comment_reaction WHERE comment_id = ?`, commentId)
+	for _, r := range reactions {
+		sdb.ExecOrPanic(
+			`INSERT INTO comment_reaction
+				(comment_id, kind, count)
+			VALUES
+				(?, ?, ?)
+			ON CONFLICT DO NOTHING`,
+			commentId, r.Kind, r.Count)
+	}
+}
+
+// What comments and authors are ranked by.
+type Ranking string
+
+const (
+	ByScore     Ranking = "score"
+	ByReactions Ranking = "reactions"
+)
+
+var Rankings = []Ranking{ByScore, ByReactions}
+
+func ParseRanking(s string) (Ranking, error) {
+	for _, r := range Rankings {
+		if string(r) == strings.ToLower(s) {
+			return r, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown ranking %q", s)
+}
+
+func (r Ranking) column() string {
+	if r == ByReactions {
+		return "c.reaction_count"
+	}
+	return "c.score"
+}
+
+// A comment with its current score and reactions. Score and ReactionCount
+// are nil when the engine doesn't report them.
+type RankedComment struct {
+	URL           *url.URL
+	Author        string
+	Published     time.Time
+	Score         *int
+	ReactionCount *uint
+	Reactions     []model.Reaction
+}
+
+// Returns the highest ranked comments in a thread, at most limit of them.
+// Comments without the ranked value are left out.
+func (sdb *ScraperDB) TopComments(threadId model.ThreadID, by Ranking, limit uint) (comments []RankedComment, err error) {
+	ids := make(map[model.CommentID]int)
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var rc RankedComment
+			var id model.CommentID
+			var urlStr string
+			var published int64
+			var score, reactionCount sql.NullInt64
+			if err = rows.Scan(&id, &urlStr, &rc.Author, &published, &score, &reactionCount); err == nil {
+				rc.URL, err = url.Parse(urlStr)
+			}
+			if err != nil {
+				panic(err)
+			}
+			rc.Published = time.Unix(published, 0)
+			if score.Valid {
+				s := int(score.Int64)
+				rc.Score = &s
+			}
+			if reactionCount.Valid {
+				n := uint(reactionCount.Int64)
+				rc.ReactionCount = &n
+			}
+			ids[id] = len(comments)
+			comments = append(comments, rc)
+		},
+		`SELECT
+			c.id, c.url, a.username, c.published, c.score, c.reaction_count
+		FROM comment c
+			JOIN author a ON a.id = c.author_id
+		WHERE
+				c.thread_id = ?
+			AND `+by.column()+` IS NOT NULL
+		ORDER BY `+by.column()+` DESC, c.published
+		LIMIT ?`,
+		threadId, limit)
+
+	for id, i := range ids {
+		sdb.ForEachRowOrPanic(
+			func(rows *sql.Rows) {
+				var r model.Reaction
+				rows.Scan(&r.Kind, &r.Count)
+				comments[i].Reactions = append(comments[i].Reactions, r)
+			},
+			`SELECT kind, count FROM comment_reaction WHERE comment_id = ? ORDER BY rowid`,
+			id)
+	}
+	return
+}
+
+// An author's total score or reactions over their comments.
+type RankedAuthor struct {
+	Username string
+	Site     string
+	Comments uint
+	Total    int
+}
+
+// Returns the authors whose comments ranked highest in total, at most limit
+// of them. Only comments with the ranked value are counted.
+func (sdb *ScraperDB) TopAuthors(by Ranking, limit uint) (authors []RankedAuthor, err error) {
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var ra RankedAuthor
+			if err = rows.Scan(&ra.Username, &ra.Site, &ra.Comments, &ra.Total); err != nil {
+				panic(err)
+			}
+			authors = append(authors, ra)
+		},
+		`SELECT
+			a.username, s.hostname, COUNT(*), SUM(`+by.column()+`) total
+		FROM comment c
+			JOIN author a ON a.id = c.author_id
+			JOIN site s ON s.id = a.site_id
+		WHERE `+by.column()+` IS NOT NULL
+		GROUP BY a.id
+		ORDER BY total DESC, a.username
+		LIMIT ?`,
+		limit)
+	return
+}
+
+// A comment's score and reaction count as first seen with those values.
+type CommentScore struct {
+	Seen          time.Time
+	Score         *int
+	ReactionCount *uint
+}
+
+// Returns the scores seen for the comment at u, oldest first.
+func (sdb *ScraperDB) CommentScores(u *url.URL) (scores []CommentScore, err error) {
+	var found bool
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			found = true
+		},
+		`SELECT id FROM comment WHERE url = ?`,
+		u.String())
+	if !found {
+		return nil, ErrCommentNotFound
+	}
+
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var cs CommentScore
+			var seen int64
+			var score, reactionCount sql.NullInt64
+			if err = rows.Scan(&score, &reactionCount, &seen); err != nil {
+				panic(err)
+			}
+			cs.Seen = time.Unix(seen, 0)
+			if score.Valid {
+				s := int(score.Int64)
+				cs.Score = &s
+			}
+			if reactionCount.Valid {
+				n := uint(reactionCount.Int64)
+				cs.ReactionCount = &n
+			}
+			scores = append(scores, cs)
+		},
+		`SELECT s.score, s.reaction_count, s.seen
+		FROM comment_score s
+			JOIN comment c ON c.id = s.comment_id
+		WHERE c.url = ?
+		ORDER BY s.id`,
+		u.String())
+	return
+}
@@ -0,0 +1,66 @@
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestScores(t *testing.T) {
+	tt := newTestThread(t, "alice")
+	scored := func(id string, author string, score int) model.Comment {
+		c := tt.post(id, author)
+		c.Score = &score
+		return c
+	}
+	unscored := tt.post("d", "dave")
+	tt.add(t, scored("a", "alice", 3), scored("bb", "bob", 10), scored("ccc", "alice", -2), unscored)
+
+	// A re-scrape that sees a new score keeps the old one in the history,
+	// and one without a score leaves the stored score alone
+	tt.add(t, scored("a", "alice", 12))
+	a := scored("a", "alice", 0)
+	a.Score = nil
+	tt.add(t, a)
+
+	scores, err := tt.db.CommentScores(a.URL)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(scores))
+	require.Equal(t, 3, *scores[0].Score)
+	require.Equal(t, 12, *scores[1].Score)
+	require.Nil(t, scores[1].ReactionCount)
+
+	top, err := tt.db.TopComments(tt.threadId, ByScore, 2)
+	require.Nil(t, err)
+	require.Equal(t, 2, len(top))
+	require.Equal(t, a.URL.String(), top[0].URL.String())
+	require.Equal(t, 12, *top[0].Score)
+	require.Equal(t, "bob", top[1].Author)
+
+	authors, err := tt.db.TopAuthors(ByScore, 10)
+	require.Nil(t, err)
+	require.Equal(t, []RankedAuthor{
+		{"alice", "forum.example.com", 2, 10},
+		{"bob", "forum.example.com", 1, 10},
+	}, authors)
+
+	// Reactions replace those seen before
+	reacted := unscored
+	count := uint(4)
+	reacted.ReactionCount = &count
+	reacted.Reactions = []model.Reaction{{Kind: "Like", Count: 4}}
+	tt.add(t, reacted)
+	reacted.Reactions = []model.Reaction{{Kind: "Like"}, {Kind: "Love"}}
+	tt.add(t, reacted)
+
+	top, err = tt.db.TopComments(tt.threadId, ByReactions, 10)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(top))
+	require.Equal(t, uint(4), *top[0].ReactionCount)
+	require.Nil(t, top[0].Score)
+	require.Equal(t, []model.Reaction{{Kind: "Like"}, {Kind: "Love"}}, top[0].Reactions)
+
+	_, err = ParseRanking("views")
+	require.NotNil(t, err)
+}
@@ -4,9 +4,10 @@ import (
 	"database/sql"
 	"errors"
 	"fmt"
-	"log"
 	"net/url"
 	"regexp"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/mattn/go-sqlite3"
@@ -18,29 +19,73 @@ import (
 type ScraperDB struct {
 	Filename string
 	DB       *sql.DB
+
+	// If set, AddComments also stores the HTML of comments that have it.
+	KeepHTML bool
+
+	// Serializes writes from concurrent scrapes, which SQLite would otherwise
+	// reject with SQLITE_BUSY.
+	writeMutex sync.Mutex
+
+	// Set in the ScraperDB passed to a withTransaction writer, whose
+	// statements all run in the transaction under its write lock.
+	tx *sql.Tx
 }
 
+// How long a connection waits for another process's write lock.
+const busyTimeoutMillis = 30000
+
 func regex(re, s string) (bool, error) {
 	return regexp.MatchString(re, s)
 }
 
+var registerDriver sync.Once
+
+// Opens the database at path, creating it if necessary, and applies any
+// pending schema migrations.
 func OpenScraperDB(path string) (sdb *ScraperDB, err error) {
-	sql.Register("sqlite3_regex",
-		&sqlite3.SQLiteDriver{
-			ConnectHook: func(conn *sqlite3.SQLiteConn) error {
-				return conn.RegisterFunc("regexp", regex, true)
-			},
-		})
-
-	if existing_db, err := utils.PathExists(path); err == nil {
-		if db, err := sql.Open("sqlite3_regex", path); err == nil {
-			sdb = new(ScraperDB)
-			sdb.Filename = path
-			sdb.DB = db
-			if !existing_db {
-				sdb.initTables()
+	if sdb, err = OpenScraperDBWithoutMigrating(path); err == nil {
+		if err = sdb.Migrate(); err == nil {
+			if !sdb.FullTextAvailable() {
+				if sdb.HasCommentIndex() {
+					// The index triggers would fail every write to comment
+					err = ErrFullTextUnavailable
+				}
+			} else if !sdb.HasCommentIndex() {
+				// Migrated by a build without FTS5
+				err = sdb.RebuildCommentIndex()
 			}
 		}
+		if err != nil {
+			sdb.Close()
+			sdb = nil
+		}
+	}
+	return
+}
+
+// Opens the database at path without applying migrations, so the caller can
+// inspect the pending ones first.
+func OpenScraperDBWithoutMigrating(path string) (sdb *ScraperDB, err error) {
+	registerDriver.Do(func() {
+		sql.Register("sqlite3_regex",
+			&sqlite3.SQLiteDriver{
+				ConnectHook: func(conn *sqlite3.SQLiteConn) error {
+					return conn.RegisterFunc("regexp", regex, true)
+				},
+			})
+	})
+
+	dsn := path
+	if !strings.Contains(dsn, "?") {
+		dsn += fmt.Sprintf("?_busy_timeout=%d", busyTimeoutMillis)
+	}
+
+	var db *sql.DB
+	if db, err = sql.Open("sqlite3_regex", dsn); err == nil {
+		sdb = new(ScraperDB)
+		sdb.Filename = path
+		sdb.DB = db
 	}
 	return
 }
@@ -51,8 +96,56 @@ func (sdb *ScraperDB) Close() {
 
 type RowsReceiver func(*sql.Rows)
 
+type execQuerier interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func (sdb *ScraperDB) conn() execQuerier {
+	if sdb.tx != nil {
+		return sdb.tx
+	}
+	return sdb.DB
+}
+
+// Takes the write lock, unless in a transaction that already holds it, and
+// returns the function that releases it.
+func (sdb *ScraperDB) lockWrites() (unlock func()) {
+	if sdb.tx != nil {
+		return func() {}
+	}
+	sdb.writeMutex.Lock()
+	return sdb.writeMutex.Unlock
+}
+
+// Runs write in a transaction, holding the write lock until it's committed.
+// Statements made through the ScraperDB passed to write run in the
+// transaction, which is rolled back if write fails or panics. Within a
+// transaction, write is simply called with sdb.
+func (sdb *ScraperDB) withTransaction(write func(tx *ScraperDB) error) (err error) {
+	if sdb.tx != nil {
+		return write(sdb)
+	}
+
+	sdb.writeMutex.Lock()
+	defer sdb.writeMutex.Unlock()
+
+	var tx *sql.Tx
+	if tx, err = sdb.DB.Begin(); err != nil {
+		return
+	}
+	// A no-op once committed
+	defer tx.Rollback()
+
+	if err = write(&ScraperDB{Filename: sdb.Filename, DB: sdb.DB, KeepHTML: sdb.KeepHTML, tx: tx}); err == nil {
+		err = tx.Commit()
+	}
+	return
+}
+
 func (sdb *ScraperDB) ForEachRowOrPanic(receiver RowsReceiver, stmt string, params ...any) {
-	if rows, err := sdb.DB.Query(stmt, params...); err == nil {
+	if rows, err := sdb.conn().Query(stmt, params...); err == nil {
 		defer rows.Close()
 		for rows.Next() {
 			receiver(rows)
@@ -74,15 +167,23 @@ func (sdb *ScraperDB) ForSingleRowOrPanic(receiver RowsReceiver, stmt string, pa
 	sdb.ForEachRowOrPanic(singleReceiver, stmt, params...)
 }
 
+// Like ForSingleRowOrPanic, for statements that modify the database and
+// return a row, such as INSERT ... RETURNING.
+func (sdb *ScraperDB) WriteRowOrPanic(receiver RowsReceiver, stmt string, params ...any) {
+	defer sdb.lockWrites()()
+	sdb.ForSingleRowOrPanic(receiver, stmt, params...)
+}
+
 func (sdb *ScraperDB) ExecOrPanic(stmt string, params ...any) {
-	if _, err := sdb.DB.Exec(stmt, params...); err != nil {
+	defer sdb.lockWrites()()
+	if _, err := sdb.conn().Exec(stmt, params...); err != nil {
 		panic(err)
 	}
 }
 
 func (sdb *ScraperDB) InsertOrUpdateForum(url *url.URL) (siteId model.SiteID, forumId model.ForumID, err error) {
 	if siteId, err = sdb.getOrInsertSite(url.Hostname()); err == nil {
-		sdb.ForSingleRowOrPanic(
+		sdb.WriteRowOrPanic(
 			func(rows *sql.Rows) {
 				err = rows.Scan(&forumId)
 			},
@@ -98,15 +199,17 @@ func (sdb *ScraperDB) InsertOrUpdateForum(url *url.URL) (siteId model.SiteID, fo
 	return
 }
 
+// Returns the comments of the authors who are or were called username.
 func (sdb *ScraperDB) FindAuthorComments(username string) (comments []model.Comment, err error) {
 	sdb.ForEachRowOrPanic(
 		func(rows *sql.Rows) {
 			var urlStr string
+			var author string
 			var published int64
 			var content string
-			if err = rows.Scan(&urlStr, &published, &content); err == nil {
+			if err = rows.Scan(&urlStr, &author, &published, &content); err == nil {
 				if url, err := url.Parse(urlStr); err == nil {
-					comments = append(comments, model.Comment{url, username, time.Unix(published, 0), content})
+					comments = append(comments, model.Comment{URL: url, Author: author, Published: time.Unix(published, 0), Content: content})
 				}
 			}
 			if err != nil {
@@ -114,18 +217,19 @@ func (sdb *ScraperDB) FindAuthorComments(username string) (comments []model.Comm
 			}
 		},
 		`SELECT
-			url, published, content
+			c.url, a.username, c.published, c.content
 		FROM comment c
+			JOIN author a ON a.id = c.author_id
 		WHERE
-			c.author_id IN (SELECT id FROM author WHERE username = ?)`,
+			c.author_id IN (`+authorIdsByAliasStmt+`)`,
 		username)
 
 	return
 }
 
 func (sdb *ScraperDB) InsertOrUpdateThread(siteId model.SiteID, forumId model.ForumID, t model.Thread) (threadId model.ThreadID, err error) {
-	if authorId, err := sdb.getOrInsertAuthor(t.Author, siteId); err == nil {
-		sdb.ForSingleRowOrPanic(
+	if authorId, err := sdb.getOrInsertIdentifiedAuthor(t.Author, t.AuthorExternalId, siteId); err == nil {
+		sdb.WriteRowOrPanic(
 			func(rows *sql.Rows) {
 				err = rows.Scan(&threadId)
 			},
@@ -145,6 +249,22 @@ func (sdb *ScraperDB) InsertOrUpdateThread(siteId model.SiteID, forumId model.Fo
 	return
 }
 
+// Returns the highest post number stored for a thread by engines that number
+// their posts, or zero if none has been recorded.
+func (sdb *ScraperDB) GetThreadHighestPostNumber(threadId model.ThreadID) (postNumber uint, err error) {
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&postNumber)
+		},
+		"SELECT highest_post_number FROM thread WHERE id = ?",
+		threadId)
+	return
+}
+
+func (sdb *ScraperDB) SetThreadHighestPostNumber(threadId model.ThreadID, postNumber uint) {
+	sdb.ExecOrPanic("UPDATE thread SET highest_post_number = ? WHERE id = ?", postNumber, threadId)
+}
+
 func (sdb *ScraperDB) GetSiteId(host string) (siteId model.SiteID, err error) {
 	stmt := `SELECT id FROM site WHERE hostname = ?`
 	sdb.ForSingleRowOrPanic(
@@ -173,7 +293,7 @@ func (sdb *ScraperDB) GetThreadById(threadId model.ThreadID) (t model.Thread, er
 			var urlStr string
 			var startDate int64
 			var latest int64
-			err = rows.Scan(&t.SiteId, &urlStr, &t.Title, &t.Author, &startDate, &latest, t.Replies, &t.Views)
+			err = rows.Scan(&t.SiteId, &urlStr, &t.Title, &t.Author, &startDate, &latest, &t.Replies, &t.Views)
 			t.StartDate = time.Unix(startDate, 0)
 			t.Latest = time.Unix(latest, 0)
 			t.Id = threadId
@@ -211,7 +331,7 @@ func (sdb *ScraperDB) GetThreadByURL(url *url.URL) (thread model.Thread, err err
 }
 
 func (sdb *ScraperDB) getOrInsertSite(hostname string) (id model.SiteID, err error) {
-	sdb.ForSingleRowOrPanic(
+	sdb.WriteRowOrPanic(
 		func(rows *sql.Rows) {
 			err = rows.Scan(&id)
 		},
@@ -227,7 +347,7 @@ func (sdb *ScraperDB) getOrInsertSite(hostname string) (id model.SiteID, err err
 }
 
 func (sdb *ScraperDB) getOrInsertAuthor(username string, siteId model.SiteID) (id model.AuthorID, err error) {
-	sdb.ForSingleRowOrPanic(
+	sdb.WriteRowOrPanic(
 		func(rows *sql.Rows) {
 			err = rows.Scan(&id)
 		},
@@ -239,22 +359,83 @@ func (sdb *ScraperDB) getOrInsertAuthor(username string, siteId model.SiteID) (i
 			username = username
 		RETURNING id`,
 		siteId, username)
+	if err == nil {
+		sdb.addAuthorAlias(id, username)
+	}
 	return
 }
 
+// Stores comments, updating the content of any already stored. Changed
+// content is kept as a new revision of the comment. All the comments are
+// marked as seen now, and no longer removed if they had been. The quotes,
+// links and media stored for each comment are replaced with those in the
+// comment, as are its score and reactions when the comment has them. A
+// comment's parent is linked when it's stored before or with the comment.
 func (sdb *ScraperDB) AddComments(siteId model.SiteID, threadId model.ThreadID, comments []model.Comment) (err error) {
+	seen := time.Now().Unix()
 	for _, comment := range comments {
-		var authorId model.AuthorID
-		if authorId, err = sdb.getOrInsertAuthor(comment.Author, siteId); err != nil {
+		// Each comment is stored whole or not at all
+		if err = sdb.withTransaction(func(tx *ScraperDB) error {
+			return tx.addComment(siteId, threadId, comment, seen)
+		}); err != nil {
 			break
 		}
-		sdb.ExecOrPanic(
-			`INSERT INTO comment
-				(thread_id, url, author_id, published, content)
-			VALUES
-				(?, ?, ?, ?, ?)
-			ON CONFLICT DO NOTHING`,
-			threadId, comment.URL.String(), authorId, comment.Published.Unix(), comment.Content)
+	}
+	return
+}
+
+func (sdb *ScraperDB) addComment(siteId model.SiteID, threadId model.ThreadID, comment model.Comment, seen int64) (err error) {
+	var parentURL any
+	if comment.ParentURL != nil {
+		parentURL = comment.ParentURL.String()
+	}
+	var authorId model.AuthorID
+	if authorId, err = sdb.getOrInsertIdentifiedAuthor(comment.Author, comment.AuthorExternalId, siteId); err != nil {
+		return
+	}
+	sdb.ExecOrPanic(
+		`INSERT INTO comment
+			(thread_id, url, author_id, published, content)
+		VALUES
+			(?, ?, ?, ?, ?)
+		ON CONFLICT DO UPDATE SET
+			content = excluded.content
+		WHERE
+			content IS NOT excluded.content`,
+		threadId, comment.URL.String(), authorId, comment.Published.Unix(), comment.Content)
+	var commentId model.CommentID
+	sdb.WriteRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&commentId)
+		},
+		`UPDATE comment SET
+			last_seen = ?,
+			removed_at = NULL,
+			score = COALESCE(?, score),
+			reaction_count = COALESCE(?, reaction_count),
+			parent_id = COALESCE((SELECT id FROM comment WHERE url = ?), parent_id)
+		WHERE url = ?
+		RETURNING id`,
+		seen, comment.Score, comment.ReactionCount, parentURL, comment.URL.String())
+	if err != nil || commentId == 0 {
+		return
+	}
+
+	if err = sdb.setCommentQuotes(siteId, commentId, comment.Quotes); err != nil {
+		return
+	}
+	sdb.setCommentLinks(commentId, comment.Links)
+	sdb.setCommentMedia(commentId, comment.Media)
+	if comment.ReactionCount != nil {
+		sdb.setCommentReactions(commentId, comment.Reactions)
+	}
+	if comment.AuthorProfile != nil {
+		if err = sdb.setAuthorProfile(siteId, *comment.AuthorProfile); err != nil {
+			return
+		}
+	}
+	if sdb.KeepHTML && comment.HTML != "" {
+		err = sdb.setCommentHTML(commentId, comment.HTML)
 	}
 	return
 }
@@ -268,7 +449,7 @@ func (sdb *ScraperDB) GetForums() (forums []model.Forum, err error) {
 			var urlStr string
 			rows.Scan(&id, &urlStr)
 			if url, err := url.Parse(urlStr); err == nil {
-				forums = append(forums, model.Forum{model.ForumID(id), url})
+				forums = append(forums, model.Forum{Id: model.ForumID(id), URL: url})
 			} else {
 				panic(err)
 			}
@@ -277,6 +458,23 @@ func (sdb *ScraperDB) GetForums() (forums []model.Forum, err error) {
 	return
 }
 
+// Returns the URLs of the site's known forums.
+func (sdb *ScraperDB) GetSiteForumURLs(siteId model.SiteID) (urls []*url.URL, err error) {
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var urlStr string
+			var u *url.URL
+			if err == nil {
+				rows.Scan(&urlStr)
+				if u, err = url.Parse(urlStr); err == nil {
+					urls = append(urls, u)
+				}
+			}
+		},
+		"SELECT url FROM forum WHERE site_id = ? ORDER BY id", siteId)
+	return
+}
+
 func (sdb *ScraperDB) GetSites() (hostnamesById map[model.SiteID]string, err error) {
 	stmt := "SELECT id, hostname FROM site"
 	hostnamesById = make(map[model.SiteID]string)
@@ -322,6 +520,7 @@ func (sdb *ScraperDB) GetThreads(threadIds []model.ThreadID) (threadsById map[mo
 			if url, err := url.Parse(urlStr); err == nil {
 				threadsById[model.ThreadID(id)] =
 					model.Thread{
+						Id:        model.ThreadID(id),
 						URL:       url,
 						Title:     title,
 						Author:    username,
@@ -350,16 +549,19 @@ func (sdb *ScraperDB) FindThread(arg string) (thread model.Thread, err error) {
 	return
 }
 
+// Returns a thread's comments in the order they were published. Replies to
+// other comments have their ParentURL set.
 func (sdb *ScraperDB) ThreadComments(threadId model.ThreadID) (comments []model.Comment, err error) {
 	stmt := `
 		SELECT
-			c.url, a.username, c.published, c.content
+			c.url, a.username, c.published, c.content, p.url
 		FROM author a, comment c, thread t
+			LEFT JOIN comment p ON p.id = c.parent_id
 		WHERE
 				a.id = c.author_id
 			AND c.thread_id = t.id
 			AND t.id = ?
-		ORDER BY published`
+		ORDER BY c.published`
 
 	sdb.ForEachRowOrPanic(
 		func(rows *sql.Rows) {
@@ -367,25 +569,29 @@ func (sdb *ScraperDB) ThreadComments(threadId model.ThreadID) (comments []model.
 			var username string
 			var published uint
 			var content string
-			err = rows.Scan(&urlStr, &username, &published, &content)
+			var parentURL sql.NullString
+			err = rows.Scan(&urlStr, &username, &published, &content, &parentURL)
 			if err != nil {
 				panic(err)
 			}
-			url, _ := url.Parse(urlStr)
-			comments = append(comments,
-				model.Comment{
-					URL:       url,
-					Author:    username,
-					Published: time.Unix(int64(published), 0),
-					Content:   content,
-				})
+			u, _ := url.Parse(urlStr)
+			comment := model.Comment{
+				URL:       u,
+				Author:    username,
+				Published: time.Unix(int64(published), 0),
+				Content:   content,
+			}
+			if parentURL.Valid {
+				comment.ParentURL, _ = url.Parse(parentURL.String)
+			}
+			comments = append(comments, comment)
 		}, stmt, threadId)
 
 	return
 }
 
 func (sdb *ScraperDB) getOrInsertTagId(tag string) (id model.TagID, err error) {
-	sdb.ForSingleRowOrPanic(
+	sdb.WriteRowOrPanic(
 		func(rows *sql.Rows) {
 			err = rows.Scan(&id)
 		},
@@ -501,67 +707,3 @@ func (sdb *ScraperDB) GetForumLastScraped(forumId model.ForumID) (tm time.Time,
 		forumId)
 	return
 }
-
-func (sdb *ScraperDB) initTables() {
-	schema := `
-CREATE TABLE site (
-	id INTEGER NOT NULL PRIMARY KEY,
-	hostname STRING UNIQUE
-);
-
-CREATE TABLE forum (
-	id INTEGER NOT NULL PRIMARY KEY,
-	site_id INTEGER NOT NULL,
-	url TEXT UNIQUE,
-	last_scraped INTEGER
-);
-
-CREATE TABLE author (
-	id INTEGER NOT NULL PRIMARY KEY,
-	site_id INTEGER NOT NULL,
-	username TEXT,
-
-	UNIQUE(site_id, username)
-);
-
-CREATE TABLE thread (
-	id INTEGER NOT NULL PRIMARY KEY,
-	forum_id INTEGER NOT NULL,
-	author_id INTEGER NOT NULL,
-	title TEXT,
-	url TEXT UNIQUE,
-	replies INTEGER,
-	views INTEGER,
-	latest_activity INTEGER,
-	start_date INTEGER
-);
-
-CREATE TABLE comment (
-	id INTEGER NOT NULL PRIMARY KEY,
-	url TEXT UNIQUE,
-	thread_id INTEGER NOT NULL,
-	author_id INTEGER NOT NULL,
-	published INTEGER,
-	content TEXT,
-
-	UNIQUE(thread_id, author_id, published)
-);
-
-CREATE TABLE thread_tag (
-	thread_id INTEGER NOT NULL,
-	tag_id INTEGER NOT NULL,
-
-	UNIQUE(thread_id, tag_id)
-);
-
-CREATE TABLE tag (
-	id INTEGER NOT NULL PRIMARY KEY,
-	name TEXT UNIQUE
-);
-`
-	_, err := sdb.DB.Exec(schema)
-	if err != nil {
-		log.Printf("Error loading schema: %q\n", err)
-		return
-	}
-}
@@ -2,8 +2,10 @@ package database
 
 import (
 	"database/sql"
+	"fmt"
 	"net/url"
 	"os"
+	"sync"
 	"testing"
 	"time"
 
@@ -112,3 +114,121 @@ func TestBasicDatabase(t *testing.T) {
 	require.True(t, findAuthor("[[:alpha:]]{8}"))
 	require.False(t, findAuthor("[[:digit:]]"))
 }
+
+func TestConcurrentWrites(t *testing.T) {
+	db, err := OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	// Scrapes of different sites write from their own goroutines
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			forumURL, _ := url.Parse(fmt.Sprintf("https://site%d.com/forums/name.1", i))
+			siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+			require.Nil(t, err)
+			for j := 0; j < 10; j++ {
+				threadURL, _ := url.Parse(fmt.Sprintf("https://site%d.com/threads/name.%d", i, j))
+				thread := model.Thread{URL: threadURL, Author: "author", Latest: time.Now()}
+				threadId, err := db.InsertOrUpdateThread(siteId, forumId, thread)
+				require.Nil(t, err)
+				commentURL, _ := url.Parse(threadURL.String() + "/post-1")
+				require.Nil(t, db.AddComments(siteId, threadId, []model.Comment{
+					{URL: commentURL, Author: "author", Published: time.Unix(int64(j), 0), Content: "content"}}))
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	var count int
+	db.ForSingleRowOrPanic(func(rows *sql.Rows) { rows.Scan(&count) }, "SELECT COUNT(*) FROM comment")
+	require.Equal(t, 40, count)
+}
+
+func TestAddCommentsAtomically(t *testing.T) {
+	tt := newTestThread(t, "alice")
+
+	// A write that fails after the comment and its author are stored
+	_, err := tt.db.DB.Exec(`
+		CREATE TRIGGER fail_link BEFORE INSERT ON comment_link BEGIN
+			SELECT RAISE(ABORT, 'link rejected');
+		END`)
+	require.Nil(t, err)
+
+	linkURL, _ := url.Parse("https://example.com/")
+	comment := tt.post("1", "bob")
+	comment.Links = []model.Link{{URL: linkURL, Text: "example"}}
+	require.Panics(t, func() { tt.db.AddComments(tt.siteId, tt.threadId, []model.Comment{comment}) })
+
+	count := func(stmt string) (n int) {
+		tt.db.ForSingleRowOrPanic(func(rows *sql.Rows) { rows.Scan(&n) }, stmt)
+		return
+	}
+	require.Equal(t, 0, count("SELECT COUNT(*) FROM comment"))
+	require.Equal(t, 0, count("SELECT COUNT(*) FROM author WHERE username = 'bob'"))
+
+	// The write lock was released
+	_, err = tt.db.DB.Exec(`DROP TRIGGER fail_link`)
+	require.Nil(t, err)
+	tt.add(t, comment)
+	require.Equal(t, 1, count("SELECT COUNT(*) FROM comment_link"))
+}
+
+func TestCommentParents(t *testing.T) {
+	tt := newTestThread(t, "alice")
+	comment := func(id string, published int64, parent *url.URL) model.Comment {
+		c := tt.post(id, "bob")
+		c.Published, c.ParentURL = time.Unix(published, 0), parent
+		return c
+	}
+	top := comment("c1", 1, nil)
+	reply := comment("c2", 2, top.URL)
+	tt.add(t, top, reply, comment("c3", 3, reply.URL))
+
+	comments, err := tt.db.ThreadComments(tt.threadId)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(comments))
+	require.Nil(t, comments[0].ParentURL)
+	require.Equal(t, top.URL.String(), comments[1].ParentURL.String())
+	require.Equal(t, reply.URL.String(), comments[2].ParentURL.String())
+}
+
+// A thread in a fresh database that tests add comments to.
+type testThread struct {
+	db       *ScraperDB
+	siteId   model.SiteID
+	threadId model.ThreadID
+	url      *url.URL
+}
+
+// Opens a database in the test's temporary directory and stores a thread
+// started by author in it. The database is closed when the test ends.
+func newTestThread(t *testing.T, author string) (tt testThread) {
+	db, err := OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	t.Cleanup(db.Close)
+
+	forumURL, _ := url.Parse("https://forum.example.com/forums/vehicles.2")
+	tt.url, _ = url.Parse("https://forum.example.com/threads/battery-warranty.10/")
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+	threadId, err := db.InsertOrUpdateThread(siteId, forumId,
+		model.Thread{URL: tt.url, Title: "Battery warranty", Author: author})
+	require.Nil(t, err)
+	tt.db, tt.siteId, tt.threadId = db, siteId, threadId
+	return
+}
+
+// Returns a comment in the thread whose content is its id. Longer ids are
+// published later.
+func (tt testThread) post(id, author string) model.Comment {
+	return model.Comment{URL: tt.url.JoinPath("post-" + id), Author: author,
+		Published: time.Unix(1696161600, 0).Add(time.Duration(len(id)) * time.Hour), Content: id}
+}
+
+// Stores comments in the thread.
+func (tt testThread) add(t *testing.T, comments ...model.Comment) {
+	require.Nil(t, tt.db.AddComments(tt.siteId, tt.threadId, comments))
+}
@@ -0,0 +1,125 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"net/url"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+// The FTS5 module is only compiled into go-sqlite3 when espy is built with
+// -tags sqlite_fts5, as the Makefile does.
+var ErrFullTextUnavailable = errors.New("Full-text search is unavailable: espy was built without -tags sqlite_fts5")
+
+// The index uses comment as an external content table, so the triggers keep
+// it in sync and 'rebuild' repopulates it from comment.content.
+const commentIndexStmt = `
+CREATE VIRTUAL TABLE IF NOT EXISTS comment_fts USING fts5 (
+	content,
+	content = 'comment',
+	content_rowid = 'id'
+);
+
+CREATE TRIGGER IF NOT EXISTS comment_fts_insert AFTER INSERT ON comment BEGIN
+	INSERT INTO comment_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comment_fts_delete AFTER DELETE ON comment BEGIN
+	INSERT INTO comment_fts (comment_fts, rowid, content) VALUES ('delete', old.id, old.content);
+END;
+
+CREATE TRIGGER IF NOT EXISTS comment_fts_update AFTER UPDATE OF content ON comment BEGIN
+	INSERT INTO comment_fts (comment_fts, rowid, content) VALUES ('delete', old.id, old.content);
+	INSERT INTO comment_fts (rowid, content) VALUES (new.id, new.content);
+END;
+
+INSERT INTO comment_fts (comment_fts) VALUES ('rebuild');`
+
+type querier interface {
+	QueryRow(query string, args ...any) *sql.Row
+}
+
+func fullTextAvailable(q querier) (available bool) {
+	q.QueryRow(`SELECT sqlite_compileoption_used('ENABLE_FTS5')`).Scan(&available)
+	return
+}
+
+func createCommentIndex(tx *sql.Tx, m Migration) (err error) {
+	if fullTextAvailable(tx) {
+		_, err = tx.Exec(m.Stmt)
+	}
+	return
+}
+
+// Returns true if the SQLite library supports FTS5.
+func (sdb *ScraperDB) FullTextAvailable() bool {
+	return fullTextAvailable(sdb.DB)
+}
+
+// Returns true if the database contains the comment full-text index.
+func (sdb *ScraperDB) HasCommentIndex() (exists bool) {
+	sdb.DB.QueryRow(
+		`SELECT COUNT(*) > 0 FROM sqlite_master WHERE type = 'table' AND name = 'comment_fts'`).Scan(&exists)
+	return
+}
+
+// Creates the comment full-text index if it does not exist and repopulates it
+// from the comment table.
+func (sdb *ScraperDB) RebuildCommentIndex() (err error) {
+	if !sdb.FullTextAvailable() {
+		return ErrFullTextUnavailable
+	}
+	_, err = sdb.DB.Exec(commentIndexStmt)
+	return
+}
+
+type SearchHit struct {
+	model.Comment
+	ThreadId model.ThreadID
+	Rank     float64
+	Snippet  string
+}
+
+// Finds comments matching an FTS5 query, best matches first. Matched terms in
+// the snippet are wrapped in hlStart and hlEnd.
+func (sdb *ScraperDB) SearchComments(query string, limit int, hlStart, hlEnd string) (hits []SearchHit, err error) {
+	if !sdb.FullTextAvailable() {
+		return nil, ErrFullTextUnavailable
+	}
+
+	stmt := `
+		SELECT
+			c.url, a.username, c.published, c.content, c.thread_id,
+			bm25(comment_fts) rank,
+			snippet(comment_fts, 0, ?, ?, '...', 24)
+		FROM comment_fts
+			JOIN comment c ON c.id = comment_fts.rowid
+			JOIN author a ON a.id = c.author_id
+		WHERE comment_fts MATCH ?
+		ORDER BY rank
+		LIMIT ?`
+
+	var rows *sql.Rows
+	if rows, err = sdb.DB.Query(stmt, hlStart, hlEnd, query, limit); err != nil {
+		return
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var hit SearchHit
+		var urlStr string
+		var published int64
+		if err = rows.Scan(&urlStr, &hit.Author, &published, &hit.Content, &hit.ThreadId, &hit.Rank, &hit.Snippet); err != nil {
+			return
+		}
+		if hit.URL, err = url.Parse(urlStr); err != nil {
+			return
+		}
+		hit.Published = time.Unix(published, 0)
+		hits = append(hits, hit)
+	}
+	err = rows.Err()
+	return
+}
@@ -0,0 +1,85 @@
+//go:build sqlite_fts5
+
+package database
+
+import (
+	"fmt"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestSearchComments(t *testing.T) {
+	db, err := OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	require.True(t, db.FullTextAvailable())
+	require.True(t, db.HasCommentIndex())
+
+	forumUrl, _ := url.Parse("https://some-forum.com/forums/name.123")
+	siteId, forumId, err := db.InsertOrUpdateForum(forumUrl)
+	require.Nil(t, err)
+
+	threadUrl, _ := url.Parse("https://some-forum.com/threads/thread-xyz.1")
+	threadId, err := db.InsertOrUpdateThread(siteId, forumId, model.Thread{Title: "Some thread", URL: threadUrl})
+	require.Nil(t, err)
+
+	var comments []model.Comment
+	for i, content := range []string{
+		"The battery pack failed after the warranty expired",
+		"Battery life is fine but the pack rattles",
+		"Nothing relevant here",
+	} {
+		commentUrl, _ := url.Parse(fmt.Sprintf("%s/post-%d", threadUrl, i+1))
+		comments = append(comments, model.Comment{
+			URL:       commentUrl,
+			Author:    "somebody",
+			Published: time.Unix(int64(1000+i), 0),
+			Content:   content,
+		})
+	}
+	require.Nil(t, db.AddComments(siteId, threadId, comments))
+
+	hits, err := db.SearchComments(`"battery pack"`, 10, "[", "]")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(hits))
+	require.Equal(t, threadId, hits[0].ThreadId)
+	require.Contains(t, hits[0].Snippet, "The [battery pack] failed")
+
+	hits, err = db.SearchComments(`batt* NOT warranty`, 10, "[", "]")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(hits))
+	require.Equal(t, comments[1].Content, hits[0].Content)
+
+	// Rebuilding preserves the indexed content
+	require.Nil(t, db.RebuildCommentIndex())
+	hits, err = db.SearchComments(`NEAR(battery warranty, 5)`, 10, "[", "]")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(hits))
+}
+
+func TestOpenCreatesMissingCommentIndex(t *testing.T) {
+	path := t.TempDir() + "/test.db"
+	db, err := OpenScraperDBWithoutMigrating(path)
+	require.Nil(t, err)
+
+	// A build without FTS5 migrated the database without the index
+	require.Nil(t, db.initSchemaVersionTable())
+	for _, m := range migrations {
+		if m.Version == 3 {
+			m.Stmt = "SELECT 1"
+		}
+		require.Nil(t, db.applyMigration(m))
+	}
+	require.False(t, db.HasCommentIndex())
+	db.Close()
+
+	db, err = OpenScraperDB(path)
+	require.Nil(t, err)
+	defer db.Close()
+	require.True(t, db.HasCommentIndex())
+}
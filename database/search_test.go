@@ -0,0 +1,24 @@
+//go:build !sqlite_fts5
+
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSearchWithoutFullText(t *testing.T) {
+	db, err := OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	require.False(t, db.FullTextAvailable())
+	require.False(t, db.HasCommentIndex())
+	_, err = db.SearchComments("battery", 10, "[", "]")
+	require.Equal(t, ErrFullTextUnavailable, err)
+	require.Equal(t, ErrFullTextUnavailable, db.RebuildCommentIndex())
+
+	_, _, err = db.NewWatchMatches(Watch{Kind: SearchWatch, Query: "battery"})
+	require.Equal(t, ErrFullTextUnavailable, err)
+}
@@ -0,0 +1,81 @@
+package database
+
+import (
+	"database/sql"
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// The parts of a cookie kept between runs.
+type storedCookie struct {
+	Name     string    `json:"name"`
+	Value    string    `json:"value"`
+	Domain   string    `json:"domain,omitempty"`
+	Path     string    `json:"path,omitempty"`
+	Expires  time.Time `json:"expires"`
+	Secure   bool      `json:"secure,omitempty"`
+	HttpOnly bool      `json:"http_only,omitempty"`
+}
+
+// Returns the session cookies stored for the host and when they were stored,
+// or no cookies if there are none.
+func (sdb *ScraperDB) GetSessionCookies(hostname string) (cookies []*http.Cookie, updated time.Time, err error) {
+	var encoded string
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			var epochSecs int64
+			err = rows.Scan(&encoded, &epochSecs)
+			updated = time.Unix(epochSecs, 0)
+		},
+		"SELECT cookies, updated FROM site_session WHERE hostname = ?",
+		hostname)
+
+	var stored []storedCookie
+	if err == nil && encoded != "" {
+		if err = json.Unmarshal([]byte(encoded), &stored); err == nil {
+			for _, c := range stored {
+				cookies = append(cookies, &http.Cookie{
+					Name:     c.Name,
+					Value:    c.Value,
+					Domain:   c.Domain,
+					Path:     c.Path,
+					Expires:  c.Expires,
+					Secure:   c.Secure,
+					HttpOnly: c.HttpOnly,
+				})
+			}
+		}
+	}
+	return
+}
+
+// Replaces the session cookies stored for the host.
+func (sdb *ScraperDB) SetSessionCookies(hostname string, cookies []*http.Cookie) (err error) {
+	stored := make([]storedCookie, len(cookies))
+	for i, c := range cookies {
+		stored[i] = storedCookie{
+			Name:     c.Name,
+			Value:    c.Value,
+			Domain:   c.Domain,
+			Path:     c.Path,
+			Expires:  c.Expires,
+			Secure:   c.Secure,
+			HttpOnly: c.HttpOnly,
+		}
+	}
+
+	var encoded []byte
+	if encoded, err = json.Marshal(stored); err == nil {
+		sdb.ExecOrPanic(
+			`INSERT INTO site_session
+				(hostname, cookies, updated)
+			VALUES
+				(?, ?, ?)
+			ON CONFLICT DO UPDATE SET
+				cookies = excluded.cookies,
+				updated = excluded.updated`,
+			hostname, string(encoded), time.Now().Unix())
+	}
+	return
+}
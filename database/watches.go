@@ -0,0 +1,228 @@
+package database
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+var ErrWatchNotFound = errors.New("Watch not found")
+
+type WatchKind string
+
+const (
+	// Matches comments whose content matches a regular expression.
+	RegexWatch WatchKind = "regex"
+
+	// Matches comments found by a full-text query.
+	SearchWatch WatchKind = "search"
+)
+
+// A saved query whose matches among newly scraped comments are sent to a sink.
+type Watch struct {
+	Id    uint
+	Name  string
+	Kind  WatchKind
+	Query string
+
+	// Limits on which comments can match; empty or zero ones don't limit.
+	Site     string
+	ForumId  model.ForumID
+	ThreadId model.ThreadID
+	Author   string
+	Tag      string
+
+	// Where matches are sent, e.g. "stdout" or "webhook:<URL>".
+	Sink string
+
+	// Comments with ids up to this one have already been checked.
+	HighWater model.CommentID
+	Created   time.Time
+}
+
+// A comment that matched a watch.
+type WatchMatch struct {
+	model.Comment
+	Id          model.CommentID
+	ThreadTitle string
+}
+
+// Stores a new watch, which matches only comments inserted from now on.
+func (sdb *ScraperDB) AddWatch(w Watch) (id uint, err error) {
+	if w.Kind != RegexWatch && w.Kind != SearchWatch {
+		return 0, fmt.Errorf("Unknown watch kind %q", w.Kind)
+	}
+	sdb.WriteRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&id)
+		},
+		`INSERT INTO watch
+			(name, kind, query, site, forum_id, thread_id, author, tag, sink, high_water, created)
+		VALUES
+			(?, ?, ?, NULLIF(?, ''), NULLIF(?, 0), NULLIF(?, 0), NULLIF(?, ''), NULLIF(?, ''), ?,
+				(SELECT COALESCE(MAX(id), 0) FROM comment), ?)
+		ON CONFLICT DO NOTHING
+		RETURNING id`,
+		w.Name, w.Kind, w.Query, w.Site, w.ForumId, w.ThreadId, w.Author, w.Tag, w.Sink, time.Now().Unix())
+	if err == nil && id == 0 {
+		err = fmt.Errorf("A watch named %q already exists", w.Name)
+	}
+	return
+}
+
+const watchColumns = `
+	id, name, kind, query, COALESCE(site, ''), COALESCE(forum_id, 0), COALESCE(thread_id, 0),
+	COALESCE(author, ''), COALESCE(tag, ''), sink, high_water, created`
+
+func scanWatch(rows *sql.Rows) (w Watch, err error) {
+	var created int64
+	err = rows.Scan(&w.Id, &w.Name, &w.Kind, &w.Query, &w.Site, &w.ForumId, &w.ThreadId,
+		&w.Author, &w.Tag, &w.Sink, &w.HighWater, &created)
+	w.Created = time.Unix(created, 0)
+	return
+}
+
+// Returns the watches with the given names, or every watch if none are given,
+// in the order they were added.
+func (sdb *ScraperDB) Watches(names ...string) (watches []Watch, err error) {
+	found := make(map[string]bool)
+	sdb.ForEachRowOrPanic(
+		func(rows *sql.Rows) {
+			var w Watch
+			if w, err = scanWatch(rows); err == nil {
+				watches = append(watches, w)
+				found[w.Name] = true
+			}
+		},
+		"SELECT "+watchColumns+" FROM watch ORDER BY id")
+
+	if len(names) > 0 && err == nil {
+		var named []Watch
+		for _, name := range names {
+			if !found[name] {
+				return nil, fmt.Errorf("%w: %s", ErrWatchNotFound, name)
+			}
+			for _, w := range watches {
+				if w.Name == name {
+					named = append(named, w)
+				}
+			}
+		}
+		watches = named
+	}
+	return
+}
+
+func (sdb *ScraperDB) RemoveWatch(name string) error {
+	return sdb.withTransaction(func(tx *ScraperDB) (err error) {
+		var result sql.Result
+		var removed int64
+		if result, err = tx.conn().Exec("DELETE FROM watch WHERE name = ?", name); err == nil {
+			if removed, err = result.RowsAffected(); err == nil && removed == 0 {
+				err = fmt.Errorf("%w: %s", ErrWatchNotFound, name)
+			}
+		}
+		return
+	})
+}
+
+// Records that the watch has checked the comments with ids up to highWater.
+func (sdb *ScraperDB) SetWatchHighWater(watchId uint, highWater model.CommentID) {
+	sdb.ExecOrPanic("UPDATE watch SET high_water = ? WHERE id = ?", highWater, watchId)
+}
+
+// Returns the comments inserted since the watch's high-water mark that match
+// it, in the order they were inserted, and the mark to record once they have
+// been sent.
+func (sdb *ScraperDB) NewWatchMatches(w Watch) (matches []WatchMatch, highWater model.CommentID, err error) {
+	if w.Kind == SearchWatch && !sdb.FullTextAvailable() {
+		return nil, w.HighWater, ErrFullTextUnavailable
+	}
+
+	sdb.ForSingleRowOrPanic(
+		func(rows *sql.Rows) {
+			err = rows.Scan(&highWater)
+		},
+		"SELECT COALESCE(MAX(id), 0) FROM comment")
+	if err != nil || highWater <= w.HighWater {
+		return nil, w.HighWater, err
+	}
+
+	conditions := []string{"c.id > ?", "c.id <= ?"}
+	params := []any{w.HighWater, highWater}
+	switch w.Kind {
+	case RegexWatch:
+		conditions = append(conditions, "c.content REGEXP ?")
+	case SearchWatch:
+		conditions = append(conditions, "c.id IN (SELECT rowid FROM comment_fts WHERE comment_fts MATCH ?)")
+	default:
+		return nil, w.HighWater, fmt.Errorf("Unknown watch kind %q", w.Kind)
+	}
+	params = append(params, w.Query)
+
+	if w.Site != "" {
+		conditions = append(conditions, "s.hostname = ?")
+		params = append(params, w.Site)
+	}
+	if w.ForumId != 0 {
+		conditions = append(conditions, "t.forum_id = ?")
+		params = append(params, w.ForumId)
+	}
+	if w.ThreadId != 0 {
+		conditions = append(conditions, "t.id = ?")
+		params = append(params, w.ThreadId)
+	}
+	if w.Author != "" {
+		conditions = append(conditions, `c.author_id IN (
+			SELECT id FROM author WHERE username = ?
+			UNION
+			SELECT author_id FROM author_alias WHERE username = ?)`)
+		params = append(params, w.Author, w.Author)
+	}
+	if w.Tag != "" {
+		conditions = append(conditions, `t.id IN (
+			SELECT tt.thread_id FROM thread_tag tt JOIN tag g ON g.id = tt.tag_id WHERE g.name = ?)`)
+		params = append(params, w.Tag)
+	}
+
+	stmt := `
+		SELECT
+			c.id, c.url, a.username, c.published, c.content, t.title
+		FROM comment c
+			JOIN author a ON a.id = c.author_id
+			JOIN thread t ON t.id = c.thread_id
+			JOIN forum f ON f.id = t.forum_id
+			JOIN site s ON s.id = f.site_id
+		WHERE
+			` + strings.Join(conditions, "\n\t\t\tAND ") + `
+		ORDER BY c.id`
+
+	var rows *sql.Rows
+	if rows, err = sdb.DB.Query(stmt, params...); err != nil {
+		return nil, w.HighWater, err
+	}
+	defer rows.Close()
+
+	for rows.Next() {
+		var m WatchMatch
+		var urlStr string
+		var published int64
+		if err = rows.Scan(&m.Id, &urlStr, &m.Author, &published, &m.Content, &m.ThreadTitle); err != nil {
+			return nil, w.HighWater, err
+		}
+		if m.URL, err = url.Parse(urlStr); err != nil {
+			return nil, w.HighWater, err
+		}
+		m.Published = time.Unix(published, 0)
+		matches = append(matches, m)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, w.HighWater, err
+	}
+	return
+}
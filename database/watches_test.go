@@ -0,0 +1,70 @@
+package database
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestWatches(t *testing.T) {
+	tt := newTestThread(t, "alice")
+	comment := func(id, author, content string) model.Comment {
+		c := tt.post(id, author)
+		c.Content = content
+		return c
+	}
+
+	// Comments from before a watch was added don't match it
+	tt.add(t, comment("1", "alice", "My battery died"))
+	id, err := tt.db.AddWatch(Watch{Name: "battery", Kind: RegexWatch, Query: "(?i)battery", Sink: "stdout"})
+	require.Nil(t, err)
+	_, err = tt.db.AddWatch(Watch{Name: "battery", Kind: RegexWatch, Query: "x", Sink: "stdout"})
+	require.NotNil(t, err)
+	_, err = tt.db.AddWatch(Watch{Name: "bob", Kind: RegexWatch, Query: ".", Author: "bob", Tag: "ev", Sink: "stdout"})
+	require.Nil(t, err)
+
+	watches, err := tt.db.Watches("battery")
+	require.Nil(t, err)
+	require.Equal(t, 1, len(watches))
+	w := watches[0]
+	require.Equal(t, id, w.Id)
+	matches, highWater, err := tt.db.NewWatchMatches(w)
+	require.Nil(t, err)
+	require.Empty(t, matches)
+	require.Equal(t, w.HighWater, highWater)
+
+	tt.add(t,
+		comment("2", "bob", "Battery replaced"), comment("post-3", "carol", "Tires"),
+	)
+	matches, highWater, err = tt.db.NewWatchMatches(w)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(matches))
+	require.Equal(t, "bob", matches[0].Author)
+	require.Equal(t, "Battery warranty", matches[0].ThreadTitle)
+
+	// Once the mark is recorded the same comments don't match again
+	tt.db.SetWatchHighWater(w.Id, highWater)
+	watches, err = tt.db.Watches()
+	require.Nil(t, err)
+	require.Equal(t, 2, len(watches))
+	matches, _, err = tt.db.NewWatchMatches(watches[0])
+	require.Nil(t, err)
+	require.Empty(t, matches)
+
+	// Scopes limit the comments that can match
+	matches, _, err = tt.db.NewWatchMatches(watches[1])
+	require.Nil(t, err)
+	require.Empty(t, matches)
+	require.Nil(t, tt.db.AddThreadTags(tt.threadId, []string{"ev"}))
+	matches, _, err = tt.db.NewWatchMatches(watches[1])
+	require.Nil(t, err)
+	require.Equal(t, 1, len(matches))
+	require.Equal(t, "Battery replaced", matches[0].Content)
+
+	require.Nil(t, tt.db.RemoveWatch("bob"))
+	require.True(t, errors.Is(tt.db.RemoveWatch("bob"), ErrWatchNotFound))
+	_, err = tt.db.Watches("bob")
+	require.True(t, errors.Is(err, ErrWatchNotFound))
+}
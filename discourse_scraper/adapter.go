@@ -0,0 +1,74 @@
+package discourse_scraper
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+func init() {
+	adapter.Register(discourseAdapter{})
+}
+
+type discourseAdapter struct{}
+
+func (discourseAdapter) Name() string {
+	return "discourse"
+}
+
+func (discourseAdapter) URLShapes() []string {
+	return []string{
+		"https://<host>/latest",
+		"https://<host>/c/<slug>/<id>",
+		"https://<host>/t/<slug>/<id>",
+	}
+}
+
+func (discourseAdapter) Matches(u *url.URL) adapter.URLKind {
+	path := strings.TrimSuffix(u.Path, "/")
+	if path == "/latest" || path == "/latest.json" || strings.HasPrefix(path, "/c/") {
+		return adapter.ForumURL
+	} else if _, err := parseTopicId(u); err == nil {
+		return adapter.ThreadURL
+	}
+	return adapter.NoMatch
+}
+
+func (discourseAdapter) ScrapeForum(db *database.ScraperDB, u *url.URL, cutoff time.Time, subforums bool) error {
+	if subforums {
+		return fmt.Errorf("The discourse adapter does not scrape subforums of %s", u)
+	}
+	return NewForumScraper(u).LoadThreadsWithActivitySince(db, cutoff)
+}
+
+// The stored highest post number is only advanced by forum scrapes, since the
+// caller may choose not to store the returned comments.
+func (discourseAdapter) ScrapeThread(db *database.ScraperDB, thread model.Thread, cutoff time.Time) (comments []model.Comment, err error) {
+	ts := NewThreadScraper(thread.Id, DiscourseThread{Thread: thread})
+	if err = ts.LoadCommentsSince(db, cutoff); err == nil {
+		comments = ts.comments()
+	}
+	return
+}
+
+func (discourseAdapter) ParsePage(u *url.URL) (page adapter.Page, err error) {
+	if _, parseErr := parseTopicId(u); parseErr == nil {
+		ts := NewThreadScraper(0, DiscourseThread{Thread: model.Thread{URL: u}})
+		if err = ts.LoadFirstPage(); err == nil {
+			page.Comments = ts.comments()
+		}
+	} else {
+		fs := NewForumScraper(u)
+		if err = fs.LoadTopicsSince(time.Now()); err == nil {
+			for _, t := range fs.Threads {
+				page.Threads = append(page.Threads, t.Thread)
+			}
+		}
+	}
+	return
+}
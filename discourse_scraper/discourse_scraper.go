@@ -0,0 +1,228 @@
+package discourse_scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/replay"
+	"github.com/zvonler/espy/scheduler"
+	"github.com/zvonler/espy/session"
+	"golang.org/x/net/html"
+)
+
+/*---------------------------------------------------------------------------*/
+
+// The subset of Discourse's JSON API used by the scrapers.
+
+type user struct {
+	Id       int    `json:"id"`
+	Username string `json:"username"`
+}
+
+type poster struct {
+	UserId      int    `json:"user_id"`
+	Description string `json:"description"`
+}
+
+type topic struct {
+	Id                int       `json:"id"`
+	Slug              string    `json:"slug"`
+	Title             string    `json:"title"`
+	PostsCount        uint      `json:"posts_count"`
+	Views             uint      `json:"views"`
+	HighestPostNumber uint      `json:"highest_post_number"`
+	CreatedAt         time.Time `json:"created_at"`
+	LastPostedAt      time.Time `json:"last_posted_at"`
+	BumpedAt          time.Time `json:"bumped_at"`
+	Pinned            bool      `json:"pinned"`
+	Posters           []poster  `json:"posters"`
+}
+
+type topicList struct {
+	Users     []user `json:"users"`
+	TopicList struct {
+		Topics        []topic `json:"topics"`
+		MoreTopicsURL string  `json:"more_topics_url"`
+	} `json:"topic_list"`
+}
+
+type post struct {
+	Id         int       `json:"id"`
+	Username   string    `json:"username"`
+	CreatedAt  time.Time `json:"created_at"`
+	Cooked     string    `json:"cooked"`
+	PostNumber uint      `json:"post_number"`
+	TopicId    int       `json:"topic_id"`
+	TopicSlug  string    `json:"topic_slug"`
+}
+
+type postStream struct {
+	Posts  []post `json:"posts"`
+	Stream []int  `json:"stream"`
+}
+
+type topicPosts struct {
+	topic
+	Details struct {
+		CreatedBy user `json:"created_by"`
+	} `json:"details"`
+	PostStream postStream `json:"post_stream"`
+}
+
+/*---------------------------------------------------------------------------*/
+
+type DiscourseThread struct {
+	model.Thread
+	topicId           int
+	highestPostNumber uint
+}
+
+type DiscourseComment struct {
+	model.Comment
+	postNumber uint
+}
+
+/*---------------------------------------------------------------------------*/
+
+// Posts are fetched in chunks of this size, matching Discourse's page size.
+const chunkSize = 20
+
+type client struct {
+	base       *url.URL
+	httpClient *http.Client
+}
+
+func newClient(u *url.URL) *client {
+	return &client{
+		base: &url.URL{Scheme: u.Scheme, Host: u.Host},
+		httpClient: &http.Client{
+			Transport: session.Transport(replay.Wrap(scheduler.Transport(http.DefaultTransport))),
+			Jar:       session.Jar(),
+			Timeout:   30 * time.Second,
+		},
+	}
+}
+
+func (c *client) getJSON(path string, query url.Values, v any) (err error) {
+	u := c.base.JoinPath(path)
+	u.RawQuery = query.Encode()
+	fmt.Println("DiscourseScraper visiting", u)
+
+	var req *http.Request
+	if req, err = http.NewRequest(http.MethodGet, u.String(), nil); err != nil {
+		return
+	}
+	req.Header.Set("Accept", "application/json")
+	req.Header.Set("User-Agent", "Mozilla")
+
+	var resp *http.Response
+	if resp, err = c.httpClient.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned %s", u, resp.Status)
+	}
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+func (c *client) topicURL(slug string, id int) *url.URL {
+	return c.base.JoinPath("t", slug, fmt.Sprint(id))
+}
+
+func (c *client) postURL(slug string, topicId int, postNumber uint) *url.URL {
+	return c.base.JoinPath("t", slug, fmt.Sprint(topicId), fmt.Sprint(postNumber))
+}
+
+func (c *client) toThread(t topic, usernamesById map[int]string) DiscourseThread {
+	thread := DiscourseThread{
+		Thread: model.Thread{
+			URL:       c.topicURL(t.Slug, t.Id),
+			Title:     t.Title,
+			StartDate: t.CreatedAt,
+			Latest:    t.LastPostedAt,
+			Views:     t.Views,
+		},
+		topicId:           t.Id,
+		highestPostNumber: t.HighestPostNumber,
+	}
+	if t.PostsCount > 0 {
+		thread.Replies = t.PostsCount - 1
+	}
+	if thread.Latest.IsZero() {
+		thread.Latest = t.CreatedAt
+	}
+	for _, p := range t.Posters {
+		if strings.Contains(p.Description, "Original Poster") {
+			thread.Author = usernamesById[p.UserId]
+		}
+	}
+	return thread
+}
+
+// Returns the username of a topic's first post.
+func (c *client) firstPoster(topicId int) (username string, err error) {
+	var tp topicPosts
+	if err = c.getJSON(fmt.Sprintf("/t/%d.json", topicId), nil, &tp); err == nil {
+		for _, p := range tp.PostStream.Posts {
+			if p.PostNumber == 1 {
+				username = p.Username
+			}
+		}
+	}
+	return
+}
+
+func (c *client) toComment(p post, slug string) DiscourseComment {
+	return DiscourseComment{
+		Comment: model.Comment{
+			URL:       c.postURL(slug, p.TopicId, p.PostNumber),
+			Author:    p.Username,
+			Published: p.CreatedAt,
+			Content:   extractContent(p.Cooked),
+		},
+		postNumber: p.PostNumber,
+	}
+}
+
+var (
+	wsLinePat = regexp.MustCompile("\n[ \t]+\n")
+	nlPat     = regexp.MustCompile("\n\n+")
+)
+
+// Returns the text of a post's cooked HTML without quoted replies.
+func extractContent(cooked string) (content string) {
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(cooked))
+	if err != nil {
+		return cooked
+	}
+
+	var collectText func(*html.Node)
+	collectText = func(n *html.Node) {
+		if n.Type == html.ElementNode && (n.Data == "aside" || n.Data == "blockquote") {
+			return
+		}
+		if n.Type == html.TextNode {
+			content += n.Data
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collectText(c)
+		}
+	}
+	for _, n := range doc.Nodes {
+		collectText(n)
+	}
+
+	content = strings.ReplaceAll(content, "\u00a0", " ")
+	content = wsLinePat.ReplaceAllString(content, "\n")
+	content = nlPat.ReplaceAllString(content, "\n")
+	return strings.TrimSpace(content)
+}
@@ -0,0 +1,198 @@
+package discourse_scraper
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/scheduler"
+)
+
+// A minimal in-memory Discourse serving one active topic.
+type fakeDiscourse struct {
+	start    time.Time
+	posts    []post
+	requests []string
+	// Lists the topic without its original poster
+	noPosters bool
+}
+
+func (fd *fakeDiscourse) addPost(username string) {
+	n := len(fd.posts) + 1
+	fd.posts = append(fd.posts, post{
+		Id:         1000 + n,
+		Username:   username,
+		CreatedAt:  fd.start.Add(time.Duration(n) * time.Hour),
+		Cooked:     fmt.Sprintf("<p>Post number %d</p>", n),
+		PostNumber: uint(n),
+		TopicId:    100,
+	})
+}
+
+func (fd *fakeDiscourse) activeTopic() topic {
+	last := fd.posts[len(fd.posts)-1]
+	t := topic{
+		Id: 100, Slug: "battery-warranty", Title: "Battery warranty",
+		PostsCount: uint(len(fd.posts)), HighestPostNumber: last.PostNumber,
+		CreatedAt: fd.posts[0].CreatedAt, LastPostedAt: last.CreatedAt,
+		Posters: []poster{{UserId: 1, Description: "Original Poster, Most Recent Poster"}},
+	}
+	if fd.noPosters {
+		t.Posters = nil
+	}
+	return t
+}
+
+func (fd *fakeDiscourse) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	fd.requests = append(fd.requests, r.URL.RequestURI())
+
+	var body any
+	switch r.URL.Path {
+	case "/latest.json":
+		var list topicList
+		list.Users = []user{{Id: 1, Username: "alice"}}
+		if r.URL.Query().Get("page") == "" {
+			pinned := topic{Id: 1, Slug: "welcome", Title: "Welcome", Pinned: true,
+				CreatedAt: fd.start.AddDate(-1, 0, 0), LastPostedAt: fd.start.AddDate(-1, 0, 0)}
+			list.TopicList.Topics = []topic{pinned, fd.activeTopic()}
+			list.TopicList.MoreTopicsURL = "/latest?page=1"
+		} else {
+			old := topic{Id: 200, Slug: "old-news", Title: "Old news",
+				CreatedAt: fd.start.AddDate(0, -1, 0), LastPostedAt: fd.start.AddDate(0, -1, 0)}
+			list.TopicList.Topics = []topic{old}
+		}
+		body = list
+	case "/t/100.json":
+		tp := topicPosts{topic: fd.activeTopic()}
+		for i, p := range fd.posts {
+			if i < chunkSize {
+				tp.PostStream.Posts = append(tp.PostStream.Posts, p)
+			}
+			tp.PostStream.Stream = append(tp.PostStream.Stream, p.Id)
+		}
+		body = tp
+	case "/t/100/posts.json":
+		var tp topicPosts
+		for _, idStr := range r.URL.Query()["post_ids[]"] {
+			id, _ := strconv.Atoi(idStr)
+			tp.PostStream.Posts = append(tp.PostStream.Posts, fd.posts[id-1001])
+		}
+		body = tp
+	default:
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(body)
+}
+
+func TestLoadThreadsWithActivitySince(t *testing.T) {
+	scheduler.SetDefaultLimits(scheduler.Limits{})
+	defer scheduler.SetDefaultLimits(scheduler.DefaultLimits)
+
+	fd := &fakeDiscourse{start: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC)}
+	for i := 0; i < 25; i++ {
+		fd.addPost("alice")
+	}
+	server := httptest.NewServer(fd)
+	defer server.Close()
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL, _ := url.Parse(server.URL + "/latest")
+	cutoff := fd.start.Add(5*time.Hour + time.Minute)
+
+	require.Nil(t, NewForumScraper(forumURL).LoadThreadsWithActivitySince(db, cutoff))
+
+	// Paging stops at the first page whose last unpinned topic predates the cutoff
+	require.Contains(t, fd.requests, "/latest.json?page=1")
+	require.NotContains(t, fd.requests, "/t/200.json")
+	require.NotContains(t, fd.requests, "/t/1.json")
+
+	topicURL, _ := url.Parse(server.URL + "/t/battery-warranty/100")
+	thread, err := db.GetThreadByURL(topicURL)
+	require.Nil(t, err)
+	require.Equal(t, "alice", thread.Author)
+	require.Equal(t, uint(24), thread.Replies)
+
+	comments, err := db.ThreadComments(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, 20, len(comments)) // posts 6 through 25
+	require.Equal(t, "Post number 6", comments[0].Content)
+	require.Equal(t, server.URL+"/t/battery-warranty/100/25", comments[19].URL.String())
+
+	highest, err := db.GetThreadHighestPostNumber(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, uint(25), highest)
+
+	// A re-scrape only fetches posts after the stored highest post number
+	fd.addPost("bob")
+	fd.addPost("alice")
+	fd.requests = nil
+	require.Nil(t, NewForumScraper(forumURL).LoadThreadsWithActivitySince(db, cutoff))
+	require.Contains(t, fd.requests, "/t/100/posts.json?post_ids%5B%5D=1021&post_ids%5B%5D=1022&post_ids%5B%5D=1023&post_ids%5B%5D=1024&post_ids%5B%5D=1025&post_ids%5B%5D=1026&post_ids%5B%5D=1027")
+	require.Equal(t, 4, len(fd.requests)) // two listing pages, the topic and one chunk
+
+	comments, err = db.ThreadComments(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, 22, len(comments))
+	require.Equal(t, "bob", comments[20].Author)
+
+	highest, err = db.GetThreadHighestPostNumber(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, uint(27), highest)
+}
+
+func TestAuthorFromFirstPost(t *testing.T) {
+	scheduler.SetDefaultLimits(scheduler.Limits{})
+	defer scheduler.SetDefaultLimits(scheduler.DefaultLimits)
+
+	fd := &fakeDiscourse{start: time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC), noPosters: true}
+	fd.addPost("carol")
+	fd.addPost("alice")
+	server := httptest.NewServer(fd)
+	defer server.Close()
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL, _ := url.Parse(server.URL + "/latest")
+	require.Nil(t, NewForumScraper(forumURL).LoadThreadsWithActivitySince(db, fd.start))
+
+	topicURL, _ := url.Parse(server.URL + "/t/battery-warranty/100")
+	thread, err := db.GetThreadByURL(topicURL)
+	require.Nil(t, err)
+	require.Equal(t, "carol", thread.Author)
+}
+
+func TestScrapeForumRejectsSubforums(t *testing.T) {
+	u, _ := url.Parse("https://discourse.example.com/c/vehicles/5")
+	require.NotNil(t, discourseAdapter{}.ScrapeForum(nil, u, time.Now(), true))
+}
+
+func TestParseTopicId(t *testing.T) {
+	for path, expected := range map[string]int{
+		"/t/100":                    100,
+		"/t/battery-warranty/100":   100,
+		"/t/battery-warranty/100/7": 100,
+	} {
+		u, _ := url.Parse("https://discourse.example.com" + path)
+		id, err := parseTopicId(u)
+		require.Nil(t, err)
+		require.Equal(t, expected, id)
+	}
+
+	u, _ := url.Parse("https://discourse.example.com/c/vehicles/5")
+	_, err := parseTopicId(u)
+	require.NotNil(t, err)
+}
@@ -0,0 +1,106 @@
+package discourse_scraper
+
+import (
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/database"
+)
+
+type ForumScraper struct {
+	forumURL *url.URL
+	client   *client
+	Threads  []DiscourseThread
+}
+
+func NewForumScraper(forumURL *url.URL) *ForumScraper {
+	fs := new(ForumScraper)
+	fs.forumURL = forumURL
+	fs.client = newClient(forumURL)
+	return fs
+}
+
+// Returns the JSON path for a /latest or /c/<slug>/<id> listing.
+func listingPath(u *url.URL) string {
+	path := strings.TrimSuffix(strings.TrimSuffix(u.Path, "/"), ".json")
+	if path == "" {
+		path = "/latest"
+	}
+	return path + ".json"
+}
+
+// Loads pages of the topic listing until a page ends with a topic whose last
+// activity is before cutoff.
+func (fs *ForumScraper) LoadTopicsSince(cutoff time.Time) (err error) {
+	for page := 0; ; page++ {
+		query := url.Values{}
+		if page > 0 {
+			query.Set("page", strconv.Itoa(page))
+		}
+
+		var list topicList
+		if err = fs.client.getJSON(listingPath(fs.forumURL), query, &list); err != nil {
+			return
+		}
+
+		usernamesById := make(map[int]string)
+		for _, u := range list.Users {
+			usernamesById[u.Id] = u.Username
+		}
+
+		var oldest time.Time
+		for _, t := range list.TopicList.Topics {
+			thread := fs.client.toThread(t, usernamesById)
+			fs.Threads = append(fs.Threads, thread)
+			// Pinned topics are listed first regardless of activity
+			if !t.Pinned {
+				oldest = thread.Latest
+			}
+		}
+
+		if list.TopicList.MoreTopicsURL == "" || len(list.TopicList.Topics) == 0 || oldest.Before(cutoff) {
+			break
+		}
+	}
+	return
+}
+
+func (fs *ForumScraper) LoadThreadsWithActivitySince(db *database.ScraperDB, cutoff time.Time) (err error) {
+	siteId, forumId, err := db.InsertOrUpdateForum(fs.forumURL)
+	if err != nil {
+		return
+	}
+
+	if err = fs.LoadTopicsSince(cutoff); err != nil {
+		return
+	}
+
+	for _, thread := range fs.Threads {
+		if thread.Latest.Before(cutoff) {
+			continue
+		}
+		// The listing doesn't always say who started a topic
+		if thread.Author == "" {
+			if thread.Author, err = fs.client.firstPoster(thread.topicId); err != nil {
+				return
+			}
+		}
+		threadId, err := db.InsertOrUpdateThread(siteId, forumId, thread.Thread)
+		if err != nil {
+			return err
+		}
+		ts := NewThreadScraper(threadId, thread)
+		if err = ts.LoadCommentsSince(db, cutoff); err != nil {
+			return err
+		}
+		if err = db.AddComments(siteId, threadId, ts.comments()); err != nil {
+			return err
+		}
+		db.SetThreadHighestPostNumber(threadId, ts.highestPostNumber)
+	}
+
+	db.SetForumLastScraped(forumId, time.Now())
+	return
+}
@@ -0,0 +1,148 @@
+package discourse_scraper
+
+import (
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+type ThreadScraper struct {
+	threadId          model.ThreadID
+	thread            DiscourseThread
+	client            *client
+	Comments          []DiscourseComment
+	highestPostNumber uint
+}
+
+func NewThreadScraper(threadId model.ThreadID, thread DiscourseThread) *ThreadScraper {
+	ts := new(ThreadScraper)
+	ts.threadId = threadId
+	ts.thread = thread
+	ts.client = newClient(thread.URL)
+	ts.Comments = make([]DiscourseComment, 0)
+	return ts
+}
+
+// Returns the topic id from a /t/<id> or /t/<slug>/<id>[/<post_number>] URL.
+func parseTopicId(u *url.URL) (id int, err error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) == 2 && parts[0] == "t" {
+		id, err = strconv.Atoi(parts[1])
+	} else if len(parts) >= 3 && parts[0] == "t" {
+		id, err = strconv.Atoi(parts[2])
+	} else {
+		err = fmt.Errorf("Not a Discourse topic URL: %s", u)
+	}
+	return
+}
+
+func (ts *ThreadScraper) comments() []model.Comment {
+	comments := make([]model.Comment, len(ts.Comments), len(ts.Comments))
+	for i := range ts.Comments {
+		comments[i] = ts.Comments[i].Comment
+	}
+	return comments
+}
+
+// Loads posts newer than those already stored, working back from the end of
+// the topic until reaching the cutoff. If the user has asked for a longer
+// lookback than previously stored, older posts are backfilled to the cutoff.
+func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.Time) (err error) {
+	topicId := ts.thread.topicId
+	if topicId == 0 {
+		if topicId, err = parseTopicId(ts.thread.URL); err != nil {
+			return
+		}
+	}
+
+	var storedHighest uint
+	if ts.threadId != 0 {
+		if storedHighest, err = db.GetThreadHighestPostNumber(ts.threadId); err != nil {
+			return
+		}
+		if timeRange := db.CommentTimeRange(ts.threadId); timeRange != nil &&
+			cutoff.Before(timeRange[0]) && !db.FirstCommentLoaded(ts.threadId) {
+			storedHighest = 0
+		}
+	}
+	ts.highestPostNumber = storedHighest
+
+	if ts.thread.highestPostNumber != 0 && ts.thread.highestPostNumber <= storedHighest {
+		return
+	}
+
+	var tp topicPosts
+	if err = ts.client.getJSON(fmt.Sprintf("/t/%d.json", topicId), nil, &tp); err != nil {
+		return
+	}
+
+	loaded := make(map[int]post)
+	for _, p := range tp.PostStream.Posts {
+		loaded[p.Id] = p
+	}
+
+	// Walk the stream from the newest post back, fetching posts not included
+	// in the first response a chunk at a time.
+	stream := tp.PostStream.Stream
+	for end := len(stream); end > 0; end -= chunkSize {
+		start := end - chunkSize
+		if start < 0 {
+			start = 0
+		}
+
+		query := url.Values{}
+		for _, id := range stream[start:end] {
+			if _, ok := loaded[id]; !ok {
+				query.Add("post_ids[]", strconv.Itoa(id))
+			}
+		}
+		if len(query) > 0 {
+			var chunk topicPosts
+			if err = ts.client.getJSON(fmt.Sprintf("/t/%d/posts.json", topicId), query, &chunk); err != nil {
+				return
+			}
+			for _, p := range chunk.PostStream.Posts {
+				loaded[p.Id] = p
+			}
+		}
+
+		done := false
+		for i := end - 1; i >= start; i-- {
+			p, ok := loaded[stream[i]]
+			if !ok {
+				continue
+			}
+			if p.PostNumber <= storedHighest || p.CreatedAt.Before(cutoff) {
+				done = true
+				continue
+			}
+			ts.Comments = append(ts.Comments, ts.client.toComment(p, tp.Slug))
+			if p.PostNumber > ts.highestPostNumber {
+				ts.highestPostNumber = p.PostNumber
+			}
+		}
+		if done {
+			break
+		}
+	}
+	return
+}
+
+// Loads only the posts included in the topic's first page.
+func (ts *ThreadScraper) LoadFirstPage() (err error) {
+	var topicId int
+	if topicId, err = parseTopicId(ts.thread.URL); err == nil {
+		var tp topicPosts
+		if err = ts.client.getJSON(fmt.Sprintf("/t/%d.json", topicId), nil, &tp); err == nil {
+			for _, p := range tp.PostStream.Posts {
+				ts.Comments = append(ts.Comments, ts.client.toComment(p, tp.Slug))
+			}
+		}
+	}
+	return
+}
@@ -0,0 +1,58 @@
+// Package history records each scrape in the scrape_run table, with the
+// requests it made and the content it changed.
+package history
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/scheduler"
+)
+
+// Runs scrape and records it as a run of target. Requests are counted for the
+// hosts the site at hostname is scraped through, so the caller must not
+// scrape the site elsewhere at the same time. Returns the run and the scrape's error; panics from the database
+// helpers are returned as errors.
+func Record(db *database.ScraperDB, target, hostname string, cutoff time.Time, scrape func() error) (run database.ScrapeRun, err error) {
+	run = database.ScrapeRun{Target: target, Cutoff: cutoff, Started: time.Now()}
+	if err = protect(func() (err error) {
+		run.Id, err = db.StartScrapeRun(target, cutoff, run.Started)
+		return
+	}); err != nil {
+		run.Error = err.Error()
+		return
+	}
+
+	hosts := adapter.RequestHosts(hostname)
+	before := scheduler.StatsFor(hosts...)
+	mark := db.CurrentContentMark()
+	err = protect(scrape)
+
+	run.Finished = time.Now()
+	stats := scheduler.StatsFor(hosts...).Since(before)
+	run.Requests, run.FailedRequests, run.Statuses, run.Bytes = stats.Requests, stats.Failed, stats.Statuses, stats.Bytes
+	if err != nil {
+		run.Error = err.Error()
+	}
+
+	recordErr := protect(func() error {
+		run.ContentChanges = db.ContentChangesSince(mark, hostname)
+		return db.FinishScrapeRun(run)
+	})
+	if err == nil {
+		err = recordErr
+	}
+	return
+}
+
+// The database helpers panic on errors.
+func protect(f func() error) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return f()
+}
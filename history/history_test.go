@@ -0,0 +1,86 @@
+package history
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/scheduler"
+)
+
+func TestRecord(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		w.Write([]byte("page"))
+	}))
+	defer server.Close()
+	scheduler.SetDefaultLimits(scheduler.Limits{})
+	t.Cleanup(func() { scheduler.SetDefaultLimits(scheduler.DefaultLimits) })
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL, _ := url.Parse(server.URL + "/forums/ev.2/")
+	hostname := forumURL.Hostname()
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+	addThread := func(path string) (threadId model.ThreadID) {
+		u, _ := url.Parse(server.URL + path)
+		threadId, err := db.InsertOrUpdateThread(siteId, forumId, model.Thread{URL: u, Author: "alice"})
+		require.Nil(t, err)
+		return
+	}
+	comment := func(path, content string, published int64) model.Comment {
+		u, _ := url.Parse(server.URL + path)
+		return model.Comment{URL: u, Author: "bob", Published: time.Unix(published, 0), Content: content}
+	}
+	oldThread := addThread("/threads/old.1/")
+	require.Nil(t, db.AddComments(siteId, oldThread, []model.Comment{comment("/posts/1", "First", 1)}))
+
+	client := &http.Client{Transport: scheduler.Transport(http.DefaultTransport)}
+	cutoff := time.Unix(1000, 0)
+	run, err := Record(db, forumURL.String(), hostname, cutoff, func() error {
+		for _, path := range []string{"/forums/ev.2/", "/private"} {
+			resp, err := client.Get(server.URL + path)
+			require.Nil(t, err)
+			resp.Body.Close()
+		}
+		// An edit and a reply in the old thread, and a new thread
+		require.Nil(t, db.AddComments(siteId, oldThread, []model.Comment{
+			comment("/posts/1", "First, edited", 1), comment("/posts/2", "Reply", 2)}))
+		newThread := addThread("/threads/new.3/")
+		require.Nil(t, db.AddComments(siteId, newThread, []model.Comment{comment("/posts/3", "Hello", 3)}))
+		return errors.New("Forbidden")
+	})
+	require.EqualError(t, err, "Forbidden")
+
+	stored, err := db.GetScrapeRun(run.Id)
+	require.Nil(t, err)
+	require.Equal(t, forumURL.String(), stored.Target)
+	require.Equal(t, cutoff, stored.Cutoff)
+	require.Equal(t, uint(2), stored.Requests)
+	require.Equal(t, map[int]uint{http.StatusOK: 1, http.StatusForbidden: 1}, stored.Statuses)
+	require.Equal(t, database.ContentChanges{NewThreads: 1, UpdatedThreads: 1, NewComments: 2, UpdatedComments: 1},
+		stored.ContentChanges)
+	require.Equal(t, "Forbidden", stored.Error)
+	require.False(t, stored.Finished.IsZero())
+
+	runs, err := db.ScrapeRuns("ev.2", time.Time{}, true, 10)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(runs))
+	runs, err = db.ScrapeRuns("other", time.Time{}, false, 10)
+	require.Nil(t, err)
+	require.Empty(t, runs)
+
+	_, err = db.GetScrapeRun(run.Id + 1)
+	require.ErrorIs(t, err, database.ErrNoScrapeRun)
+}
@@ -22,6 +22,10 @@ type Thread struct {
 	Latest    time.Time
 	Replies   uint
 	Views     uint
+
+	// The site's stable id for the author, if it shows one. Authors keep
+	// their id when they change their username.
+	AuthorExternalId string
 }
 
 type Comment struct {
@@ -29,6 +33,84 @@ type Comment struct {
 	Author    string
 	Published time.Time
 	Content   string
+	Quotes    []Quote
+	Links     []Link
+	Media     []Media
+
+	// The site's stable id for the author, if it shows one.
+	AuthorExternalId string
+
+	// The comment this one replies to, for engines with threaded replies.
+	// Nil for replies to the thread itself.
+	ParentURL *url.URL
+
+	// Net votes, for engines with voting.
+	Score *int
+
+	// The total number of reactions and the kinds given, for engines with
+	// reactions. A Reaction's Count is zero when the engine shows the kind
+	// but not how often it was given.
+	ReactionCount *uint
+	Reactions     []Reaction
+
+	// The author's profile as shown alongside the comment, if the engine
+	// shows one.
+	AuthorProfile *AuthorProfile
+
+	// The markup the comment was extracted from, if the scraper keeps it.
+	HTML string
+}
+
+// A Quote is another post quoted in a comment. Engines identify the quoted
+// post and its author in their own ways, so any of the fields may be empty.
+type Quote struct {
+	// The quoted comment, if the scraper could work out its URL.
+	URL      *url.URL
+	Author   string
+	PostId   string
+	MemberId string
+}
+
+// What a site shows about a member. MemberId is the site's stable id for the
+// member, which is kept when they change their username.
+type AuthorProfile struct {
+	MemberId      string
+	Username      string
+	Title         string
+	Joined        time.Time
+	Messages      uint
+	ReactionScore uint
+	Location      string
+}
+
+// A kind of reaction to a comment, such as "Like", and how many readers gave
+// it.
+type Reaction struct {
+	Kind  string
+	Count uint
+}
+
+// A hyperlink in a comment's content.
+type Link struct {
+	URL  *url.URL
+	Text string
+}
+
+type MediaKind string
+
+const (
+	Image      MediaKind = "image"
+	Video      MediaKind = "video"
+	Embed      MediaKind = "embed"
+	Attachment MediaKind = "attachment"
+)
+
+// An image, video, embedded player or attached file in a comment. Text is the
+// alt text, embed provider or file name, when there is one.
+type Media struct {
+	Kind MediaKind
+	URL  *url.URL
+	Text string
 }
 
 type Forum struct {
@@ -0,0 +1,95 @@
+package output
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/viper"
+)
+
+type Format string
+
+const (
+	Text   Format = "text"
+	JSON   Format = "json"
+	NDJSON Format = "ndjson"
+	CSV    Format = "csv"
+	TSV    Format = "tsv"
+)
+
+var Formats = []Format{Text, JSON, NDJSON, CSV, TSV}
+
+func ParseFormat(s string) (Format, error) {
+	for _, f := range Formats {
+		if string(f) == strings.ToLower(s) {
+			return f, nil
+		}
+	}
+	return "", fmt.Errorf("Unknown output format %q", s)
+}
+
+// Returns the format selected with the global --format flag.
+func CurrentFormat() Format {
+	if f, err := ParseFormat(viper.GetString("format")); err == nil {
+		return f
+	}
+	return Text
+}
+
+// Returns true when commands should produce their human-readable output.
+func IsText() bool {
+	return CurrentFormat() == Text
+}
+
+// A Record is a single row of structured output. Records are serialized as
+// objects using their json tags for json and ndjson, and as rows under a
+// header of Columns for csv and tsv.
+type Record interface {
+	Columns() []string
+	Values() []string
+}
+
+// Writes records to stdout in the format selected with --format.
+func Print[R Record](records []R) error {
+	return Write(os.Stdout, CurrentFormat(), records)
+}
+
+func Write[R Record](w io.Writer, format Format, records []R) (err error) {
+	switch format {
+	case JSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		enc.SetIndent("", "  ")
+		if records == nil {
+			records = []R{}
+		}
+		err = enc.Encode(records)
+	case NDJSON:
+		enc := json.NewEncoder(w)
+		enc.SetEscapeHTML(false)
+		for _, r := range records {
+			if err = enc.Encode(r); err != nil {
+				break
+			}
+		}
+	case CSV, TSV:
+		cw := csv.NewWriter(w)
+		if format == TSV {
+			cw.Comma = '\t'
+		}
+		var zero R
+		cw.Write(zero.Columns())
+		for _, r := range records {
+			cw.Write(r.Values())
+		}
+		cw.Flush()
+		err = cw.Error()
+	default:
+		err = fmt.Errorf("Format %q is not a structured format", format)
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package output
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/model"
+)
+
+func TestWrite(t *testing.T) {
+	commentUrl, err := url.Parse("https://some-forum.com/threads/xyz.1/post-2")
+	require.Nil(t, err)
+	records := NewComments([]model.Comment{{
+		URL:       commentUrl,
+		Author:    "somebody",
+		Published: time.Unix(123456789, 0),
+		Content:   "Some text, with a comma\nand a newline",
+	}})
+
+	var buf bytes.Buffer
+	require.Nil(t, Write(&buf, JSON, records))
+	var decoded []map[string]any
+	require.Nil(t, json.Unmarshal(buf.Bytes(), &decoded))
+	require.Equal(t, 1, len(decoded))
+	require.Equal(t, "somebody", decoded[0]["author"])
+	require.Equal(t, "1973-11-29T21:33:09Z", decoded[0]["published"])
+
+	buf.Reset()
+	require.Nil(t, Write(&buf, NDJSON, append(records, records...)))
+	require.Equal(t, 2, bytes.Count(buf.Bytes(), []byte("\n")))
+
+	buf.Reset()
+	require.Nil(t, Write(&buf, CSV, records))
+	require.Equal(t,
+		"url,author,published,content\n"+
+			"https://some-forum.com/threads/xyz.1/post-2,somebody,1973-11-29T21:33:09Z,\"Some text, with a comma\nand a newline\"\n",
+		buf.String())
+
+	buf.Reset()
+	require.Nil(t, Write(&buf, TSV, []Site{{Id: 1, Hostname: "some-forum.com"}}))
+	require.Equal(t, "id\thostname\n1\tsome-forum.com\n", buf.String())
+
+	// Empty results are still valid JSON arrays
+	buf.Reset()
+	require.Nil(t, Write(&buf, JSON, []Forum(nil)))
+	require.Equal(t, "[]\n", buf.String())
+
+	require.NotNil(t, Write(&buf, Text, records))
+}
+
+func TestParseFormat(t *testing.T) {
+	f, err := ParseFormat("NDJSON")
+	require.Nil(t, err)
+	require.Equal(t, NDJSON, f)
+
+	_, err = ParseFormat("xml")
+	require.NotNil(t, err)
+}
+
+func TestFormatStatuses(t *testing.T) {
+	require.Equal(t, "", FormatStatuses(nil))
+	require.Equal(t, "200:41 403:2 503:1", FormatStatuses(map[int]uint{503: 1, 200: 41, 403: 2}))
+}
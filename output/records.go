@@ -0,0 +1,463 @@
+package output
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/model"
+)
+
+func formatTime(t time.Time) string {
+	return t.UTC().Format(time.RFC3339)
+}
+
+func formatUint(u uint) string {
+	return strconv.FormatUint(uint64(u), 10)
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Thread struct {
+	Id        model.ThreadID `json:"id"`
+	URL       string         `json:"url"`
+	Title     string         `json:"title"`
+	Author    string         `json:"author"`
+	StartDate time.Time      `json:"start_date"`
+	Latest    time.Time      `json:"latest_activity"`
+	Replies   uint           `json:"replies"`
+	Views     uint           `json:"views"`
+}
+
+func NewThread(t model.Thread) Thread {
+	return Thread{
+		Id:        t.Id,
+		URL:       t.URL.String(),
+		Title:     t.Title,
+		Author:    t.Author,
+		StartDate: t.StartDate.UTC(),
+		Latest:    t.Latest.UTC(),
+		Replies:   t.Replies,
+		Views:     t.Views,
+	}
+}
+
+func (Thread) Columns() []string {
+	return []string{"id", "url", "title", "author", "start_date", "latest_activity", "replies", "views"}
+}
+
+func (t Thread) Values() []string {
+	return []string{
+		formatUint(uint(t.Id)), t.URL, t.Title, t.Author,
+		formatTime(t.StartDate), formatTime(t.Latest),
+		formatUint(t.Replies), formatUint(t.Views),
+	}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Comment struct {
+	URL       string    `json:"url"`
+	Author    string    `json:"author"`
+	Published time.Time `json:"published"`
+	Content   string    `json:"content"`
+}
+
+func NewComment(c model.Comment) Comment {
+	return Comment{
+		URL:       c.URL.String(),
+		Author:    c.Author,
+		Published: c.Published.UTC(),
+		Content:   c.Content,
+	}
+}
+
+func NewComments(comments []model.Comment) (records []Comment) {
+	for _, c := range comments {
+		records = append(records, NewComment(c))
+	}
+	return
+}
+
+func (Comment) Columns() []string {
+	return []string{"url", "author", "published", "content"}
+}
+
+func (c Comment) Values() []string {
+	return []string{c.URL, c.Author, formatTime(c.Published), c.Content}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Forum struct {
+	Id  model.ForumID `json:"id"`
+	URL string        `json:"url"`
+}
+
+func NewForum(f model.Forum) Forum {
+	return Forum{Id: f.Id, URL: f.URL.String()}
+}
+
+func (Forum) Columns() []string {
+	return []string{"id", "url"}
+}
+
+func (f Forum) Values() []string {
+	return []string{formatUint(uint(f.Id)), f.URL}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Site struct {
+	Id       model.SiteID `json:"id"`
+	Hostname string       `json:"hostname"`
+}
+
+func (Site) Columns() []string {
+	return []string{"id", "hostname"}
+}
+
+func (s Site) Values() []string {
+	return []string{formatUint(uint(s.Id)), s.Hostname}
+}
+
+/*---------------------------------------------------------------------------*/
+
+// An author's activity aggregated over their comments.
+type AuthorSummary struct {
+	Id       model.AuthorID `json:"id"`
+	Username string         `json:"username"`
+	Site     string         `json:"site"`
+	Comments uint           `json:"comments"`
+	Latest   time.Time      `json:"latest"`
+}
+
+func (AuthorSummary) Columns() []string {
+	return []string{"id", "username", "site", "comments", "latest"}
+}
+
+func (a AuthorSummary) Values() []string {
+	return []string{formatUint(uint(a.Id)), a.Username, a.Site, formatUint(a.Comments), formatTime(a.Latest)}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Participant struct {
+	Username string `json:"username"`
+}
+
+func (Participant) Columns() []string {
+	return []string{"username"}
+}
+
+func (p Participant) Values() []string {
+	return []string{p.Username}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Adapter struct {
+	Name      string   `json:"name"`
+	URLShapes []string `json:"url_shapes"`
+}
+
+func (Adapter) Columns() []string {
+	return []string{"name", "url_shapes"}
+}
+
+func (a Adapter) Values() []string {
+	return []string{a.Name, strings.Join(a.URLShapes, " ")}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type CommentRevision struct {
+	URL      string    `json:"url"`
+	Revision uint      `json:"revision"`
+	Seen     time.Time `json:"seen"`
+	Content  string    `json:"content"`
+}
+
+func (CommentRevision) Columns() []string {
+	return []string{"url", "revision", "seen", "content"}
+}
+
+func (r CommentRevision) Values() []string {
+	return []string{r.URL, formatUint(r.Revision), formatTime(r.Seen), r.Content}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type RemovedComment struct {
+	Comment
+	RemovedAt time.Time `json:"removed_at"`
+}
+
+func (RemovedComment) Columns() []string {
+	return append(Comment{}.Columns(), "removed_at")
+}
+
+func (r RemovedComment) Values() []string {
+	return append(r.Comment.Values(), formatTime(r.RemovedAt))
+}
+
+/*---------------------------------------------------------------------------*/
+
+type ReplyEdge struct {
+	From  string `json:"from"`
+	To    string `json:"to"`
+	Count uint   `json:"count"`
+}
+
+func (ReplyEdge) Columns() []string {
+	return []string{"from", "to", "count"}
+}
+
+func (e ReplyEdge) Values() []string {
+	return []string{e.From, e.To, formatUint(e.Count)}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Interaction struct {
+	Username string `json:"username"`
+	Quoted   uint   `json:"quoted"`
+	QuotedBy uint   `json:"quoted_by"`
+}
+
+func (Interaction) Columns() []string {
+	return []string{"username", "quoted", "quoted_by"}
+}
+
+func (i Interaction) Values() []string {
+	return []string{i.Username, formatUint(i.Quoted), formatUint(i.QuotedBy)}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Link struct {
+	Comment   string    `json:"comment"`
+	Published time.Time `json:"published"`
+	URL       string    `json:"url"`
+	Text      string    `json:"text"`
+}
+
+func (Link) Columns() []string {
+	return []string{"comment", "published", "url", "text"}
+}
+
+func (l Link) Values() []string {
+	return []string{l.Comment, formatTime(l.Published), l.URL, l.Text}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type DomainCount struct {
+	Domain string `json:"domain"`
+	Count  uint   `json:"count"`
+}
+
+func (DomainCount) Columns() []string {
+	return []string{"domain", "count"}
+}
+
+func (d DomainCount) Values() []string {
+	return []string{d.Domain, formatUint(d.Count)}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Media struct {
+	Comment   string    `json:"comment"`
+	Author    string    `json:"author"`
+	Published time.Time `json:"published"`
+	Kind      string    `json:"kind"`
+	URL       string    `json:"url"`
+	Text      string    `json:"text"`
+}
+
+func (Media) Columns() []string {
+	return []string{"comment", "author", "published", "kind", "url", "text"}
+}
+
+func (m Media) Values() []string {
+	return []string{m.Comment, m.Author, formatTime(m.Published), m.Kind, m.URL, m.Text}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Author struct {
+	Site           string    `json:"site"`
+	Username       string    `json:"username"`
+	ExternalId     string    `json:"external_id,omitempty"`
+	Aliases        []string  `json:"aliases"`
+	Title          string    `json:"title,omitempty"`
+	Joined         time.Time `json:"joined"`
+	Messages       uint      `json:"messages"`
+	ReactionScore  uint      `json:"reaction_score"`
+	Location       string    `json:"location,omitempty"`
+	Comments       uint      `json:"comments"`
+	Threads        uint      `json:"threads"`
+	ThreadsStarted uint      `json:"threads_started"`
+	FirstComment   time.Time `json:"first_comment"`
+	LastComment    time.Time `json:"last_comment"`
+}
+
+func (Author) Columns() []string {
+	return []string{"site", "username", "external_id", "aliases", "title", "joined", "messages", "reaction_score",
+		"location", "comments", "threads", "threads_started", "first_comment", "last_comment"}
+}
+
+func (a Author) Values() []string {
+	return []string{a.Site, a.Username, a.ExternalId, strings.Join(a.Aliases, ","), a.Title, formatTime(a.Joined),
+		formatUint(a.Messages), formatUint(a.ReactionScore), a.Location, formatUint(a.Comments), formatUint(a.Threads),
+		formatUint(a.ThreadsStarted), formatTime(a.FirstComment), formatTime(a.LastComment)}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type RankedComment struct {
+	URL           string    `json:"url"`
+	Author        string    `json:"author"`
+	Published     time.Time `json:"published"`
+	Score         *int      `json:"score"`
+	ReactionCount *uint     `json:"reaction_count"`
+	Reactions     []string  `json:"reactions"`
+}
+
+func (RankedComment) Columns() []string {
+	return []string{"url", "author", "published", "score", "reaction_count", "reactions"}
+}
+
+func (c RankedComment) Values() []string {
+	var score, reactionCount string
+	if c.Score != nil {
+		score = strconv.Itoa(*c.Score)
+	}
+	if c.ReactionCount != nil {
+		reactionCount = formatUint(*c.ReactionCount)
+	}
+	return []string{c.URL, c.Author, formatTime(c.Published), score, reactionCount, strings.Join(c.Reactions, ",")}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type RankedAuthor struct {
+	Username string `json:"username"`
+	Site     string `json:"site"`
+	Comments uint   `json:"comments"`
+	Total    int    `json:"total"`
+}
+
+func (RankedAuthor) Columns() []string {
+	return []string{"username", "site", "comments", "total"}
+}
+
+func (a RankedAuthor) Values() []string {
+	return []string{a.Username, a.Site, formatUint(a.Comments), strconv.Itoa(a.Total)}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Setting struct {
+	Key   string `json:"key"`
+	Value string `json:"value"`
+}
+
+func (Setting) Columns() []string {
+	return []string{"key", "value"}
+}
+
+func (s Setting) Values() []string {
+	return []string{s.Key, s.Value}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type Watch struct {
+	Name      string    `json:"name"`
+	Kind      string    `json:"kind"`
+	Query     string    `json:"query"`
+	Scope     string    `json:"scope"`
+	Sink      string    `json:"sink"`
+	HighWater uint      `json:"high_water"`
+	Created   time.Time `json:"created"`
+}
+
+func (Watch) Columns() []string {
+	return []string{"name", "kind", "query", "scope", "sink", "high_water", "created"}
+}
+
+func (w Watch) Values() []string {
+	return []string{w.Name, w.Kind, w.Query, w.Scope, w.Sink, formatUint(w.HighWater), formatTime(w.Created)}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type WatchResult struct {
+	Watch   string `json:"watch"`
+	Matches int    `json:"matches"`
+	Error   string `json:"error,omitempty"`
+}
+
+func (WatchResult) Columns() []string {
+	return []string{"watch", "matches", "error"}
+}
+
+func (r WatchResult) Values() []string {
+	return []string{r.Watch, strconv.Itoa(r.Matches), r.Error}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type ScrapeRun struct {
+	Id              uint         `json:"id"`
+	Target          string       `json:"target"`
+	Cutoff          time.Time    `json:"cutoff"`
+	Started         time.Time    `json:"started"`
+	Finished        time.Time    `json:"finished"`
+	DurationSeconds float64      `json:"duration_seconds"`
+	Requests        uint         `json:"requests"`
+	FailedRequests  uint         `json:"failed_requests"`
+	Statuses        map[int]uint `json:"statuses"`
+	Bytes           uint64       `json:"bytes"`
+	NewThreads      uint         `json:"new_threads"`
+	UpdatedThreads  uint         `json:"updated_threads"`
+	NewComments     uint         `json:"new_comments"`
+	UpdatedComments uint         `json:"updated_comments"`
+	Error           string       `json:"error,omitempty"`
+}
+
+func (ScrapeRun) Columns() []string {
+	return []string{"id", "target", "cutoff", "started", "finished", "duration_seconds",
+		"requests", "failed_requests", "statuses", "bytes",
+		"new_threads", "updated_threads", "new_comments", "updated_comments", "error"}
+}
+
+func (r ScrapeRun) Values() []string {
+	return []string{
+		formatUint(r.Id), r.Target, formatTime(r.Cutoff), formatTime(r.Started), formatTime(r.Finished),
+		strconv.FormatFloat(r.DurationSeconds, 'f', -1, 64),
+		formatUint(r.Requests), formatUint(r.FailedRequests), FormatStatuses(r.Statuses),
+		strconv.FormatUint(r.Bytes, 10),
+		formatUint(r.NewThreads), formatUint(r.UpdatedThreads),
+		formatUint(r.NewComments), formatUint(r.UpdatedComments), r.Error,
+	}
+}
+
+// Formats counts of status codes like "200:41 403:2", in order of code.
+func FormatStatuses(statuses map[int]uint) string {
+	codes := make([]int, 0, len(statuses))
+	for code := range statuses {
+		codes = append(codes, code)
+	}
+	sort.Ints(codes)
+
+	counts := make([]string, len(codes))
+	for i, code := range codes {
+		counts[i] = fmt.Sprintf("%d:%d", code, statuses[code])
+	}
+	return strings.Join(counts, " ")
+}
@@ -0,0 +1,69 @@
+package phpbb_scraper
+
+import (
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+func init() {
+	adapter.Register(phpbbAdapter{})
+}
+
+type phpbbAdapter struct{}
+
+func (phpbbAdapter) Name() string {
+	return "phpbb"
+}
+
+func (phpbbAdapter) URLShapes() []string {
+	return []string{
+		"https://<host>/<board>/index.php",
+		"https://<host>/<board>/viewforum.php?f=<id>",
+		"https://<host>/<board>/viewtopic.php?t=<id>",
+	}
+}
+
+func (phpbbAdapter) Matches(u *url.URL) adapter.URLKind {
+	switch path.Base(u.Path) {
+	case "index.php", "viewforum.php":
+		return adapter.ForumURL
+	case "viewtopic.php":
+		if u.Query().Get("t") != "" {
+			return adapter.ThreadURL
+		}
+	}
+	return adapter.NoMatch
+}
+
+func (phpbbAdapter) ScrapeForum(db *database.ScraperDB, u *url.URL, cutoff time.Time, subforums bool) error {
+	fs := NewForumScraper(u)
+	return fs.LoadThreadsWithActivitySince(db, cutoff, subforums)
+}
+
+func (phpbbAdapter) ScrapeThread(db *database.ScraperDB, thread model.Thread, cutoff time.Time) ([]model.Comment, error) {
+	ts := NewThreadScraper(thread.Id, PhpbbThread{thread})
+	err := ts.LoadCommentsSince(db, cutoff)
+	return ts.comments(), err
+}
+
+func (phpbbAdapter) ParsePage(u *url.URL) (page adapter.Page, err error) {
+	if path.Base(u.Path) == "viewtopic.php" {
+		ts := NewThreadScraper(0, PhpbbThread{model.Thread{URL: u}})
+		ts.errs.visit(ts.CommentScraper, u.String())
+		page.Comments = ts.comments()
+		err = ts.errs.err
+	} else {
+		fs := NewForumScraper(u)
+		err = fs.Visit(u.String())
+		for _, t := range fs.Threads {
+			page.Threads = append(page.Threads, t.Thread)
+		}
+		page.SubForums = fs.SubForums
+	}
+	return
+}
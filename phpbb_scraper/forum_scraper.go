@@ -0,0 +1,155 @@
+package phpbb_scraper
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+	"github.com/zvonler/espy/database"
+)
+
+type ForumScraper struct {
+	forumURL  *url.URL
+	Threads   []PhpbbThread
+	SubForums []*url.URL
+	Collector *colly.Collector
+	errs      visitErrors
+	perPage   int
+	lastStart int
+}
+
+func NewForumScraper(forumURL *url.URL) *ForumScraper {
+	fs := new(ForumScraper)
+	fs.Threads = make([]PhpbbThread, 0)
+	fs.Collector = newCollector()
+	fs.forumURL = forumURL
+
+	fs.Collector.OnHTML("html", func(e *colly.HTMLElement) {
+		threads, subForums := parseTopicList(e.DOM, e.Request.URL)
+		fs.Threads = append(fs.Threads, threads...)
+		fs.SubForums = append(fs.SubForums, subForums...)
+		if fs.perPage == 0 {
+			fs.perPage, fs.lastStart = parsePagination(e.DOM, e.Request.URL)
+		}
+	})
+
+	fs.Collector.OnRequest(func(r *colly.Request) {
+		fmt.Println("ForumScraper visiting", r.URL.String())
+	})
+
+	fs.Collector.OnError(func(r *colly.Response, err error) {
+		fmt.Printf("ForumScraper got %v for %s\n", err, r.Request.URL)
+		fs.errs.onError(r, err)
+	})
+
+	return fs
+}
+
+// Parses the sub-forum and topic rows of a forum index or viewforum page.
+func parseTopicList(s *goquery.Selection, base *url.URL) (threads []PhpbbThread, subForums []*url.URL) {
+	s.Find("ul.forums a.forumtitle").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		if u, err := canonicalURL(base, href, "f"); err == nil {
+			subForums = append(subForums, u)
+		} else {
+			log.Printf("Failed to parse forum href %q\n", href)
+		}
+	})
+
+	s.Find("ul.topics li.row").Each(func(_ int, row *goquery.Selection) {
+		temp := PhpbbThread{}
+
+		title := row.Find("a.topictitle").First()
+		temp.Title = title.Text()
+		if href, exists := title.Attr("href"); exists {
+			if u, err := canonicalURL(base, href, "t"); err == nil {
+				temp.URL = u
+			}
+		}
+
+		poster := row.Find("dt div.left-box, dt div.topic-poster").First()
+		temp.Author = poster.Find(".username, .username-coloured").First().Text()
+		if tm, ok := parseDateTime(poster.Find("time").First()); ok {
+			temp.StartDate = tm
+		}
+
+		temp.Replies = parseCount(row.Find("dd.posts").Text())
+		temp.Views = parseCount(row.Find("dd.views").Text())
+
+		if tm, ok := parseDateTime(row.Find("dd.lastpost time").First()); ok {
+			temp.Latest = tm
+		} else {
+			temp.Latest = temp.StartDate
+		}
+
+		if temp.URL != nil {
+			threads = append(threads, temp)
+		} else {
+			fmt.Printf("Skipping topic %q\n", temp.Title)
+		}
+	})
+	return
+}
+
+// Visits u, returning the error if the page couldn't be fetched.
+func (fs *ForumScraper) Visit(u string) error {
+	fs.errs.visit(fs.Collector, u)
+	return fs.errs.err
+}
+
+// Scrapes the topics with activity since cutoff, stopping at the first page
+// that can't be fetched. The posts already scraped from a topic are stored
+// even if later pages of it fail.
+func (fs *ForumScraper) LoadThreadsWithActivitySince(db *database.ScraperDB, cutoff time.Time, subforums bool) (err error) {
+	siteId, forumId, err := db.InsertOrUpdateForum(fs.forumURL)
+	if err != nil {
+		return
+	}
+
+	fs.errs.visit(fs.Collector, fs.forumURL.String())
+
+	// Topics are listed by latest activity after any stickies, so stop paging
+	// once the last topic on a page is older than the cutoff.
+	for start := fs.perPage; fs.perPage > 0 && start <= fs.lastStart && fs.errs.err == nil; start += fs.perPage {
+		if len(fs.Threads) == 0 || fs.Threads[len(fs.Threads)-1].Latest.Before(cutoff) {
+			break
+		}
+		fs.errs.visit(fs.Collector, withStart(fs.forumURL, start).String())
+	}
+	if err = fs.errs.err; err != nil {
+		return
+	}
+
+	for _, thread := range fs.Threads {
+		if thread.Latest.Before(cutoff) {
+			continue
+		}
+		threadId, err := db.InsertOrUpdateThread(siteId, forumId, thread.Thread)
+		if err != nil {
+			return err
+		}
+		ts := NewThreadScraper(threadId, thread)
+		loadErr := ts.LoadCommentsSince(db, cutoff)
+		if err = db.AddComments(siteId, threadId, ts.comments()); err != nil {
+			return err
+		}
+		if loadErr != nil {
+			return loadErr
+		}
+	}
+
+	if subforums {
+		for _, subForumURL := range fs.SubForums {
+			sfs := NewForumScraper(subForumURL)
+			if err = sfs.LoadThreadsWithActivitySince(db, cutoff, subforums); err != nil {
+				return
+			}
+		}
+	}
+
+	db.SetForumLastScraped(forumId, time.Now())
+	return
+}
@@ -0,0 +1,191 @@
+package phpbb_scraper
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/replay"
+	"github.com/zvonler/espy/scheduler"
+	"github.com/zvonler/espy/session"
+	"golang.org/x/net/html"
+)
+
+/*---------------------------------------------------------------------------*/
+
+type PhpbbThread struct {
+	model.Thread
+}
+
+// phpBB paginates with an offset into the topic's posts rather than a page
+// number.
+func (t PhpbbThread) pageURL(start int) *url.URL {
+	return withStart(t.URL, start)
+}
+
+/*---------------------------------------------------------------------------*/
+
+type PhpbbComment struct {
+	model.Comment
+}
+
+/*---------------------------------------------------------------------------*/
+
+// Keeps the first error from a scraper's visits, including the failed
+// responses colly only reports to OnError callbacks.
+type visitErrors struct {
+	err error
+}
+
+func (v *visitErrors) record(u string, err error) {
+	if err != nil && v.err == nil && !errors.Is(err, colly.ErrAlreadyVisited) {
+		v.err = fmt.Errorf("Fetching %s: %w", u, err)
+	}
+}
+
+// Visits u with c, recording the error if the visit fails.
+func (v *visitErrors) visit(c *colly.Collector, u string) {
+	v.record(u, c.Visit(u))
+}
+
+// For use in OnError callbacks.
+func (v *visitErrors) onError(r *colly.Response, err error) {
+	v.record(r.Request.URL.String(), err)
+}
+
+/*---------------------------------------------------------------------------*/
+
+func newCollector() *colly.Collector {
+	collector := colly.NewCollector(
+		colly.IgnoreRobotsTxt(),
+		colly.UserAgent("Mozilla"),
+	)
+	collector.WithTransport(session.Transport(replay.Wrap(scheduler.Transport(http.DefaultTransport))))
+	collector.SetCookieJar(session.Jar())
+	return collector
+}
+
+// Resolves href against base and keeps only the named query parameters, so
+// session ids and redundant forum ids don't create duplicate URLs.
+func canonicalURL(base *url.URL, href string, keep ...string) (*url.URL, error) {
+	ref, err := url.Parse(href)
+	if err != nil {
+		return nil, err
+	}
+	resolved := base.ResolveReference(ref)
+	query := resolved.Query()
+	kept := url.Values{}
+	for _, k := range keep {
+		if v := query.Get(k); v != "" {
+			kept.Set(k, v)
+		}
+	}
+	resolved.RawQuery = kept.Encode()
+	resolved.Fragment = ""
+	return resolved, nil
+}
+
+func withStart(u *url.URL, start int) *url.URL {
+	paged := *u
+	query := paged.Query()
+	if start > 0 {
+		query.Set("start", strconv.Itoa(start))
+	} else {
+		query.Del("start")
+	}
+	paged.RawQuery = query.Encode()
+	return &paged
+}
+
+// Returns the number of items per page and the start offset of the last page
+// from a page's pagination links. Both are zero for single-page listings.
+// Only the first page is sure to link to the second, so the results are only
+// right for it; deeper pages elide the pages far from them, like
+// "1 … 4 5 6 … 20".
+func parsePagination(s *goquery.Selection, base *url.URL) (perPage, lastStart int) {
+	s.Find("div.action-bar div.pagination a[href]").Each(func(_ int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		if u, err := url.Parse(href); err == nil {
+			if start, err := strconv.Atoi(u.Query().Get("start")); err == nil && start > 0 {
+				if perPage == 0 || start < perPage {
+					perPage = start
+				}
+				if start > lastStart {
+					lastStart = start
+				}
+			}
+		}
+	})
+	return
+}
+
+func parseDateTime(s *goquery.Selection) (tm time.Time, ok bool) {
+	if datetime, exists := s.Attr("datetime"); exists {
+		var err error
+		tm, err = time.Parse(time.RFC3339, datetime)
+		ok = err == nil
+	}
+	return
+}
+
+// Returns the leading count from text like "1.2K Replies".
+func parseCount(text string) (res uint) {
+	if fields := strings.Fields(text); len(fields) > 0 {
+		c := strings.ReplaceAll(fields[0], ",", "")
+		if len(c) == 0 {
+			return
+		}
+		multiplier := 1.0
+		switch c[len(c)-1] {
+		case 'K', 'k':
+			multiplier, c = 1e3, c[:len(c)-1]
+		case 'M', 'm':
+			multiplier, c = 1e6, c[:len(c)-1]
+		}
+		if val, err := strconv.ParseFloat(c, 64); err == nil {
+			res = uint(val * multiplier)
+		}
+	}
+	return
+}
+
+var (
+	wsLinePat = regexp.MustCompile("\n[ \t]+\n")
+	nlPat     = regexp.MustCompile("\n\n+")
+)
+
+// Returns the text of a post body without quoted replies, normalized the same
+// way as XenForo content.
+func extractContent(s *goquery.Selection) (content string) {
+	var collectText func(*html.Node)
+	collectText = func(n *html.Node) {
+		if n.Type == html.ElementNode && n.Data == "blockquote" {
+			return
+		}
+		if n.Type == html.ElementNode && n.Data == "br" {
+			content += "\n"
+		}
+		if n.Type == html.TextNode {
+			content += n.Data
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collectText(c)
+		}
+	}
+	for _, n := range s.Nodes {
+		collectText(n)
+	}
+
+	content = strings.ReplaceAll(content, "\u00a0", " ")
+	content = wsLinePat.ReplaceAllString(content, "\n")
+	content = nlPat.ReplaceAllString(content, "\n")
+	return strings.TrimSpace(content)
+}
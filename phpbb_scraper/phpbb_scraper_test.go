@@ -0,0 +1,202 @@
+package phpbb_scraper
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/scheduler"
+)
+
+func loadFixture(t *testing.T, name string) *goquery.Document {
+	fd, err := os.Open("testdata/" + name)
+	require.Nil(t, err)
+	defer fd.Close()
+	doc, err := goquery.NewDocumentFromReader(fd)
+	require.Nil(t, err)
+	return doc
+}
+
+func mustParse(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	require.Nil(t, err)
+	return u
+}
+
+// Serves the fixtures by request URI, recording the URIs requested.
+func serveFixtures(t *testing.T, fixtures map[string]string) (server *httptest.Server, requested *[]string) {
+	requested = new([]string)
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requested = append(*requested, r.URL.RequestURI())
+		if name, ok := fixtures[r.URL.RequestURI()]; ok {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+			http.ServeFile(w, r, "testdata/"+name)
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	scheduler.SetDefaultLimits(scheduler.Limits{})
+	t.Cleanup(func() { scheduler.SetDefaultLimits(scheduler.DefaultLimits) })
+	return
+}
+
+func TestParseTopicList(t *testing.T) {
+	base := mustParse(t, "https://board.example.com/forum/viewforum.php?f=2")
+	doc := loadFixture(t, "viewforum_f2.html")
+
+	threads, subForums := parseTopicList(doc.Selection, base)
+	require.Equal(t, 1, len(subForums))
+	require.Equal(t, "https://board.example.com/forum/viewforum.php?f=3", subForums[0].String())
+
+	require.Equal(t, 2, len(threads))
+	require.Equal(t, "Battery warranty experiences", threads[0].Title)
+	require.Equal(t, "https://board.example.com/forum/viewtopic.php?t=10", threads[0].URL.String())
+	require.Equal(t, "alice", threads[0].Author)
+	require.Equal(t, time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC), threads[0].StartDate.UTC())
+	require.Equal(t, time.Date(2023, 10, 2, 8, 30, 0, 0, time.UTC), threads[0].Latest.UTC())
+	require.Equal(t, uint(2), threads[0].Replies)
+	require.Equal(t, uint(1200), threads[0].Views)
+	require.Equal(t, "guest_poster", threads[1].Author)
+
+	perPage, lastStart := parsePagination(doc.Selection, base)
+	require.Equal(t, 2, perPage)
+	require.Equal(t, 2, lastStart)
+
+	threads, subForums = parseTopicList(loadFixture(t, "index.html").Selection, mustParse(t, "https://board.example.com/forum/index.php"))
+	require.Empty(t, threads)
+	require.Equal(t, 2, len(subForums))
+	require.Equal(t, "https://board.example.com/forum/viewforum.php?f=4", subForums[1].String())
+}
+
+func TestParsePosts(t *testing.T) {
+	base := mustParse(t, "https://board.example.com/forum/viewtopic.php?t=10")
+	doc := loadFixture(t, "viewtopic_t10.html")
+
+	comments := parsePosts(doc.Selection, base)
+	require.Equal(t, 2, len(comments))
+	require.Equal(t, "https://board.example.com/forum/viewtopic.php?p=101#p101", comments[0].URL.String())
+	require.Equal(t, "alice", comments[0].Author)
+	require.Equal(t, "Has anyone had a battery pack replaced under warranty?\nMine failed at 70k miles.", comments[0].Content)
+
+	// Quoted text is excluded from the reply
+	require.Equal(t, "bob", comments[1].Author)
+	require.Equal(t, "Same here, it took three weeks.", comments[1].Content)
+	require.Equal(t, time.Date(2023, 10, 1, 18, 45, 0, 0, time.UTC), comments[1].Published.UTC())
+
+	perPage, lastStart := parsePagination(doc.Selection, base)
+	require.Equal(t, 2, perPage)
+	require.Equal(t, 2, lastStart)
+}
+
+func TestScrapeForum(t *testing.T) {
+	fixtures := map[string]string{
+		"/viewforum.php?f=2":          "viewforum_f2.html",
+		"/viewforum.php?f=2&start=2":  "viewforum_f2_start2.html",
+		"/viewtopic.php?t=10":         "viewtopic_t10.html",
+		"/viewtopic.php?start=2&t=10": "viewtopic_t10_start2.html",
+		"/viewtopic.php?t=11":         "viewtopic_t11.html",
+	}
+	server, requested := serveFixtures(t, fixtures)
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	cutoff := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	fs := NewForumScraper(mustParse(t, server.URL+"/viewforum.php?f=2"))
+	require.Nil(t, fs.LoadThreadsWithActivitySince(db, cutoff, false))
+
+	// The topic older than the cutoff on page 2 is not loaded
+	require.NotContains(t, *requested, "/viewtopic.php?t=12")
+
+	thread, err := db.GetThreadByURL(mustParse(t, server.URL+"/viewtopic.php?t=10"))
+	require.Nil(t, err)
+	comments, err := db.ThreadComments(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(comments))
+	require.Equal(t, "Update: the replacement pack is working fine.", comments[2].Content)
+
+	thread, err = db.GetThreadByURL(mustParse(t, server.URL+"/viewtopic.php?t=11"))
+	require.Nil(t, err)
+	comments, err = db.ThreadComments(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(comments))
+}
+
+func TestScrapeReportsFetchErrors(t *testing.T) {
+	// The second topic's page is missing
+	fixtures := map[string]string{
+		"/viewforum.php?f=2":          "viewforum_f2.html",
+		"/viewforum.php?f=2&start=2":  "viewforum_f2_start2.html",
+		"/viewtopic.php?t=10":         "viewtopic_t10.html",
+		"/viewtopic.php?start=2&t=10": "viewtopic_t10_start2.html",
+	}
+	server, _ := serveFixtures(t, fixtures)
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	cutoff := time.Date(2023, 9, 1, 0, 0, 0, 0, time.UTC)
+	err = phpbbAdapter{}.ScrapeForum(db, mustParse(t, server.URL+"/viewforum.php?f=2"), cutoff, false)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "/viewtopic.php?t=11")
+
+	// The topic scraped before the failure is kept
+	thread, err := db.GetThreadByURL(mustParse(t, server.URL+"/viewtopic.php?t=10"))
+	require.Nil(t, err)
+	comments, err := db.ThreadComments(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(comments))
+
+	thread, err = db.GetThreadByURL(mustParse(t, server.URL+"/viewtopic.php?t=11"))
+	require.Nil(t, err)
+	_, err = phpbbAdapter{}.ScrapeThread(db, thread, cutoff)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "Not Found")
+}
+
+func TestScrapeWindowedPagination(t *testing.T) {
+	// The last page links only to pages 1 and 3, so its smallest start is
+	// twice the page size
+	fixtures := map[string]string{
+		"/viewtopic.php?t=20":         "viewtopic_t20.html",
+		"/viewtopic.php?start=2&t=20": "viewtopic_t20_start2.html",
+		"/viewtopic.php?start=4&t=20": "viewtopic_t20_start4.html",
+		"/viewtopic.php?start=6&t=20": "viewtopic_t20_start6.html",
+	}
+	server, requested := serveFixtures(t, fixtures)
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	thread := PhpbbThread{model.Thread{URL: mustParse(t, server.URL+"/viewtopic.php?t=20")}}
+	ts := NewThreadScraper(0, thread)
+	require.Nil(t, ts.LoadCommentsSince(db, time.Date(2023, 1, 1, 0, 0, 0, 0, time.UTC)))
+
+	require.Equal(t, []string{
+		"/viewtopic.php?t=20",
+		"/viewtopic.php?start=6&t=20",
+		"/viewtopic.php?start=4&t=20",
+		"/viewtopic.php?start=2&t=20",
+	}, *requested)
+	require.Equal(t, 8, len(ts.Comments))
+}
+
+func TestParseCount(t *testing.T) {
+	require.Equal(t, uint(0), parseCount(""))
+	require.Equal(t, uint(0), parseCount(", Replies"))
+	require.Equal(t, uint(1234), parseCount("1,234 Views"))
+	require.Equal(t, uint(1200), parseCount("1.2K Replies"))
+	require.Equal(t, uint(3000000), parseCount("3M"))
+}
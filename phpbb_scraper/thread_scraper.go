@@ -0,0 +1,115 @@
+package phpbb_scraper
+
+import (
+	"fmt"
+	"log"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+type ThreadScraper struct {
+	threadId        model.ThreadID
+	thread          PhpbbThread
+	Comments        []PhpbbComment
+	CommentScraper  *colly.Collector
+	errs            visitErrors
+	perPage         int
+	lastStart       int
+	earliestScraped time.Time
+}
+
+func NewThreadScraper(threadId model.ThreadID, thread PhpbbThread) *ThreadScraper {
+	ts := new(ThreadScraper)
+	ts.threadId = threadId
+	ts.thread = thread
+	ts.Comments = make([]PhpbbComment, 0)
+
+	ts.CommentScraper = newCollector()
+	ts.CommentScraper.OnHTML("html", func(e *colly.HTMLElement) {
+		for _, c := range parsePosts(e.DOM, e.Request.URL) {
+			if ts.earliestScraped.IsZero() || c.Published.Before(ts.earliestScraped) {
+				ts.earliestScraped = c.Published
+			}
+			ts.Comments = append(ts.Comments, c)
+		}
+		if ts.perPage == 0 {
+			ts.perPage, ts.lastStart = parsePagination(e.DOM, e.Request.URL)
+		}
+	})
+
+	ts.CommentScraper.OnRequest(func(r *colly.Request) {
+		fmt.Printf("CommentScraper (%d) visiting %s\n", ts.threadId, r.URL.String())
+	})
+
+	ts.CommentScraper.OnError(func(r *colly.Response, err error) {
+		fmt.Printf("CommentScraper (%d) got %v for %s\n", ts.threadId, err, r.Request.URL)
+		ts.errs.onError(r, err)
+	})
+
+	return ts
+}
+
+// Parses the posts on a viewtopic page.
+func parsePosts(s *goquery.Selection, base *url.URL) (comments []PhpbbComment) {
+	s.Find("div.post").Each(func(_ int, post *goquery.Selection) {
+		temp := PhpbbComment{}
+
+		postId := strings.TrimPrefix(post.AttrOr("id", ""), "p")
+		if postId == "" {
+			return
+		}
+		permalink, err := url.Parse(fmt.Sprintf("./viewtopic.php?p=%s#p%s", postId, postId))
+		if err != nil {
+			log.Printf("Skipping post with id %q\n", postId)
+			return
+		}
+		temp.URL = base.ResolveReference(permalink)
+
+		author := post.Find("p.author")
+		temp.Author = author.Find(".username, .username-coloured").First().Text()
+		if tm, ok := parseDateTime(author.Find("time").First()); ok {
+			temp.Published = tm
+		} else {
+			log.Printf("Missing post time for %s\n", temp.URL)
+		}
+
+		temp.Content = extractContent(post.Find("div.content").First())
+		comments = append(comments, temp)
+	})
+	return
+}
+
+func (ts *ThreadScraper) comments() []model.Comment {
+	comments := make([]model.Comment, len(ts.Comments), len(ts.Comments))
+	for i := range ts.Comments {
+		comments[i] = ts.Comments[i].Comment
+	}
+	return comments
+}
+
+// Scrapes the topic's posts since cutoff. Returns the first error fetching a
+// page; the posts scraped before it are kept.
+func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.Time) error {
+	// Already-stored comments don't need to be loaded again
+	if timeRange := db.CommentTimeRange(ts.threadId); timeRange != nil && timeRange[1].After(cutoff) {
+		cutoff = timeRange[1]
+	}
+
+	// The first page gets us the pagination, then load from the last page
+	// back until earlier than the cutoff
+	ts.errs.visit(ts.CommentScraper, ts.thread.pageURL(0).String())
+	for start := ts.lastStart; ts.perPage > 0 && start > 0 && ts.errs.err == nil; start -= ts.perPage {
+		ts.earliestScraped = time.Time{}
+		ts.errs.visit(ts.CommentScraper, ts.thread.pageURL(start).String())
+		if ts.earliestScraped.Before(cutoff) {
+			break
+		}
+	}
+	return ts.errs.err
+}
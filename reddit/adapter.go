@@ -0,0 +1,138 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+func init() {
+	adapter.Register(redditAdapter{})
+}
+
+type redditAdapter struct{}
+
+func (redditAdapter) Name() string {
+	return "reddit"
+}
+
+func (redditAdapter) URLShapes() []string {
+	return []string{
+		"https://reddit.com/r/<subreddit>",
+		"https://reddit.com/r/<subreddit>/{hot,top,controversial}?t=<hour|day|week|month|year|all>",
+		"https://reddit.com/r/<subreddit>/search?q=<query>",
+		"https://reddit.com/search?q=<query>",
+		"https://reddit.com/r/<subreddit>/comments/<id>/<title>",
+	}
+}
+
+func (redditAdapter) Matches(u *url.URL) adapter.URLKind {
+	if !strings.Contains(u.Host, "reddit.com") {
+		return adapter.NoMatch
+	} else if _, _, ok := parsePostPath(u); ok {
+		return adapter.ThreadURL
+	}
+	return adapter.ForumURL
+}
+
+// The API isn't served from the hosts in post URLs: authenticated clients
+// use oauth.reddit.com, and tokens come from www.reddit.com.
+func (redditAdapter) RequestHosts(hostname string) []string {
+	c := currentConfig()
+	if c.BaseURL != "" {
+		if base, err := url.Parse(c.BaseURL); err == nil {
+			return []string{base.Hostname()}
+		}
+	} else if c.anonymous() {
+		// reddit.com redirects to www.reddit.com
+		return []string{"reddit.com", "www.reddit.com"}
+	}
+	return []string{"oauth.reddit.com", "www.reddit.com"}
+}
+
+// Splits /r/<subreddit>/comments/<id>/... into its subreddit and post ID.
+func parsePostPath(u *url.URL) (subreddit, postId string, ok bool) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) >= 4 && parts[0] == "r" && parts[2] == "comments" {
+		subreddit, postId, ok = parts[1], parts[3], true
+	}
+	return
+}
+
+func (redditAdapter) ScrapeForum(db *database.ScraperDB, u *url.URL, cutoff time.Time, subforums bool) (err error) {
+	var fs *ForumScraper
+	if fs, err = NewForumScraper(u); err == nil {
+		err = fs.LoadThreadsWithActivitySince(db, cutoff)
+	}
+	return
+}
+
+func (redditAdapter) ScrapeThread(db *database.ScraperDB, thread model.Thread, cutoff time.Time) (comments []model.Comment, err error) {
+	subreddit, postId, ok := parsePostPath(thread.URL)
+	if !ok {
+		return nil, fmt.Errorf("Not a Reddit post URL: %s", thread.URL)
+	}
+	forumURL := &url.URL{Scheme: "https", Host: thread.URL.Host, Path: "/r/" + subreddit}
+
+	var fs *ForumScraper
+	var siteId model.SiteID
+	var forumId model.ForumID
+	var postAndComments *reddit.PostAndComments
+	if fs, err = NewForumScraper(forumURL); err == nil {
+		if siteId, forumId, err = db.InsertOrUpdateForum(forumURL); err == nil {
+			if postAndComments, _, err = fs.client.Post.Get(context.Background(), postId); err == nil {
+				ts := NewThreadScraper(siteId, forumId, fs.client, postAndComments)
+				if err = ts.loadComments(cutoff); err == nil {
+					comments = ts.comments()
+				}
+			}
+		}
+	}
+	return
+}
+
+func (redditAdapter) ParsePage(u *url.URL) (page adapter.Page, err error) {
+	var fs *ForumScraper
+	if fs, err = NewForumScraper(u); err != nil {
+		return
+	}
+
+	if _, postId, ok := parsePostPath(u); ok {
+		postAndComments, _, err := fs.client.Post.Get(context.Background(), postId)
+		if err != nil {
+			return page, err
+		}
+		ts := NewThreadScraper(0, 0, fs.client, postAndComments)
+		if err = ts.loadComments(time.Time{}); err != nil {
+			return page, err
+		}
+		page.Comments = ts.comments()
+	} else {
+		cutoff := time.Now().AddDate(0, 0, -7)
+		posts, err := fs.SubredditPostsSince(cutoff)
+		if err != nil {
+			return page, err
+		}
+		for _, p := range posts {
+			if permalink, err := url.Parse("https://reddit.com" + p.Permalink); err == nil {
+				page.Threads = append(page.Threads, model.Thread{
+					URL:       permalink,
+					Author:    p.Author,
+					Title:     p.Title,
+					StartDate: p.Created.Time,
+					Replies:   uint(p.NumberOfComments),
+
+					AuthorExternalId: p.AuthorID,
+				})
+			}
+		}
+	}
+	return
+}
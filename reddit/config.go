@@ -0,0 +1,83 @@
+package reddit
+
+import (
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+	"github.com/zvonler/espy/replay"
+	"github.com/zvonler/espy/scheduler"
+)
+
+// Settings for the Reddit API. Without a client ID and secret, or with
+// Anonymous set, posts are read from the public .json endpoints instead.
+type Config struct {
+	ClientID     string
+	ClientSecret string
+	Username     string
+	Password     string
+
+	// Reddit asks for "<platform>:<app ID>:<version> (by /u/<username>)".
+	UserAgent string
+
+	// Overrides the API host, e.g. to point at a local stub server. Tokens
+	// are requested from the same host when set.
+	BaseURL string
+
+	Anonymous bool
+}
+
+var (
+	configMutex sync.Mutex
+	config      Config
+)
+
+// Sets the configuration used by clients created afterward.
+func Configure(c Config) {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	config = c
+}
+
+func currentConfig() Config {
+	configMutex.Lock()
+	defer configMutex.Unlock()
+
+	return config
+}
+
+func (c Config) anonymous() bool {
+	return c.Anonymous || c.ClientID == "" || c.ClientSecret == ""
+}
+
+func newClient() (*reddit.Client, error) {
+	c := currentConfig()
+
+	opts := []reddit.Opt{
+		reddit.WithHTTPClient(&http.Client{
+			Transport: replay.Wrap(scheduler.Transport(http.DefaultTransport)),
+			Timeout:   30 * time.Second,
+		}),
+	}
+	if c.UserAgent != "" {
+		opts = append(opts, reddit.WithUserAgent(c.UserAgent))
+	}
+	if c.BaseURL != "" {
+		base := strings.TrimSuffix(c.BaseURL, "/")
+		opts = append(opts, reddit.WithBaseURL(base), reddit.WithTokenURL(base+"/api/v1/access_token"))
+	}
+
+	if c.anonymous() {
+		return reddit.NewReadonlyClient(opts...)
+	}
+	credentials := reddit.Credentials{
+		ID:       c.ClientID,
+		Secret:   c.ClientSecret,
+		Username: c.Username,
+		Password: c.Password,
+	}
+	return reddit.NewClient(credentials, opts...)
+}
@@ -0,0 +1,116 @@
+package reddit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/history"
+	"github.com/zvonler/espy/scheduler"
+)
+
+// Serves a subreddit with one post and one comment, recording the headers of
+// the listing request.
+func stubSubreddit(t *testing.T, listingHeaders *http.Header) *httptest.Server {
+	post := postJSON("p1", time.Now(), 1)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/api/v1/access_token":
+			w.Header().Set("Content-Type", "application/json")
+			fmt.Fprint(w, `{"access_token": "token", "token_type": "bearer", "expires_in": 3600}`)
+		case "/r/ev/new":
+			*listingHeaders = r.Header.Clone()
+			fmt.Fprint(w, listingJSON(post))
+		case "/comments/p1":
+			fmt.Fprintf(w, `[%s, %s]`, listingJSON(post), listingJSON(commentJSON("c1", "t3_p1", "")))
+		default:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	scheduler.SetDefaultLimits(scheduler.Limits{})
+	t.Cleanup(func() { scheduler.SetDefaultLimits(scheduler.DefaultLimits) })
+	t.Cleanup(func() { Configure(Config{}) })
+	return srv
+}
+
+func scrapeStub(t *testing.T, forum string) (db *database.ScraperDB, err error) {
+	db, err = database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	t.Cleanup(func() { db.Close() })
+
+	forumURL, _ := url.Parse(forum)
+	err = redditAdapter{}.ScrapeForum(db, forumURL, time.Now().AddDate(0, 0, -1), false)
+	return
+}
+
+func TestAnonymousScrape(t *testing.T) {
+	var headers http.Header
+	srv := stubSubreddit(t, &headers)
+	Configure(Config{BaseURL: srv.URL, UserAgent: "espy-test"})
+
+	db, err := scrapeStub(t, "https://reddit.com/r/ev")
+	require.Nil(t, err)
+	require.Equal(t, "espy-test", headers.Get("User-Agent"))
+	require.Empty(t, headers.Get("Authorization"))
+
+	threadURL, _ := url.Parse("https://reddit.com/r/ev/comments/p1/t/")
+	thread, err := db.GetThreadByURL(threadURL)
+	require.Nil(t, err)
+	comments, err := db.ThreadComments(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, 1, len(comments))
+	require.Equal(t, "c1", comments[0].Content)
+}
+
+func TestAuthenticatedScrape(t *testing.T) {
+	var headers http.Header
+	srv := stubSubreddit(t, &headers)
+	Configure(Config{BaseURL: srv.URL, ClientID: "id", ClientSecret: "secret", Username: "u", Password: "p"})
+
+	_, err := scrapeStub(t, "https://reddit.com/r/ev")
+	require.Nil(t, err)
+	require.Equal(t, "Bearer token", headers.Get("Authorization"))
+}
+
+func TestRecordCountsAPIRequests(t *testing.T) {
+	var headers http.Header
+	srv := stubSubreddit(t, &headers)
+	Configure(Config{BaseURL: srv.URL, ClientID: "id", ClientSecret: "secret", Username: "u", Password: "p"})
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	// The requests go to the API host rather than reddit.com
+	forumURL, _ := url.Parse("https://reddit.com/r/ev")
+	cutoff := time.Now().AddDate(0, 0, -1)
+	run, err := history.Record(db, forumURL.String(), forumURL.Hostname(), cutoff, func() error {
+		return redditAdapter{}.ScrapeForum(db, forumURL, cutoff, false)
+	})
+	require.Nil(t, err)
+	require.Equal(t, uint(3), run.Requests)
+	require.Equal(t, map[int]uint{http.StatusOK: 3}, run.Statuses)
+	require.Greater(t, run.Bytes, uint64(0))
+
+	Configure(Config{ClientID: "id", ClientSecret: "secret"})
+	require.Equal(t, []string{"reddit.com", "oauth.reddit.com", "www.reddit.com"}, adapter.RequestHosts("reddit.com"))
+	Configure(Config{})
+	require.Equal(t, []string{"reddit.com", "www.reddit.com"}, adapter.RequestHosts("reddit.com"))
+}
+
+func TestScrapeErrorsAreReturned(t *testing.T) {
+	var headers http.Header
+	srv := stubSubreddit(t, &headers)
+	Configure(Config{BaseURL: srv.URL})
+
+	_, err := scrapeStub(t, "https://reddit.com/r/missing")
+	require.NotNil(t, err)
+}
@@ -0,0 +1,90 @@
+package reddit
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+// Reddit returns at most this many posts per listing page.
+const listingPageLimit = 100
+
+// The posts a forum URL lists. Subreddit URLs list the newest posts, and
+// /hot, /top, /controversial and /search list others, as on the site:
+//
+//	https://reddit.com/r/<subreddit>
+//	https://reddit.com/r/<subreddit>/top?t=week
+//	https://reddit.com/r/<subreddit>/search?q=<query>&sort=new
+//	https://reddit.com/search?q=<query>
+type listing struct {
+	subreddit string
+	sort      string
+	time      string
+	query     string
+
+	// Search results are sorted by this, and other listings by sort
+	searchSort string
+}
+
+func parseListing(u *url.URL) (l listing, err error) {
+	parts := strings.Split(strings.Trim(u.Path, "/"), "/")
+	if len(parts) >= 2 && parts[0] == "r" {
+		l.subreddit = parts[1]
+		parts = parts[2:]
+	}
+	l.sort = "new"
+	if len(parts) > 0 && parts[0] != "" {
+		l.sort = parts[0]
+	}
+	l.time = u.Query().Get("t")
+
+	switch l.sort {
+	case "new", "hot", "top", "controversial":
+		if l.subreddit == "" {
+			err = fmt.Errorf("Not a subreddit URL: %s", u)
+		}
+	case "search":
+		if l.query = u.Query().Get("q"); l.query == "" {
+			err = fmt.Errorf("Search URL has no query: %s", u)
+		}
+		l.searchSort = u.Query().Get("sort")
+	default:
+		err = fmt.Errorf("Unsupported Reddit listing %q in %s", l.sort, u)
+	}
+	return
+}
+
+// True when the listing is newest first, so paging can stop at the cutoff.
+func (l listing) chronological() bool {
+	return l.sort == "new" || (l.sort == "search" && l.searchSort == "new")
+}
+
+// Fetches the page of the listing after the given anchor, returning the
+// anchor for the next page or "" at the end.
+func (l listing) page(client *reddit.Client, after string) (posts []*reddit.Post, next string, err error) {
+	ctx := context.Background()
+	list := reddit.ListOptions{Limit: listingPageLimit, After: after}
+	postOpts := &reddit.ListPostOptions{ListOptions: list, Time: l.time}
+
+	var resp *reddit.Response
+	switch l.sort {
+	case "new":
+		posts, resp, err = client.Subreddit.NewPosts(ctx, l.subreddit, &list)
+	case "hot":
+		posts, resp, err = client.Subreddit.HotPosts(ctx, l.subreddit, &list)
+	case "top":
+		posts, resp, err = client.Subreddit.TopPosts(ctx, l.subreddit, postOpts)
+	case "controversial":
+		posts, resp, err = client.Subreddit.ControversialPosts(ctx, l.subreddit, postOpts)
+	case "search":
+		posts, resp, err = client.Subreddit.SearchPosts(ctx, l.query, l.subreddit,
+			&reddit.ListPostSearchOptions{ListPostOptions: *postOpts, Sort: l.searchSort})
+	}
+	if err == nil && resp != nil {
+		next = resp.After
+	}
+	return
+}
@@ -0,0 +1,120 @@
+package reddit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+)
+
+func TestParseListing(t *testing.T) {
+	parse := func(s string) (listing, error) {
+		u, err := url.Parse(s)
+		require.Nil(t, err)
+		return parseListing(u)
+	}
+
+	l, err := parse("https://reddit.com/r/ev")
+	require.Nil(t, err)
+	require.Equal(t, listing{subreddit: "ev", sort: "new"}, l)
+	require.True(t, l.chronological())
+
+	l, err = parse("https://www.reddit.com/r/ev/top/?t=week")
+	require.Nil(t, err)
+	require.Equal(t, listing{subreddit: "ev", sort: "top", time: "week"}, l)
+	require.False(t, l.chronological())
+
+	l, err = parse("https://reddit.com/search?q=battery+warranty&sort=new")
+	require.Nil(t, err)
+	require.Equal(t, listing{sort: "search", query: "battery warranty", searchSort: "new"}, l)
+	require.True(t, l.chronological())
+
+	_, err = parse("https://reddit.com/r/ev/wiki")
+	require.NotNil(t, err)
+	_, err = parse("https://reddit.com/r/ev/search")
+	require.NotNil(t, err)
+}
+
+func postJSON(id string, created time.Time, comments int) string {
+	return fmt.Sprintf(`{"kind": "t3", "data": {"id": %q, "name": "t3_%s", "created_utc": %d,
+		"permalink": "/r/ev/comments/%s/t/", "num_comments": %d}}`, id, id, created.Unix(), id, comments)
+}
+
+func TestSubredditPostsSince(t *testing.T) {
+	now := time.Now()
+	var requested []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		after := r.URL.Query().Get("after")
+		requested = append(requested, r.URL.Path+"?after="+after)
+		var after2 string
+		var posts []string
+		switch after {
+		case "":
+			after2, posts = "t3_p2", []string{postJSON("p1", now, 0), postJSON("p2", now.AddDate(0, 0, -1), 0)}
+		case "t3_p2":
+			after2, posts = "t3_p4", []string{postJSON("p3", now.AddDate(0, 0, -2), 0), postJSON("p4", now.AddDate(0, 0, -10), 0)}
+		default:
+			after2, posts = "", []string{postJSON("p5", now.AddDate(0, 0, -1), 0)}
+		}
+		fmt.Fprintf(w, `{"kind": "Listing", "data": {"after": %q, "children": [%s]}}`, after2, strings.Join(posts, ","))
+	}))
+	defer srv.Close()
+
+	client, err := reddit.NewReadonlyClient(reddit.WithBaseURL(srv.URL))
+	require.Nil(t, err)
+	cutoff := now.AddDate(0, 0, -5)
+
+	// Newest first listings stop at the first page reaching the cutoff
+	forumURL, _ := url.Parse("https://reddit.com/r/ev")
+	fs := &ForumScraper{forumURL: forumURL, client: client}
+	posts, err := fs.SubredditPostsSince(cutoff)
+	require.Nil(t, err)
+	require.Equal(t, 3, len(posts))
+	require.Equal(t, []string{"/r/ev/new?after=", "/r/ev/new?after=t3_p2"}, requested)
+
+	// Others are followed to the end, leaving out posts older than the cutoff
+	requested = nil
+	fs.forumURL, _ = url.Parse("https://reddit.com/r/ev/top?t=month")
+	posts, err = fs.SubredditPostsSince(cutoff)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(posts))
+	require.Equal(t, "p5", posts[3].ID)
+	require.Equal(t, 3, len(requested))
+}
+
+func TestUnchangedSinceScraped(t *testing.T) {
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL, _ := url.Parse("https://reddit.com/r/ev")
+	threadURL, _ := url.Parse("https://reddit.com/r/ev/comments/p1/t/")
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+	post := &reddit.Post{ID: "p1", Permalink: "/r/ev/comments/p1/t/", NumberOfComments: 4}
+
+	require.False(t, unchangedSinceScraped(db, post))
+
+	// A thread stored before its comments were isn't complete
+	thread := model.Thread{URL: threadURL, Author: "alice", StartDate: time.Unix(1696161600, 0)}
+	_, err = db.InsertOrUpdateThread(siteId, forumId, thread)
+	require.Nil(t, err)
+	require.False(t, unchangedSinceScraped(db, post))
+
+	thread.Replies = 4
+	thread.Latest = time.Unix(1696165200, 0)
+	_, err = db.InsertOrUpdateThread(siteId, forumId, thread)
+	require.Nil(t, err)
+	require.True(t, unchangedSinceScraped(db, post))
+
+	post.NumberOfComments = 5
+	require.False(t, unchangedSinceScraped(db, post))
+}
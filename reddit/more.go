@@ -0,0 +1,210 @@
+package reddit
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+)
+
+// Reddit expands at most this many comments per morechildren request.
+const moreChildrenLimit = 100
+
+// A "load more comments" stub in a post's comment tree, with the comment or
+// post it hangs from.
+type moreStub struct {
+	more   *reddit.More
+	parent *reddit.Comment // nil for the post
+}
+
+// Walks the post's comment tree, indexing the comments by full id and
+// collecting the stubs not yet expanded.
+func (ts *ThreadScraper) indexTree(expanded map[string]bool) (byId map[string]*reddit.Comment, stubs []moreStub) {
+	byId = make(map[string]*reddit.Comment)
+	var walk func(c *reddit.Comment)
+	walk = func(c *reddit.Comment) {
+		byId[c.FullID] = c
+		for _, r := range c.Replies.Comments {
+			walk(r)
+		}
+		if m := c.Replies.More; m != nil && !expanded[m.FullID+m.ParentID] {
+			stubs = append(stubs, moreStub{m, c})
+		}
+	}
+	for _, c := range ts.post.Comments {
+		walk(c)
+	}
+	if m := ts.post.More; m != nil && !expanded[m.FullID+m.ParentID] {
+		stubs = append(stubs, moreStub{m, nil})
+	}
+	return
+}
+
+// Expands the post's "load more comments" stubs until the comment tree is
+// complete. With a non-zero cutoff, expansion stops once a round of requests
+// finds no comments published after it, leaving ts.complete false if stubs
+// remain.
+func (ts *ThreadScraper) expandMoreComments(cutoff time.Time) (err error) {
+	expanded := make(map[string]bool)
+	for {
+		byId, stubs := ts.indexTree(expanded)
+		if len(stubs) == 0 {
+			ts.complete = true
+			return
+		}
+
+		var fetched []*reddit.Comment
+		var mores []*reddit.More
+		for _, s := range stubs {
+			expanded[s.more.FullID+s.more.ParentID] = true
+			var comments []*reddit.Comment
+			var more []*reddit.More
+			if len(s.more.Children) == 0 {
+				// A "continue this thread" link, which has no children listed
+				if s.parent != nil {
+					comments, more, err = ts.continueThread(s.parent)
+				}
+			} else {
+				comments, more, err = ts.moreChildren(s.more.Children)
+			}
+			if err != nil {
+				return
+			}
+			if s.parent == nil {
+				ts.post.More = nil
+			} else {
+				s.parent.Replies.More = nil
+			}
+			fetched = append(fetched, comments...)
+			mores = append(mores, more...)
+		}
+		ts.graft(byId, fetched, mores)
+
+		if !cutoff.IsZero() && !anyCommentSince(fetched, cutoff) {
+			_, stubs = ts.indexTree(expanded)
+			ts.complete = len(stubs) == 0
+			return
+		}
+	}
+}
+
+// Adds fetched comments and stubs to the tree under their parents. Comments
+// come before their replies.
+func (ts *ThreadScraper) graft(byId map[string]*reddit.Comment, comments []*reddit.Comment, mores []*reddit.More) {
+	for _, c := range comments {
+		if _, ok := byId[c.FullID]; ok {
+			continue
+		}
+		if c.ParentID == ts.post.Post.FullID {
+			ts.post.Comments = append(ts.post.Comments, c)
+		} else if parent, ok := byId[c.ParentID]; ok {
+			parent.Replies.Comments = append(parent.Replies.Comments, c)
+		} else {
+			continue
+		}
+		byId[c.FullID] = c
+		for _, r := range c.Replies.Comments {
+			byId[r.FullID] = r
+		}
+	}
+	for _, m := range mores {
+		if m.ParentID == ts.post.Post.FullID {
+			ts.post.More = m
+		} else if parent, ok := byId[m.ParentID]; ok {
+			parent.Replies.More = m
+		}
+	}
+}
+
+func anyCommentSince(comments []*reddit.Comment, cutoff time.Time) bool {
+	for _, c := range comments {
+		if c.Created != nil && !c.Created.Time.Before(cutoff) {
+			return true
+		}
+		if anyCommentSince(c.Replies.Comments, cutoff) {
+			return true
+		}
+	}
+	return false
+}
+
+// Fetches the comments with the given ids, a batch at a time. The results
+// are flat, with each comment's replies listed after it.
+func (ts *ThreadScraper) moreChildren(ids []string) (comments []*reddit.Comment, mores []*reddit.More, err error) {
+	for len(ids) > 0 {
+		batch := ids
+		if len(batch) > moreChildrenLimit {
+			batch = batch[:moreChildrenLimit]
+		}
+		ids = ids[len(batch):]
+
+		query := url.Values{}
+		query.Set("api_type", "json")
+		query.Set("link_id", ts.post.Post.FullID)
+		query.Set("children", strings.Join(batch, ","))
+		var req *http.Request
+		if req, err = ts.client.NewRequest(http.MethodGet, "api/morechildren?"+query.Encode(), nil); err != nil {
+			return
+		}
+
+		var root struct {
+			JSON struct {
+				Errors [][]string `json:"errors"`
+				Data   struct {
+					Things []struct {
+						Kind string          `json:"kind"`
+						Data json.RawMessage `json:"data"`
+					} `json:"things"`
+				} `json:"data"`
+			} `json:"json"`
+		}
+		if _, err = ts.client.Do(context.Background(), req, &root); err != nil {
+			return
+		}
+		if len(root.JSON.Errors) > 0 {
+			return nil, nil, fmt.Errorf("morechildren for %s: %v", ts.post.Post.FullID, root.JSON.Errors)
+		}
+		for _, t := range root.JSON.Data.Things {
+			switch t.Kind {
+			case "t1":
+				c := new(reddit.Comment)
+				if err = json.Unmarshal(t.Data, c); err != nil {
+					return
+				}
+				comments = append(comments, c)
+			case "more":
+				m := new(reddit.More)
+				if err = json.Unmarshal(t.Data, m); err != nil {
+					return
+				}
+				mores = append(mores, m)
+			}
+		}
+	}
+	return
+}
+
+// Fetches the replies to a comment too deep in the tree for Reddit to have
+// included them, as its "continue this thread" link does.
+func (ts *ThreadScraper) continueThread(parent *reddit.Comment) (comments []*reddit.Comment, mores []*reddit.More, err error) {
+	var req *http.Request
+	path := fmt.Sprintf("comments/%s/_/%s", ts.post.Post.ID, parent.ID)
+	if req, err = ts.client.NewRequest(http.MethodGet, path, nil); err != nil {
+		return
+	}
+	pc := new(reddit.PostAndComments)
+	if _, err = ts.client.Do(context.Background(), req, pc); err == nil && len(pc.Comments) > 0 {
+		// The response is rooted at the parent comment
+		replies := pc.Comments[0].Replies
+		comments = replies.Comments
+		if replies.More != nil {
+			mores = append(mores, replies.More)
+		}
+	}
+	return
+}
@@ -0,0 +1,108 @@
+package reddit
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/vartanbeno/go-reddit/v2/reddit"
+	"github.com/zvonler/espy/database"
+)
+
+func commentJSON(id, parent, replies string) string {
+	if replies == "" {
+		replies = `""`
+	}
+	return fmt.Sprintf(`{"kind": "t1", "data": {"id": %q, "name": "t1_%s", "parent_id": %q,
+		"permalink": "/r/ev/comments/p1/t/%s/", "author": "a%s", "body": %q,
+		"created_utc": 1696161600, "replies": %s}}`, id, id, parent, id, id, id, replies)
+}
+
+func listingJSON(children ...string) string {
+	return `{"kind": "Listing", "data": {"children": [` + strings.Join(children, ",") + `]}}`
+}
+
+// Serves the comments collapsed in the tree returned by stubPost.
+func stubMoreChildren(t *testing.T) *reddit.Client {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.URL.Path == "/api/morechildren" && r.URL.Query().Get("children") == "c2":
+			fmt.Fprintf(w, `{"json": {"errors": [], "data": {"things": [%s]}}}`, commentJSON("c2", "t3_p1", ""))
+		case r.URL.Path == "/api/morechildren" && r.URL.Query().Get("children") == "c3,c4":
+			fmt.Fprintf(w, `{"json": {"errors": [], "data": {"things": [%s, %s,
+				{"kind": "more", "data": {"id": "_", "name": "t1__", "parent_id": "t1_c4", "children": []}}]}}}`,
+				commentJSON("c3", "t1_c1", ""), commentJSON("c4", "t1_c1", ""))
+		case r.URL.Path == "/comments/p1/_/c4":
+			fmt.Fprintf(w, `[%s, %s]`,
+				listingJSON(`{"kind": "t3", "data": {"id": "p1", "name": "t3_p1"}}`),
+				listingJSON(commentJSON("c4", "t1_c1", listingJSON(commentJSON("c5", "t1_c4", "")))))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(srv.Close)
+
+	client, err := reddit.NewReadonlyClient(reddit.WithBaseURL(srv.URL))
+	require.Nil(t, err)
+	return client
+}
+
+// The post comes back with one comment, whose replies and the post's
+// remaining comments are collapsed.
+func stubPost() *reddit.PostAndComments {
+	created := &reddit.Timestamp{Time: time.Unix(1696161600, 0)}
+	return &reddit.PostAndComments{
+		Post: &reddit.Post{ID: "p1", FullID: "t3_p1", Permalink: "/r/ev/comments/p1/t/", Created: created,
+			NumberOfComments: 5},
+		Comments: []*reddit.Comment{{ID: "c1", FullID: "t1_c1", ParentID: "t3_p1",
+			Body: "c1", Permalink: "/r/ev/comments/p1/t/c1/", Created: created,
+			Replies: reddit.Replies{More: &reddit.More{ID: "c3", FullID: "t1_c3", ParentID: "t1_c1", Children: []string{"c3", "c4"}}}}},
+		More: &reddit.More{ID: "c2", FullID: "t1_c2", ParentID: "t3_p1", Children: []string{"c2"}},
+	}
+}
+
+func TestExpandMoreComments(t *testing.T) {
+	client := stubMoreChildren(t)
+	ts := NewThreadScraper(0, 0, client, stubPost())
+	require.Nil(t, ts.loadComments(time.Time{}))
+	require.True(t, ts.complete)
+
+	var ids, parents []string
+	for _, c := range ts.comments() {
+		ids = append(ids, c.Content)
+		if c.ParentURL != nil {
+			parents = append(parents, c.ParentURL.Path)
+		} else {
+			parents = append(parents, "")
+		}
+	}
+	require.Equal(t, []string{"c1", "c3", "c4", "c5", "c2"}, ids)
+	require.Equal(t, []string{"", "/r/ev/comments/p1/t/c1/", "/r/ev/comments/p1/t/c1/", "/r/ev/comments/p1/t/c4/", ""}, parents)
+}
+
+func TestPartlyExpandedThreadIsScrapedAgain(t *testing.T) {
+	client := stubMoreChildren(t)
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+	forumURL, _ := url.Parse("https://reddit.com/r/ev")
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+
+	// Expansion stops at a cutoff after every comment, leaving the
+	// "continue this thread" stub under c4
+	ts := NewThreadScraper(siteId, forumId, client, stubPost())
+	require.Nil(t, ts.LoadCommentsSince(db, time.Unix(1700000000, 0)))
+	require.False(t, ts.complete)
+	require.False(t, unchangedSinceScraped(db, stubPost().Post))
+
+	ts = NewThreadScraper(siteId, forumId, client, stubPost())
+	require.Nil(t, ts.LoadCommentsSince(db, time.Unix(0, 0)))
+	require.True(t, ts.complete)
+	require.True(t, unchangedSinceScraped(db, stubPost().Post))
+}
@@ -2,12 +2,8 @@ package reddit
 
 import (
 	"context"
-	"encoding/json"
 	"fmt"
-	"io/ioutil"
-	"log"
 	"net/url"
-	"strings"
 	"time"
 
 	"github.com/vartanbeno/go-reddit/v2/reddit"
@@ -35,64 +31,84 @@ type ForumScraper struct {
 	client   *reddit.Client
 }
 
-func NewForumScraper(url *url.URL) *ForumScraper {
-	fs := new(ForumScraper)
-	fs.forumURL = url
-
-	content, err := ioutil.ReadFile("reddit.agent")
-	if err != nil {
-		log.Fatal(err)
-	}
-	var credentials reddit.Credentials
-	err = json.Unmarshal(content, &credentials)
-	if err != nil {
-		log.Fatal("Error unmarshaling JSON")
+func NewForumScraper(url *url.URL) (fs *ForumScraper, err error) {
+	var client *reddit.Client
+	if client, err = newClient(); err != nil {
+		return nil, fmt.Errorf("Failed to create Reddit client: %w", err)
 	}
+	fs = &ForumScraper{forumURL: url, client: client}
+	return
+}
 
-	client, err := reddit.NewClient(credentials)
-	if err != nil {
-		fmt.Printf("Failed to create client: %v\n", err)
-		return nil
+// Returns the forum URL's posts created since cutoff, following the
+// listing's pages until they reach posts older than cutoff or run out.
+func (fs *ForumScraper) SubredditPostsSince(cutoff time.Time) (posts []*reddit.Post, err error) {
+	var l listing
+	if l, err = parseListing(fs.forumURL); err != nil {
+		return
 	}
-	fs.client = client
 
-	return fs
+	var page []*reddit.Post
+	after := ""
+	for {
+		if page, after, err = l.page(fs.client, after); err != nil {
+			return
+		}
+		reachedCutoff := false
+		for _, p := range page {
+			if p.Created != nil && p.Created.Time.Before(cutoff) {
+				reachedCutoff = true
+				continue
+			}
+			posts = append(posts, p)
+		}
+		if after == "" || len(page) == 0 || (reachedCutoff && l.chronological()) {
+			return
+		}
+	}
 }
 
-func (fs *ForumScraper) SubredditPostsSince(cutoff time.Time) (posts []*reddit.Post, err error) {
-	subreddit := fs.forumURL.Path
-	if !strings.HasPrefix(subreddit, "/r/") {
-		panic(subreddit)
+// True when the post's thread was completely scraped before and has the
+// same number of comments now, so its comments needn't be fetched again.
+// Only complete scrapes store the count.
+func unchangedSinceScraped(db *database.ScraperDB, post *reddit.Post) bool {
+	if permalink, err := url.Parse("https://reddit.com" + post.Permalink); err == nil {
+		if stored, err := db.GetThreadByURL(permalink); err == nil {
+			return !stored.Latest.IsZero() && stored.Replies == uint(post.NumberOfComments)
+		}
 	}
-	subreddit = strings.TrimPrefix(subreddit, "/r/")
-
-	posts, _, err = fs.client.Subreddit.NewPosts(context.Background(), subreddit, &reddit.ListOptions{
-		Limit: 300,
-	})
-	return
+	return false
 }
 
-func (fs *ForumScraper) LoadThreadsWithActivitySince(db *database.ScraperDB, cutoff time.Time) {
-	posts, err := fs.SubredditPostsSince(cutoff)
-	if err != nil {
-		log.Fatal(err)
+func (fs *ForumScraper) LoadThreadsWithActivitySince(db *database.ScraperDB, cutoff time.Time) (err error) {
+	var posts []*reddit.Post
+	if posts, err = fs.SubredditPostsSince(cutoff); err != nil {
+		return
 	}
 
-	siteId, forumId, err := db.InsertOrUpdateForum(fs.forumURL)
-	if err != nil {
-		panic(err)
+	var siteId model.SiteID
+	var forumId model.ForumID
+	if siteId, forumId, err = db.InsertOrUpdateForum(fs.forumURL); err != nil {
+		return
 	}
 
 	for _, post := range posts {
-		postAndComments, _, err := fs.client.Post.Get(context.Background(), post.ID)
-		if err != nil {
-			log.Fatal(err)
+		if unchangedSinceScraped(db, post) {
+			fmt.Printf("Skipping unchanged post %s\n", post.Permalink)
+			continue
+		}
+		var postAndComments *reddit.PostAndComments
+		if postAndComments, _, err = fs.client.Post.Get(context.Background(), post.ID); err != nil {
+			return
+		}
+		threadScraper := NewThreadScraper(siteId, forumId, fs.client, postAndComments)
+		if err = threadScraper.LoadCommentsSince(db, cutoff); err != nil {
+			return
 		}
-		threadScraper := NewThreadScraper(siteId, forumId, postAndComments)
-		threadScraper.LoadCommentsSince(db, cutoff)
 	}
 
 	db.SetForumLastScraped(forumId, time.Now())
+	return
 }
 
 /*---------------------------------------------------------------------------*/
@@ -100,22 +116,28 @@ func (fs *ForumScraper) LoadThreadsWithActivitySince(db *database.ScraperDB, cut
 type ThreadScraper struct {
 	siteId   model.SiteID
 	forumId  model.ForumID
+	client   *reddit.Client
 	post     *reddit.PostAndComments
 	Comments []RedditComment
+
+	// Set when every comment in the tree was fetched, rather than expansion
+	// stopping at the cutoff.
+	complete bool
 }
 
-func NewThreadScraper(siteId model.SiteID, forumId model.ForumID, post *reddit.PostAndComments) *ThreadScraper {
+func NewThreadScraper(siteId model.SiteID, forumId model.ForumID, client *reddit.Client, post *reddit.PostAndComments) *ThreadScraper {
 	ts := new(ThreadScraper)
 	ts.siteId = siteId
 	ts.forumId = forumId
+	ts.client = client
 	ts.post = post
 	return ts
 }
 
-func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.Time) {
-	permalink, err := url.Parse("https://reddit.com" + ts.post.Post.Permalink)
-	if err != nil {
-		log.Fatal(err)
+func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.Time) (err error) {
+	var permalink *url.URL
+	if permalink, err = url.Parse("https://reddit.com" + ts.post.Post.Permalink); err != nil {
+		return
 	}
 	thread := RedditThread{
 		Thread: model.Thread{
@@ -123,43 +145,94 @@ func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.T
 			Author:    ts.post.Post.Author,
 			Title:     ts.post.Post.Title,
 			StartDate: ts.post.Post.Created.Time,
-			Replies:   uint(ts.post.Post.NumberOfComments),
+
+			AuthorExternalId: ts.post.Post.AuthorID,
 		},
 	}
-	threadId, err := db.InsertOrUpdateThread(ts.siteId, ts.forumId, thread.Thread)
-	if err != nil {
-		panic(err)
+	// The comment count and latest activity are stored once the comments
+	// are, so a thread whose scrape fails isn't skipped as unchanged later
+	var threadId model.ThreadID
+	if threadId, err = db.InsertOrUpdateThread(ts.siteId, ts.forumId, thread.Thread); err != nil {
+		return
 	}
 	fmt.Printf("ThreadScraper %d loading comments from %s\n", threadId, permalink)
 
-	var toRc func(c *reddit.Comment)
+	if err = ts.loadComments(cutoff); err != nil {
+		return
+	}
+	comments := ts.comments()
+	if err = db.AddComments(ts.siteId, threadId, comments); err != nil {
+		return
+	}
+
+	// A tree whose expansion stopped at the cutoff lacks older comments, so
+	// its count is left unset and a scrape with an earlier cutoff isn't
+	// skipped as unchanged
+	if ts.complete {
+		thread.Replies = uint(ts.post.Post.NumberOfComments)
+	}
+	thread.Latest = thread.StartDate
+	for _, c := range comments {
+		if c.Published.After(thread.Latest) {
+			thread.Latest = c.Published
+		}
+	}
+	_, err = db.InsertOrUpdateThread(ts.siteId, ts.forumId, thread.Thread)
+	return
+}
 
-	toRc = func(c *reddit.Comment) {
+// Expands the post's comment tree and flattens it into ts.Comments, parents
+// before their replies.
+func (ts *ThreadScraper) loadComments(cutoff time.Time) (err error) {
+	if err = ts.expandMoreComments(cutoff); err != nil {
+		return
+	}
+
+	var toRc func(c *reddit.Comment, parentURL *url.URL) error
+
+	toRc = func(c *reddit.Comment, parentURL *url.URL) error {
 		permalink, err := url.Parse("https://reddit.com" + c.Permalink)
 		if err != nil {
-			log.Fatal(err)
+			return err
 		}
-		ts.Comments = append(ts.Comments, RedditComment{
+		rc := RedditComment{
 			Comment: model.Comment{
 				URL:       permalink,
 				Author:    c.Author,
 				Published: c.Created.Time,
 				Content:   c.Body,
+
+				AuthorExternalId: c.AuthorID,
+				ParentURL:        parentURL,
 			},
-		})
+		}
+		// Scores of new comments are hidden for a while
+		if !c.ScoreHidden {
+			score := c.Score
+			rc.Score = &score
+		}
+		ts.Comments = append(ts.Comments, rc)
 
 		for _, r := range c.Replies.Comments {
-			toRc(r)
+			if err = toRc(r, permalink); err != nil {
+				return err
+			}
 		}
+		return nil
 	}
 
 	for _, comment := range ts.post.Comments {
-		toRc(comment)
+		if err = toRc(comment, nil); err != nil {
+			return
+		}
 	}
+	return
+}
 
+func (ts *ThreadScraper) comments() []model.Comment {
 	comments := make([]model.Comment, len(ts.Comments), len(ts.Comments))
 	for i := range ts.Comments {
 		comments[i] = ts.Comments[i].Comment
 	}
-	db.AddComments(ts.siteId, threadId, comments)
+	return comments
 }
@@ -0,0 +1,230 @@
+// Package refresh keeps the archive current by scraping forums on a schedule.
+package refresh
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/alerts"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/history"
+	"github.com/zvonler/espy/model"
+)
+
+// A Target is a forum, or every known forum at a site, scraped at an
+// interval.
+type Target struct {
+	// Exactly one of Site, a hostname, and Forum, a forum URL, is set.
+	Site  string
+	Forum string
+
+	Interval time.Duration
+
+	// Activity earlier than this long before each scrape is ignored.
+	Lookback time.Duration
+
+	SubForums bool
+}
+
+func (t Target) String() string {
+	if t.Forum != "" {
+		return t.Forum
+	}
+	return t.Site
+}
+
+func (t Target) Validate() (err error) {
+	switch {
+	case (t.Site == "") == (t.Forum == ""):
+		err = errors.New("exactly one of site and forum must be set")
+	case t.Interval <= 0:
+		err = errors.New("the interval must be positive")
+	case t.Lookback <= 0:
+		err = errors.New("the lookback must be positive")
+	case t.Forum != "":
+		var u *url.URL
+		if u, err = url.Parse(t.Forum); err == nil && u.Hostname() == "" {
+			err = errors.New("the forum URL has no host")
+		}
+	}
+	if err != nil {
+		err = fmt.Errorf("Bad schedule entry %q: %w", t, err)
+	}
+	return
+}
+
+func (t Target) hostname() string {
+	if t.Forum != "" {
+		u, _ := url.Parse(t.Forum)
+		return u.Hostname()
+	}
+	return t.Site
+}
+
+type Daemon struct {
+	db      *database.ScraperDB
+	targets []Target
+
+	// Failed scrapes are retried after twice as long as the last delay,
+	// up to MaxBackoff.
+	MaxBackoff time.Duration
+
+	// Scrapes target, ignoring activity before cutoff. Replaced in tests.
+	scrape func(db *database.ScraperDB, target Target, cutoff time.Time) error
+
+	hostsMutex sync.Mutex
+	hosts      map[string]*sync.Mutex
+}
+
+func New(db *database.ScraperDB, targets []Target) (d *Daemon, err error) {
+	if len(targets) == 0 {
+		return nil, errors.New("Nothing is scheduled")
+	}
+	for _, t := range targets {
+		if err = t.Validate(); err != nil {
+			return
+		}
+	}
+	d = &Daemon{
+		db:         db,
+		targets:    targets,
+		MaxBackoff: 24 * time.Hour,
+		scrape:     scrapeTarget,
+		hosts:      make(map[string]*sync.Mutex),
+	}
+	return
+}
+
+// Scrapes each target on its schedule until ctx is done, then waits for the
+// scrapes in progress to finish. A target's first scrape is due one interval
+// after its last recorded run, so restarting the daemon doesn't scrape
+// everything again.
+func (d *Daemon) Run(ctx context.Context) {
+	var wg sync.WaitGroup
+	for _, t := range d.targets {
+		wg.Add(1)
+		go func(t Target) {
+			defer wg.Done()
+			d.runTarget(ctx, t)
+		}(t)
+	}
+	wg.Wait()
+}
+
+func (d *Daemon) runTarget(ctx context.Context, t Target) {
+	next := time.Now()
+	if last, err := d.db.LastScrapeRun(t.String()); err == nil {
+		next = last.Started.Add(t.Interval)
+	}
+
+	var failures uint
+	for waitUntil(ctx, next) {
+		run := d.runOnce(t)
+		if run.Error != "" {
+			failures++
+			log.Printf("Scrape of %s failed: %s\n", t, run.Error)
+		} else {
+			failures = 0
+			log.Printf("Scraped %s: %d requests, %d new threads, %d new comments\n",
+				t, run.Requests, run.NewThreads, run.NewComments)
+		}
+		next = run.Started.Add(d.delay(t, failures))
+		if failures > 0 {
+			log.Printf("Retrying %s at %s\n", t, next.Format(time.RFC3339))
+		}
+	}
+}
+
+// Returns the delay before the next scrape of t after the given number of
+// consecutive failures.
+func (d *Daemon) delay(t Target, failures uint) time.Duration {
+	delay := t.Interval
+	for i := uint(0); i < failures && delay < d.MaxBackoff; i++ {
+		delay *= 2
+	}
+	if failures > 0 && delay > d.MaxBackoff {
+		delay = max(d.MaxBackoff, t.Interval)
+	}
+	return delay
+}
+
+// Returns whether ctx is still live at tm.
+func waitUntil(ctx context.Context, tm time.Time) bool {
+	timer := time.NewTimer(time.Until(tm))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return ctx.Err() == nil
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Scrapes of one host run one at a time, as history.Record requires.
+func (d *Daemon) hostMutex(hostname string) *sync.Mutex {
+	d.hostsMutex.Lock()
+	defer d.hostsMutex.Unlock()
+
+	m, found := d.hosts[hostname]
+	if !found {
+		m = &sync.Mutex{}
+		d.hosts[hostname] = m
+	}
+	return m
+}
+
+func (d *Daemon) runOnce(t Target) (run database.ScrapeRun) {
+	hostname := t.hostname()
+	m := d.hostMutex(hostname)
+	m.Lock()
+	defer m.Unlock()
+
+	cutoff := time.Now().Add(-t.Lookback)
+	run, _ = history.Record(d.db, t.String(), hostname, cutoff, func() error {
+		return d.scrape(d.db, t, cutoff)
+	})
+	if run.NewComments > 0 {
+		alerts.RunAll(d.db)
+	}
+	return
+}
+
+func scrapeTarget(db *database.ScraperDB, t Target, cutoff time.Time) (err error) {
+	var urls []*url.URL
+	if t.Forum != "" {
+		var u *url.URL
+		if u, err = url.Parse(t.Forum); err == nil {
+			urls = append(urls, u)
+		}
+	} else {
+		var siteId model.SiteID
+		if siteId, err = db.GetSiteId(t.Site); err == nil && siteId == 0 {
+			err = fmt.Errorf("No site %s in the database", t.Site)
+		}
+		if err == nil {
+			urls, err = db.GetSiteForumURLs(siteId)
+		}
+	}
+	if err != nil {
+		return
+	}
+
+	// One failing forum shouldn't keep the site's others from being scraped
+	var errs []error
+	for _, u := range urls {
+		if siteAdapter, kind, err := adapter.ForURL(u); err != nil {
+			errs = append(errs, err)
+		} else if kind != adapter.ForumURL {
+			errs = append(errs, fmt.Errorf("%s is not a forum", u))
+		} else if err = siteAdapter.ScrapeForum(db, u, cutoff, t.SubForums); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", u, err))
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,110 @@
+package refresh
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+)
+
+func TestValidate(t *testing.T) {
+	good := Target{Forum: "https://forum.example.com/forums/ev.2/", Interval: time.Hour, Lookback: time.Hour}
+	require.Nil(t, good.Validate())
+
+	bad := good
+	bad.Site = "forum.example.com"
+	require.NotNil(t, bad.Validate())
+
+	bad = good
+	bad.Interval = 0
+	require.NotNil(t, bad.Validate())
+
+	bad = good
+	bad.Forum = "/forums/ev.2/"
+	require.NotNil(t, bad.Validate())
+}
+
+func TestDelay(t *testing.T) {
+	d := Daemon{MaxBackoff: 5 * time.Hour}
+	target := Target{Interval: time.Hour}
+	require.Equal(t, time.Hour, d.delay(target, 0))
+	require.Equal(t, 2*time.Hour, d.delay(target, 1))
+	require.Equal(t, 4*time.Hour, d.delay(target, 2))
+	require.Equal(t, 5*time.Hour, d.delay(target, 3))
+	require.Equal(t, 5*time.Hour, d.delay(target, 40))
+
+	// A target scheduled less often than the backoff limit keeps its interval
+	target.Interval = 6 * time.Hour
+	require.Equal(t, 6*time.Hour, d.delay(target, 1))
+}
+
+func TestRunRecordsScrapes(t *testing.T) {
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	target := Target{Site: "forum.example.com", Interval: 20 * time.Millisecond, Lookback: time.Hour}
+	d, err := New(db, []Target{target})
+	require.Nil(t, err)
+
+	var mutex sync.Mutex
+	var cutoffs []time.Time
+	d.scrape = func(db *database.ScraperDB, t Target, cutoff time.Time) error {
+		mutex.Lock()
+		defer mutex.Unlock()
+		cutoffs = append(cutoffs, cutoff)
+		switch len(cutoffs) {
+		case 1:
+			return errors.New("site is down")
+		case 2:
+			panic("database is locked")
+		}
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 300*time.Millisecond)
+	defer cancel()
+	d.Run(ctx)
+
+	// Runs continue after failures
+	mutex.Lock()
+	require.GreaterOrEqual(t, len(cutoffs), 3)
+	require.WithinDuration(t, time.Now().Add(-time.Hour), cutoffs[0], time.Second)
+	mutex.Unlock()
+
+	last, err := db.LastScrapeRun(target.String())
+	require.Nil(t, err)
+	require.Empty(t, last.Error)
+	require.False(t, last.Finished.IsZero())
+
+	_, err = db.LastScrapeRun("other.example.com")
+	require.ErrorIs(t, err, database.ErrNoScrapeRun)
+}
+
+func TestRunWaitsForInterval(t *testing.T) {
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	// A run recorded just now means the next one isn't due for an hour
+	target := Target{Site: "forum.example.com", Interval: time.Hour, Lookback: time.Hour}
+	_, err = db.StartScrapeRun(target.String(), time.Time{}, time.Now())
+	require.Nil(t, err)
+
+	d, err := New(db, []Target{target})
+	require.Nil(t, err)
+	var scrapes int
+	d.scrape = func(*database.ScraperDB, Target, time.Time) error {
+		scrapes++
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	d.Run(ctx)
+	require.Equal(t, 0, scrapes)
+}
@@ -0,0 +1,140 @@
+package replay
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Responses are stored as raw HTTP responses, one file per method and URL,
+// under a directory per host. Both the status line and headers are kept so
+// replayed pages are handled exactly like live ones, except for cookies, which
+// would leak session credentials into the recordings.
+
+var (
+	modeMutex sync.Mutex
+	recordDir string
+	replayDir string
+)
+
+// Makes Wrap save every response under dir.
+func Record(dir string) {
+	modeMutex.Lock()
+	defer modeMutex.Unlock()
+	recordDir, replayDir = dir, ""
+}
+
+// Makes Wrap serve responses from dir instead of the network.
+func Replay(dir string) {
+	modeMutex.Lock()
+	defer modeMutex.Unlock()
+	recordDir, replayDir = "", dir
+}
+
+// Makes Wrap return transports unchanged.
+func Disable() {
+	modeMutex.Lock()
+	defer modeMutex.Unlock()
+	recordDir, replayDir = "", ""
+}
+
+// Returns next wrapped for the mode selected with Record or Replay, or next
+// itself if neither was called.
+func Wrap(next http.RoundTripper) http.RoundTripper {
+	modeMutex.Lock()
+	defer modeMutex.Unlock()
+
+	if replayDir != "" {
+		return NewReplayer(replayDir)
+	} else if recordDir != "" {
+		return NewRecorder(recordDir, next)
+	}
+	return next
+}
+
+// Escaped request URIs longer than this, such as ones with long pagination
+// cursors, are shortened to fit within the 255-byte file name limit.
+const maxNameLen = 200
+
+// Returns the file holding the response to a method request for u under dir.
+// Names of requests other than GET start with the method. Long names keep a
+// readable prefix followed by a hash of the whole name.
+func Path(dir string, method string, u *url.URL) string {
+	name := u.RequestURI()
+	if method != "" && method != http.MethodGet {
+		name = method + " " + name
+	}
+	name = url.PathEscape(name)
+	if len(name) > maxNameLen {
+		sum := sha256.Sum256([]byte(name))
+		name = name[:maxNameLen-2*len(sum)-1] + "-" + hex.EncodeToString(sum[:])
+	}
+	return filepath.Join(dir, u.Host, name)
+}
+
+/*---------------------------------------------------------------------------*/
+
+type recorder struct {
+	dir  string
+	next http.RoundTripper
+}
+
+func NewRecorder(dir string, next http.RoundTripper) http.RoundTripper {
+	return &recorder{dir, next}
+}
+
+func (r *recorder) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if resp, err = r.next.RoundTrip(req); err != nil {
+		return
+	}
+
+	// DumpResponse replaces the body with an equivalent reader. The caller
+	// still gets the cookies.
+	header := resp.Header
+	resp.Header = header.Clone()
+	resp.Header.Del("Set-Cookie")
+	resp.Header.Del("Cookie")
+	var dump []byte
+	dump, err = httputil.DumpResponse(resp, true)
+	resp.Header = header
+	if err != nil {
+		resp.Body.Close()
+		return nil, err
+	}
+
+	path := Path(r.dir, req.Method, req.URL)
+	if err = os.MkdirAll(filepath.Dir(path), 0755); err == nil {
+		err = os.WriteFile(path, dump, 0644)
+	}
+	if err != nil {
+		resp.Body.Close()
+		return nil, fmt.Errorf("Failed to record %s: %w", req.URL, err)
+	}
+	return
+}
+
+/*---------------------------------------------------------------------------*/
+
+type replayer struct {
+	dir string
+}
+
+func NewReplayer(dir string) http.RoundTripper {
+	return &replayer{dir}
+}
+
+func (r *replayer) RoundTrip(req *http.Request) (*http.Response, error) {
+	dump, err := os.ReadFile(Path(r.dir, req.Method, req.URL))
+	if err != nil {
+		return nil, fmt.Errorf("No recorded response for %s: %w", req.URL, err)
+	}
+	return http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), req)
+}
@@ -0,0 +1,123 @@
+package replay
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRecordAndReplay(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("X-Page", r.URL.Query().Get("page"))
+		io.WriteString(w, "<html><body>Page "+r.URL.Query().Get("page")+"</body></html>")
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	get := func(client *http.Client, page string) (*http.Response, string) {
+		resp, err := client.Get(server.URL + "/forums/name.1/?page=" + page)
+		require.Nil(t, err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.Nil(t, err)
+		return resp, string(body)
+	}
+
+	recording := &http.Client{Transport: NewRecorder(dir, http.DefaultTransport)}
+	_, body := get(recording, "2")
+	require.Equal(t, "<html><body>Page 2</body></html>", body)
+
+	server.Close()
+
+	replaying := &http.Client{Transport: NewReplayer(dir)}
+	resp, body := get(replaying, "2")
+	require.Equal(t, "<html><body>Page 2</body></html>", body)
+	require.Equal(t, http.StatusOK, resp.StatusCode)
+	require.Equal(t, "text/html; charset=utf-8", resp.Header.Get("Content-Type"))
+	require.Equal(t, "2", resp.Header.Get("X-Page"))
+
+	_, err := replaying.Get(server.URL + "/forums/name.1/?page=3")
+	require.NotNil(t, err)
+}
+
+func TestRecordsMethodsSeparatelyWithoutCookies(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		}
+		io.WriteString(w, r.Method)
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	recording := &http.Client{Transport: NewRecorder(dir, http.DefaultTransport)}
+	resp, err := recording.Post(server.URL+"/login", "application/x-www-form-urlencoded", strings.NewReader("user=alice"))
+	require.Nil(t, err)
+	resp.Body.Close()
+	require.Equal(t, 1, len(resp.Cookies()))
+	resp, err = recording.Get(server.URL + "/login")
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	dump, err := os.ReadFile(Path(dir, http.MethodPost, resp.Request.URL))
+	require.Nil(t, err)
+	require.NotContains(t, string(dump), "secret")
+
+	replaying := &http.Client{Transport: NewReplayer(dir)}
+	for _, method := range []string{http.MethodPost, http.MethodGet} {
+		req, _ := http.NewRequest(method, server.URL+"/login", nil)
+		resp, err = replaying.Do(req)
+		require.Nil(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.Equal(t, method, string(body))
+		require.Empty(t, resp.Cookies())
+	}
+}
+
+func TestPathShortensLongNames(t *testing.T) {
+	dir := t.TempDir()
+	u, _ := url.Parse("https://www.reddit.com/r/electricvehicles/new.json?after=" + strings.Repeat("t3_abc", 60))
+	path := Path(dir, http.MethodGet, u)
+	name := filepath.Base(path)
+	require.LessOrEqual(t, len(name), 255)
+	require.True(t, strings.HasPrefix(name, "%2Fr%2Felectricvehicles%2Fnew.json%3Fafter=t3_abc"))
+
+	// Names differing only past the prefix don't collide
+	other, _ := url.Parse(u.String() + "x")
+	require.NotEqual(t, path, Path(dir, http.MethodGet, other))
+
+	// Short names are unchanged, so existing recordings still replay
+	u, _ = url.Parse("https://forum.example.com/threads/battery-warranty.10/page-2")
+	require.Equal(t, filepath.Join(dir, "forum.example.com", "%2Fthreads%2Fbattery-warranty.10%2Fpage-2"), Path(dir, http.MethodGet, u))
+
+	// Recording works for long names
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "ok")
+	}))
+	defer server.Close()
+	recording := &http.Client{Transport: NewRecorder(dir, http.DefaultTransport)}
+	resp, err := recording.Get(server.URL + "/new.json?after=" + strings.Repeat("t3_abc", 60))
+	require.Nil(t, err)
+	resp.Body.Close()
+}
+
+func TestWrap(t *testing.T) {
+	defer Disable()
+
+	require.Equal(t, http.DefaultTransport, Wrap(http.DefaultTransport))
+
+	Record(t.TempDir())
+	require.IsType(t, &recorder{}, Wrap(http.DefaultTransport))
+
+	Replay(t.TempDir())
+	require.IsType(t, &replayer{}, Wrap(http.DefaultTransport))
+}
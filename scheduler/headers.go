@@ -0,0 +1,40 @@
+package scheduler
+
+import (
+	"net/http"
+	"sync"
+)
+
+var (
+	headersMutex sync.Mutex
+	hostHeaders  = make(map[string]http.Header)
+)
+
+// Sets headers, such as a User-Agent, that replace those of every
+// request to the host made through Transport.
+func SetHostHeaders(hostname string, h http.Header) {
+	headersMutex.Lock()
+	defer headersMutex.Unlock()
+
+	if len(h) == 0 {
+		delete(hostHeaders, hostname)
+	} else {
+		hostHeaders[hostname] = h.Clone()
+	}
+}
+
+// Returns req, or a copy of it with the host's headers set.
+func withHostHeaders(req *http.Request) *http.Request {
+	headersMutex.Lock()
+	h, found := hostHeaders[req.URL.Hostname()]
+	headersMutex.Unlock()
+
+	if !found {
+		return req
+	}
+	req = req.Clone(req.Context())
+	for name, values := range h {
+		req.Header[name] = values
+	}
+	return req
+}
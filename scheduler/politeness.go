@@ -0,0 +1,159 @@
+package scheduler
+
+import (
+	"context"
+	"math/rand"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limits on how hard a single host is scraped.
+type Limits struct {
+	// Minimum time between the starts of consecutive requests.
+	Interval time.Duration
+
+	// Upper bound on a random delay added to Interval.
+	Jitter time.Duration
+
+	// Maximum number of requests in flight at once.
+	MaxInFlight int
+}
+
+// Matches the delays the scrapers used before requests were scheduled.
+var DefaultLimits = Limits{
+	Interval:    1 * time.Second,
+	Jitter:      3 * time.Second,
+	MaxInFlight: 1,
+}
+
+var (
+	hostsMutex    sync.Mutex
+	defaultLimits = DefaultLimits
+	hostLimits    = make(map[string]Limits)
+	hosts         = make(map[string]*host)
+)
+
+// Sets the limits for hosts without limits of their own.
+func SetDefaultLimits(l Limits) {
+	hostsMutex.Lock()
+	defer hostsMutex.Unlock()
+
+	defaultLimits = l
+	hosts = make(map[string]*host)
+}
+
+// Sets the limits for a single host, overriding the defaults.
+func SetHostLimits(hostname string, l Limits) {
+	hostsMutex.Lock()
+	defer hostsMutex.Unlock()
+
+	hostLimits[hostname] = l
+	delete(hosts, hostname)
+}
+
+// Returns the limits in effect for hostname.
+func LimitsFor(hostname string) Limits {
+	hostsMutex.Lock()
+	defer hostsMutex.Unlock()
+
+	return limitsFor(hostname)
+}
+
+func limitsFor(hostname string) Limits {
+	if l, found := hostLimits[hostname]; found {
+		return l
+	}
+	return defaultLimits
+}
+
+/*---------------------------------------------------------------------------*/
+
+type host struct {
+	limits Limits
+	slots  chan struct{}
+
+	mutex sync.Mutex
+	next  time.Time
+}
+
+func hostFor(hostname string) *host {
+	hostsMutex.Lock()
+	defer hostsMutex.Unlock()
+
+	h, found := hosts[hostname]
+	if !found {
+		h = &host{limits: limitsFor(hostname)}
+		if h.limits.MaxInFlight > 0 {
+			h.slots = make(chan struct{}, h.limits.MaxInFlight)
+		}
+		hosts[hostname] = h
+	}
+	return h
+}
+
+// Blocks until a request to the host may start.
+func (h *host) acquire(ctx context.Context) error {
+	if h.slots != nil {
+		select {
+		case h.slots <- struct{}{}:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+
+	h.mutex.Lock()
+	start := time.Now()
+	if h.next.After(start) {
+		start = h.next
+	}
+	delay := h.limits.Interval
+	if h.limits.Jitter > 0 {
+		delay += time.Duration(rand.Int63n(int64(h.limits.Jitter)))
+	}
+	h.next = start.Add(delay)
+	h.mutex.Unlock()
+
+	if wait := time.Until(start); wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			h.release()
+			return ctx.Err()
+		}
+	}
+	return nil
+}
+
+func (h *host) release() {
+	if h.slots != nil {
+		<-h.slots
+	}
+}
+
+/*---------------------------------------------------------------------------*/
+
+type politeTransport struct {
+	next http.RoundTripper
+}
+
+// Wraps next so that requests wait for the limits of their host and carry
+// the headers set for it with SetHostHeaders. All transports returned share
+// the same per-host state, so concurrent scrapes of one host are limited
+// together.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return politeTransport{next}
+}
+
+func (t politeTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	h := hostFor(req.URL.Hostname())
+	if err := h.acquire(req.Context()); err != nil {
+		return nil, err
+	}
+	defer h.release()
+	resp, err := t.next.RoundTrip(withHostHeaders(req))
+	countRequest(req.URL.Hostname(), resp, err)
+	return resp, err
+}
@@ -0,0 +1,69 @@
+package scheduler
+
+import (
+	"fmt"
+	"sync"
+)
+
+// A Job is a unit of scraping work against a single host.
+type Job struct {
+	Host string
+	Name string
+	Run  func() error
+}
+
+type Result struct {
+	Job Job
+	Err error
+}
+
+// Runs jobs for different hosts concurrently, with at most maxHosts hosts
+// busy at once. Jobs for the same host run one at a time in the order given,
+// and their requests are further limited by Transport. Results are returned
+// in the order of jobs.
+func Run(jobs []Job, maxHosts int) []Result {
+	if maxHosts < 1 {
+		maxHosts = 1
+	}
+
+	var hostOrder []string
+	jobsByHost := make(map[string][]int)
+	for i, job := range jobs {
+		if _, found := jobsByHost[job.Host]; !found {
+			hostOrder = append(hostOrder, job.Host)
+		}
+		jobsByHost[job.Host] = append(jobsByHost[job.Host], i)
+	}
+
+	results := make([]Result, len(jobs))
+	busy := make(chan struct{}, maxHosts)
+	var wg sync.WaitGroup
+
+	for _, hostname := range hostOrder {
+		wg.Add(1)
+		busy <- struct{}{}
+		go func(indexes []int) {
+			defer func() {
+				<-busy
+				wg.Done()
+			}()
+			for _, i := range indexes {
+				results[i] = Result{jobs[i], runJob(jobs[i])}
+			}
+		}(jobsByHost[hostname])
+	}
+
+	wg.Wait()
+	return results
+}
+
+// The database helpers panic on errors; one failing job should not stop the
+// others.
+func runJob(job Job) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return job.Run()
+}
@@ -0,0 +1,162 @@
+package scheduler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRunSerializesEachHost(t *testing.T) {
+	var mutex sync.Mutex
+	running := make(map[string]int)
+	maxRunning := make(map[string]int)
+	var order []string
+
+	job := func(hostname, name string) Job {
+		return Job{Host: hostname, Name: name, Run: func() error {
+			mutex.Lock()
+			running[hostname]++
+			if running[hostname] > maxRunning[hostname] {
+				maxRunning[hostname] = running[hostname]
+			}
+			if hostname == "a" {
+				order = append(order, name)
+			}
+			mutex.Unlock()
+
+			time.Sleep(10 * time.Millisecond)
+
+			mutex.Lock()
+			running[hostname]--
+			mutex.Unlock()
+			if name == "b2" {
+				panic("boom")
+			}
+			if name == "c1" {
+				return errors.New("failed")
+			}
+			return nil
+		}}
+	}
+
+	jobs := []Job{job("a", "a1"), job("b", "b1"), job("a", "a2"), job("c", "c1"), job("b", "b2"), job("a", "a3")}
+	results := Run(jobs, 2)
+
+	require.Equal(t, len(jobs), len(results))
+	for i, r := range results {
+		require.Equal(t, jobs[i].Name, r.Job.Name)
+	}
+	require.Nil(t, results[0].Err)
+	require.EqualError(t, results[3].Err, "failed")
+	require.EqualError(t, results[4].Err, "boom")
+
+	require.Equal(t, []string{"a1", "a2", "a3"}, order)
+	for _, hostname := range []string{"a", "b", "c"} {
+		require.Equal(t, 1, maxRunning[hostname])
+	}
+}
+
+func TestTransportLimitsHost(t *testing.T) {
+	var mutex sync.Mutex
+	var inFlight, maxInFlight int
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		inFlight++
+		if inFlight > maxInFlight {
+			maxInFlight = inFlight
+		}
+		mutex.Unlock()
+
+		time.Sleep(5 * time.Millisecond)
+
+		mutex.Lock()
+		inFlight--
+		mutex.Unlock()
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	interval := 20 * time.Millisecond
+	SetHostLimits(u.Hostname(), Limits{Interval: interval, MaxInFlight: 1})
+	require.Equal(t, interval, LimitsFor(u.Hostname()).Interval)
+	require.Equal(t, DefaultLimits, LimitsFor("example.com"))
+
+	before := StatsFor(u.Hostname())
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	start := time.Now()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := client.Get(server.URL)
+			require.Nil(t, err)
+			resp.Body.Close()
+		}()
+	}
+	wg.Wait()
+
+	require.Equal(t, 1, maxInFlight)
+	require.GreaterOrEqual(t, time.Since(start), 3*interval)
+	require.Equal(t, uint(4), StatsFor(u.Hostname()).Since(before).Requests)
+}
+
+func TestTransportSetsHostHeaders(t *testing.T) {
+	var userAgent, cookie string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		userAgent, cookie = r.Header.Get("User-Agent"), r.Header.Get("Cookie")
+	}))
+	defer server.Close()
+
+	u, _ := url.Parse(server.URL)
+	SetHostHeaders(u.Hostname(), http.Header{"User-Agent": {"espy-test"}, "Cookie": {"session=1"}})
+	defer SetHostHeaders(u.Hostname(), nil)
+
+	req, _ := http.NewRequest(http.MethodGet, server.URL, nil)
+	req.Header.Set("User-Agent", "Mozilla")
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	resp, err := client.Do(req)
+	require.Nil(t, err)
+	resp.Body.Close()
+
+	require.Equal(t, "espy-test", userAgent)
+	require.Equal(t, "session=1", cookie)
+	// The caller's request is left alone
+	require.Equal(t, "Mozilla", req.Header.Get("User-Agent"))
+}
+
+func TestTransportCountsResponses(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/private" {
+			w.WriteHeader(http.StatusForbidden)
+		}
+		w.Write([]byte("hello"))
+	}))
+	u, _ := url.Parse(server.URL)
+	SetHostLimits(u.Hostname(), Limits{})
+	before := StatsFor(u.Hostname())
+
+	client := &http.Client{Transport: Transport(http.DefaultTransport)}
+	for _, path := range []string{"/", "/private", "/"} {
+		resp, err := client.Get(server.URL + path)
+		require.Nil(t, err)
+		io.ReadAll(resp.Body)
+		resp.Body.Close()
+	}
+	server.Close()
+	_, err := client.Get(server.URL)
+	require.NotNil(t, err)
+
+	stats := StatsFor(u.Hostname()).Since(before)
+	require.Equal(t, uint(4), stats.Requests)
+	require.Equal(t, uint(1), stats.Failed)
+	require.Equal(t, map[int]uint{http.StatusOK: 2, http.StatusForbidden: 1}, stats.Statuses)
+	require.Equal(t, uint64(15), stats.Bytes)
+}
@@ -0,0 +1,104 @@
+package scheduler
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Counts of the requests made to a host through Transport.
+type Stats struct {
+	Requests uint
+
+	// Requests that failed without a response, e.g. on a timeout.
+	Failed uint
+
+	// The number of responses with each status code.
+	Statuses map[int]uint
+
+	// Response body bytes read.
+	Bytes uint64
+}
+
+var (
+	statsMutex sync.Mutex
+	hostStats  = make(map[string]*Stats)
+)
+
+// Returns the counts for the hosts together since the process started.
+// Callers measure a scrape by subtracting the counts taken before it from
+// those after.
+func StatsFor(hostnames ...string) (s Stats) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	s.Statuses = make(map[int]uint)
+	for _, hostname := range hostnames {
+		if hs, found := hostStats[hostname]; found {
+			s.Requests += hs.Requests
+			s.Failed += hs.Failed
+			s.Bytes += hs.Bytes
+			for code, count := range hs.Statuses {
+				s.Statuses[code] += count
+			}
+		}
+	}
+	return
+}
+
+// Returns the counts accumulated since earlier.
+func (s Stats) Since(earlier Stats) (diff Stats) {
+	diff = Stats{
+		Requests: s.Requests - earlier.Requests,
+		Failed:   s.Failed - earlier.Failed,
+		Statuses: make(map[int]uint),
+		Bytes:    s.Bytes - earlier.Bytes,
+	}
+	for code, count := range s.Statuses {
+		if count > earlier.Statuses[code] {
+			diff.Statuses[code] = count - earlier.Statuses[code]
+		}
+	}
+	return
+}
+
+// Must be called with statsMutex held.
+func statsLocked(hostname string) *Stats {
+	s, found := hostStats[hostname]
+	if !found {
+		s = &Stats{Statuses: make(map[int]uint)}
+		hostStats[hostname] = s
+	}
+	return s
+}
+
+// Counts a request to hostname and its response, and wraps the response body
+// to count the bytes read from it.
+func countRequest(hostname string, resp *http.Response, err error) {
+	statsMutex.Lock()
+	defer statsMutex.Unlock()
+
+	s := statsLocked(hostname)
+	s.Requests++
+	if err != nil {
+		s.Failed++
+	} else {
+		s.Statuses[resp.StatusCode]++
+		resp.Body = &countingBody{resp.Body, hostname}
+	}
+}
+
+type countingBody struct {
+	io.ReadCloser
+	hostname string
+}
+
+func (b *countingBody) Read(p []byte) (n int, err error) {
+	n, err = b.ReadCloser.Read(p)
+	if n > 0 {
+		statsMutex.Lock()
+		statsLocked(b.hostname).Bytes += uint64(n)
+		statsMutex.Unlock()
+	}
+	return
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Cookies exported by browsers in the Netscape cookies.txt format mark those
+// hidden from scripts with this prefix on what would otherwise be a comment.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// Reads cookies in the Netscape cookies.txt format used by curl, wget and
+// browser export extensions: one cookie per line, with tab-separated domain,
+// include-subdomains flag, path, secure flag, expiry time, name and value.
+func ReadCookieFile(r io.Reader) (cookies []*http.Cookie, err error) {
+	scanner := bufio.NewScanner(r)
+	for lineNum := 1; scanner.Scan(); lineNum++ {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(line, httpOnlyPrefix)
+		if httpOnly {
+			line = strings.TrimPrefix(line, httpOnlyPrefix)
+		} else if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		fields := strings.Split(line, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("Bad cookie on line %d: expected 7 tab-separated fields, found %d", lineNum, len(fields))
+		}
+		var expires int64
+		if expires, err = strconv.ParseInt(fields[4], 10, 64); err != nil {
+			return nil, fmt.Errorf("Bad cookie expiry on line %d: %w", lineNum, err)
+		}
+
+		c := &http.Cookie{
+			Domain:   fields[0],
+			Path:     fields[2],
+			Secure:   strings.EqualFold(fields[3], "TRUE"),
+			Name:     fields[5],
+			Value:    fields[6],
+			HttpOnly: httpOnly,
+		}
+		// Session cookies are exported with an expiry of zero
+		if expires > 0 {
+			c.Expires = time.Unix(expires, 0)
+		}
+		cookies = append(cookies, c)
+	}
+	err = scanner.Err()
+	return
+}
+
+// Returns the cookies that would be sent to the host.
+func CookiesForHost(cookies []*http.Cookie, hostname string) (matching []*http.Cookie) {
+	for _, c := range cookies {
+		domain := strings.TrimPrefix(c.Domain, ".")
+		if hostname == domain || strings.HasSuffix(hostname, "."+domain) {
+			matching = append(matching, c)
+		}
+	}
+	return
+}
@@ -0,0 +1,182 @@
+// Package session keeps the cookies of signed-in sessions with forums, so
+// that scrapes can see what the site only shows to members. Cookies are
+// shared by every scraper through one jar, and are stored in the database
+// between runs.
+package session
+
+import (
+	"net/http"
+	"net/http/cookiejar"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/zvonler/espy/database"
+)
+
+// A member's login for a site.
+type Credentials struct {
+	Username string
+	Password string
+}
+
+var (
+	mutex       sync.Mutex
+	jar, _      = cookiejar.New(nil)
+	cookies     = make(map[string]map[string]*http.Cookie)
+	restored    = make(map[string]bool)
+	credentials = make(map[string]Credentials)
+)
+
+// Returns the cookie jar to be used by every client that scrapes sites.
+func Jar() *cookiejar.Jar {
+	return jar
+}
+
+// Sets the login used to sign in to the host when its session has expired.
+func SetCredentials(hostname string, c Credentials) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	credentials[hostname] = c
+}
+
+// Removes the login set for the host, so its sessions are no longer renewed.
+func ForgetCredentials(hostname string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	delete(credentials, hostname)
+}
+
+// Returns the login set for the host, if any.
+func CredentialsFor(hostname string) (c Credentials, found bool) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	c, found = credentials[hostname]
+	return
+}
+
+// Returns the value of the named cookie last set by the host, or "" if it
+// has none.
+func Cookie(hostname, name string) string {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	if c, found := cookies[hostname][name]; found {
+		return c.Value
+	}
+	return ""
+}
+
+// Adds cookies for the host to the jar, as if the host had set them.
+func SetCookies(u *url.URL, cs []*http.Cookie) {
+	jar.SetCookies(u, cs)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	record(u, cs)
+}
+
+func record(u *url.URL, cs []*http.Cookie) {
+	hostname := u.Hostname()
+	now := time.Now()
+	if cookies[hostname] == nil {
+		cookies[hostname] = make(map[string]*http.Cookie)
+	}
+	for _, c := range cs {
+		if c.MaxAge < 0 || (!c.Expires.IsZero() && c.Expires.Before(now)) {
+			delete(cookies[hostname], c.Name)
+			continue
+		}
+		if c.MaxAge > 0 {
+			c.Expires = now.Add(time.Duration(c.MaxAge) * time.Second)
+			c.MaxAge = 0
+		}
+		if c.Path == "" {
+			c.Path = defaultPath(u.Path)
+		}
+		cookies[hostname][c.Name] = c
+	}
+}
+
+// The path the jar gives a cookie set without one, per RFC 6265 section 5.1.4.
+func defaultPath(path string) string {
+	if i := strings.LastIndex(path, "/"); i > 0 {
+		return path[:i]
+	}
+	return "/"
+}
+
+// Loads the session stored for u's host into the jar, the first time it's
+// called for the host.
+func Restore(db *database.ScraperDB, u *url.URL) (err error) {
+	hostname := u.Hostname()
+
+	mutex.Lock()
+	done := restored[hostname]
+	restored[hostname] = true
+	mutex.Unlock()
+
+	if !done {
+		var stored []*http.Cookie
+		if stored, _, err = db.GetSessionCookies(hostname); err == nil && len(stored) > 0 {
+			SetCookies(&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}, stored)
+		}
+	}
+	return
+}
+
+// Stores the session cookies the host has set, replacing those stored before.
+func Save(db *database.ScraperDB, hostname string) error {
+	mutex.Lock()
+	var cs []*http.Cookie
+	for _, c := range cookies[hostname] {
+		cs = append(cs, c)
+	}
+	mutex.Unlock()
+
+	return db.SetSessionCookies(hostname, cs)
+}
+
+// Removes the host's cookies from the jar, so that the next request is made
+// without a session.
+func Forget(u *url.URL) {
+	mutex.Lock()
+	defer mutex.Unlock()
+
+	var expired []*http.Cookie
+	for _, c := range cookies[u.Hostname()] {
+		expired = append(expired, &http.Cookie{Name: c.Name, Domain: c.Domain, Path: c.Path, MaxAge: -1})
+	}
+	jar.SetCookies(&url.URL{Scheme: u.Scheme, Host: u.Host, Path: "/"}, expired)
+	delete(cookies, u.Hostname())
+	delete(restored, u.Hostname())
+}
+
+/*---------------------------------------------------------------------------*/
+
+type recordingTransport struct {
+	next http.RoundTripper
+}
+
+// Wraps next so that the cookies responses set are kept for Save. The jar
+// only hands back cookies' names and values, so their other attributes are
+// taken from the responses.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	return recordingTransport{next}
+}
+
+func (t recordingTransport) RoundTrip(req *http.Request) (resp *http.Response, err error) {
+	if resp, err = t.next.RoundTrip(req); err == nil {
+		if cs := resp.Cookies(); len(cs) > 0 {
+			mutex.Lock()
+			record(req.URL, cs)
+			mutex.Unlock()
+		}
+	}
+	return
+}
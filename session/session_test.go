@@ -0,0 +1,55 @@
+package session
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+)
+
+func TestReadCookieFile(t *testing.T) {
+	file := "# Netscape HTTP Cookie File\n" +
+		"\n" +
+		".example.com\tTRUE\t/\tTRUE\t1900000000\txf_user\t1%2Cabc\n" +
+		"#HttpOnly_forum.example.com\tFALSE\t/\tFALSE\t0\txf_session\tdef\n" +
+		"other.org\tFALSE\t/\tFALSE\t0\tid\t1\n"
+	cookies, err := ReadCookieFile(strings.NewReader(file))
+	require.Nil(t, err)
+	require.Equal(t, 3, len(cookies))
+	require.Equal(t, &http.Cookie{Name: "xf_user", Value: "1%2Cabc", Domain: ".example.com", Path: "/",
+		Secure: true, Expires: time.Unix(1900000000, 0)}, cookies[0])
+	require.True(t, cookies[1].HttpOnly)
+	require.True(t, cookies[1].Expires.IsZero())
+
+	matching := CookiesForHost(cookies, "forum.example.com")
+	require.Equal(t, 2, len(matching))
+
+	_, err = ReadCookieFile(strings.NewReader("example.com\tTRUE\t/\n"))
+	require.NotNil(t, err)
+}
+
+func TestSaveAndRestore(t *testing.T) {
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	u, _ := url.Parse("https://forum.example.com/forums/vehicles.2/")
+	SetCookies(u, []*http.Cookie{
+		{Name: "xf_user", Value: "1", MaxAge: 3600},
+		{Name: "gone", Value: "x", Expires: time.Now().Add(-time.Hour)},
+	})
+	require.Equal(t, "1", Cookie(u.Hostname(), "xf_user"))
+	require.Equal(t, "", Cookie(u.Hostname(), "gone"))
+	require.Nil(t, Save(db, u.Hostname()))
+
+	Forget(u)
+	require.Empty(t, Jar().Cookies(u))
+
+	require.Nil(t, Restore(db, u))
+	require.Equal(t, []*http.Cookie{{Name: "xf_user", Value: "1"}}, Jar().Cookies(u))
+	Forget(u)
+}
@@ -0,0 +1,80 @@
+package utils
+
+import (
+	"strings"
+)
+
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffDelete
+	DiffInsert
+)
+
+// Prefix used when printing a line of a diff.
+func (op DiffOp) String() string {
+	switch op {
+	case DiffDelete:
+		return "-"
+	case DiffInsert:
+		return "+"
+	}
+	return " "
+}
+
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// Returns a line-by-line diff that turns a into b, using the longest common
+// subsequence of lines. Deleted lines come before inserted ones where both
+// occur at the same place.
+func LineDiff(a, b string) (diff []DiffLine) {
+	aLines, bLines := splitLines(a), splitLines(b)
+
+	// lcs[i][j] is the length of the LCS of aLines[i:] and bLines[j:]
+	lcs := make([][]int, len(aLines)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(bLines)+1)
+	}
+	for i := len(aLines) - 1; i >= 0; i-- {
+		for j := len(bLines) - 1; j >= 0; j-- {
+			if aLines[i] == bLines[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	i, j := 0, 0
+	for i < len(aLines) && j < len(bLines) {
+		if aLines[i] == bLines[j] {
+			diff = append(diff, DiffLine{DiffEqual, aLines[i]})
+			i++
+			j++
+		} else if lcs[i+1][j] >= lcs[i][j+1] {
+			diff = append(diff, DiffLine{DiffDelete, aLines[i]})
+			i++
+		} else {
+			diff = append(diff, DiffLine{DiffInsert, bLines[j]})
+			j++
+		}
+	}
+	for ; i < len(aLines); i++ {
+		diff = append(diff, DiffLine{DiffDelete, aLines[i]})
+	}
+	for ; j < len(bLines); j++ {
+		diff = append(diff, DiffLine{DiffInsert, bLines[j]})
+	}
+	return
+}
+
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(s, "\n"), "\n")
+}
@@ -0,0 +1,27 @@
+package utils
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLineDiff(t *testing.T) {
+	require.Empty(t, LineDiff("", ""))
+
+	diff := LineDiff("first\nsecond\nthird", "first\nchanged\nthird\nfourth")
+	require.Equal(t, []DiffLine{
+		{DiffEqual, "first"},
+		{DiffDelete, "second"},
+		{DiffInsert, "changed"},
+		{DiffEqual, "third"},
+		{DiffInsert, "fourth"},
+	}, diff)
+
+	diff = LineDiff("removed\nkept\n", "kept\n")
+	require.Equal(t, []DiffLine{{DiffDelete, "removed"}, {DiffEqual, "kept"}}, diff)
+
+	require.Equal(t, "+", DiffInsert.String())
+	require.Equal(t, "-", DiffDelete.String())
+	require.Equal(t, " ", DiffEqual.String())
+}
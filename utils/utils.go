@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"time"
 )
 
 func TrimmedURL(url *url.URL) *url.URL {
@@ -42,3 +43,22 @@ func ParseURLOrID(arg string) (url *url.URL, id uint, err error) {
 	}
 	return
 }
+
+// Parses a point in time given either as a date (2006-01-02), an RFC 3339
+// timestamp, or an age before now such as "36h" or "7d".
+func ParseSince(arg string, now time.Time) (since time.Time, err error) {
+	if days, found := strings.CutSuffix(arg, "d"); found {
+		var n int
+		if n, err = strconv.Atoi(days); err == nil {
+			since = now.AddDate(0, 0, -n)
+		}
+	} else if d, durErr := time.ParseDuration(arg); durErr == nil {
+		since = now.Add(-d)
+	} else if since, err = time.ParseInLocation("2006-01-02", arg, time.Local); err != nil {
+		since, err = time.Parse(time.RFC3339, arg)
+	}
+	if err != nil {
+		err = fmt.Errorf("Bad time %q: expected a date, RFC 3339 time or age like 36h or 7d", arg)
+	}
+	return
+}
@@ -5,6 +5,7 @@ import (
 	"os"
 	"path/filepath"
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 )
@@ -72,3 +73,26 @@ func TestParseURLOrID(t *testing.T) {
 	require.Equal(t, forumUrl, url.String())
 	require.Equal(t, uint(0), id)
 }
+
+func TestParseSince(t *testing.T) {
+	now := time.Date(2023, 10, 8, 12, 0, 0, 0, time.UTC)
+
+	since, err := ParseSince("7d", now)
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2023, 10, 1, 12, 0, 0, 0, time.UTC), since)
+
+	since, err = ParseSince("36h", now)
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2023, 10, 7, 0, 0, 0, 0, time.UTC), since)
+
+	since, err = ParseSince("2023-10-01T00:00:00Z", now)
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2023, 10, 1, 0, 0, 0, 0, time.UTC), since.UTC())
+
+	since, err = ParseSince("2023-10-01", now)
+	require.Nil(t, err)
+	require.Equal(t, time.Date(2023, 10, 1, 0, 0, 0, 0, time.Local), since)
+
+	_, err = ParseSince("last week", now)
+	require.NotNil(t, err)
+}
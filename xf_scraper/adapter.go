@@ -0,0 +1,106 @@
+package xf_scraper
+
+import (
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/session"
+)
+
+func init() {
+	adapter.Register(xenForoAdapter{})
+}
+
+type xenForoAdapter struct{}
+
+func (xenForoAdapter) Name() string {
+	return "xenforo"
+}
+
+func (xenForoAdapter) URLShapes() []string {
+	return []string{
+		"https://<host>/forums/<name>.<id>",
+		"https://<host>/threads/<title>.<id>",
+	}
+}
+
+func (xenForoAdapter) Matches(u *url.URL) adapter.URLKind {
+	if strings.Contains(u.Path, "/forums/") {
+		return adapter.ForumURL
+	} else if strings.Contains(u.Path, "/threads/") {
+		return adapter.ThreadURL
+	}
+	return adapter.NoMatch
+}
+
+// Scrapes within the site's stored session, signing in first if needed, and
+// stores the session's cookies afterward since the site may have renewed
+// them.
+func withSession(db *database.ScraperDB, u *url.URL, scrape func() error) (err error) {
+	if err = ensureSession(db, u); err == nil {
+		if err = scrape(); err == nil {
+			err = session.Save(db, u.Hostname())
+		}
+	}
+	return
+}
+
+func (xenForoAdapter) ScrapeForum(db *database.ScraperDB, u *url.URL, cutoff time.Time, subforums bool) error {
+	return withSession(db, u, func() error {
+		return StartCrawl(db, u, cutoff, subforums)
+	})
+}
+
+func (xenForoAdapter) ResumeCrawl(db *database.ScraperDB, crawl database.Crawl) error {
+	return withSession(db, crawl.URL, func() error {
+		return RunCrawl(db, crawl)
+	})
+}
+
+func (xenForoAdapter) ScrapeThread(db *database.ScraperDB, thread model.Thread, cutoff time.Time) (comments []model.Comment, err error) {
+	err = withSession(db, thread.URL, func() error {
+		xfThread := XFThread{model.Thread{URL: thread.URL}}
+		ts := NewThreadScraper(thread.Id, xfThread)
+		err := ts.LoadCommentsSince(db, cutoff)
+		comments = ts.comments()
+		return err
+	})
+	return
+}
+
+// Stores the thread's comments like a crawl does, marking as removed those
+// missing from the pages fetched.
+func (xenForoAdapter) StoreThread(db *database.ScraperDB, thread model.Thread, cutoff time.Time) error {
+	return withSession(db, thread.URL, func() (err error) {
+		ts := NewThreadScraper(thread.Id, XFThread{thread})
+		if err = ts.LoadCommentsSince(db, cutoff); err == nil {
+			err = ts.StoreComments(db)
+		}
+		return
+	})
+}
+
+func (xenForoAdapter) Reextract(thread model.Thread, commentURL *url.URL, html string) (model.Comment, error) {
+	return ExtractComment(html, commentURL, thread.URL)
+}
+
+func (xenForoAdapter) ParsePage(u *url.URL) (page adapter.Page, err error) {
+	if strings.Contains(u.Path, "/threads/") {
+		ts := NewThreadScraper(0, XFThread{model.Thread{URL: u}})
+		ts.errs.visit(ts.CommentScraper, u.String())
+		page.Comments = ts.comments()
+		err = ts.errs.err
+	} else {
+		fs := NewForumScraper(u)
+		err = fs.Visit(u.String())
+		for _, t := range fs.Threads {
+			page.Threads = append(page.Threads, t.Thread)
+		}
+		page.SubForums = fs.SubForums
+	}
+	return
+}
@@ -0,0 +1,288 @@
+package xf_scraper
+
+import (
+	"errors"
+	"log"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/gocolly/colly"
+	"github.com/zvonler/espy/model"
+	"golang.org/x/net/html"
+)
+
+// Extracts a comment from an article.message--post element. Relative URLs
+// are resolved against the element's request URL, and quoted post URLs are
+// built from threadURL.
+func extractComment(e *colly.HTMLElement, threadURL *url.URL) (temp XFComment) {
+	temp.HTML, _ = goquery.OuterHtml(e.DOM)
+	temp.Author = e.Attr("data-author")
+	temp.AuthorProfile = parseAuthorProfile(e)
+	if temp.AuthorProfile != nil {
+		temp.AuthorExternalId = temp.AuthorProfile.MemberId
+	}
+	reactionCount, reactions := parseReactions(e)
+	temp.ReactionCount = &reactionCount
+	temp.Reactions = reactions
+	e.ForEach("article.message-body", func(_ int, e *colly.HTMLElement) {
+		// These get just the content of the blockquote
+		// temp.Content = e.DOM.ChildrenFiltered(".bbCodeBlock--quote").Text()
+		// temp.Content = e.ChildText(".bbCodeBlock--quote")
+
+		// These approaches return the full text including blockquotes
+		// temp.Content = e.DOM.ChildrenFiltered("*").Text()
+		// temp.Content = e.DOM.ChildrenFiltered("*:not(.bbCodeBlock--quote)").Text()
+		// temp.Content = e.DOM.Not("div").Text()
+		// temp.Content = e.DOM.Not(".bbCodeBlock--quote").Text()
+		// temp.Content = e.Text
+
+		// These double up the text including the blockquote contents
+		// temp.Content = e.ChildText("*:not(.bbCodeBlock--quote)")
+		// temp.Content = e.ChildText("*")
+
+		// This returns no text
+		// temp.Content = e.DOM.Not("*").Text()
+
+		// Approaches above don't work so filter blockquotes manually
+		outer, _ := goquery.OuterHtml(e.DOM)
+		doc, _ := html.Parse(strings.NewReader(outer))
+		var collectText func(*html.Node)
+		collectText = func(n *html.Node) {
+			if n.Type == html.ElementNode && n.Data == "blockquote" {
+				return
+			}
+			if n.Type == html.TextNode {
+				temp.Content += n.Data
+			}
+			for c := n.FirstChild; c != nil; c = c.NextSibling {
+				collectText(c)
+			}
+		}
+		collectText(doc)
+
+		// Quotes nested in other quotes were not quoted by this comment
+		e.ForEach("blockquote.bbCodeBlock--quote", func(_ int, e *colly.HTMLElement) {
+			if e.DOM.ParentsFiltered("blockquote").Length() == 0 {
+				temp.Quotes = append(temp.Quotes, parseQuote(e, threadURL))
+			}
+		})
+		temp.Links = append(temp.Links, parseLinks(e)...)
+	})
+	temp.Media = parseMedia(e)
+
+	// Replace non-breaking spaces with regular spaces
+	temp.Content = strings.ReplaceAll(temp.Content, "\u00a0", " ")
+
+	// Remove whitespace-only lines
+	wsLinePat := regexp.MustCompile("\n[ \t]+\n")
+	temp.Content = string(wsLinePat.ReplaceAll([]byte(temp.Content), []byte("\n")))
+
+	// Replace repeated newlines with singles
+	nlPat := regexp.MustCompile("\n\n+")
+	temp.Content = string(nlPat.ReplaceAll([]byte(temp.Content), []byte("\n")))
+
+	// Trim leading and trailing newlines
+	temp.Content = strings.TrimLeft(temp.Content, "\n")
+	temp.Content = strings.TrimRight(temp.Content, "\n")
+
+	getUrl := func(_ int, e *colly.HTMLElement) {
+		commentHref, err := url.Parse(e.ChildAttr("a", "href"))
+		if err != nil {
+			panic(err)
+		}
+		temp.URL = e.Request.URL.ResolveReference(commentHref)
+		dataTime := e.ChildAttr("time.u-dt", "data-time")
+		if tm, err := strconv.Atoi(dataTime); err != nil {
+			log.Printf("Unparseable data-time '%v' for %s", dataTime, temp.Author)
+		} else {
+			temp.Published = time.Unix(int64(tm), 0)
+		}
+	}
+	e.ForEach("ul.message-attribution-main", getUrl)
+	if temp.URL == nil {
+		e.ForEach("div.message-attribution-main", getUrl)
+	}
+
+	return
+}
+
+// Extracts a comment from the stored HTML of its article.message--post
+// element, as if it had just been scraped from pageURL.
+func ExtractComment(rawHTML string, pageURL, threadURL *url.URL) (comment model.Comment, err error) {
+	var doc *goquery.Document
+	if doc, err = goquery.NewDocumentFromReader(strings.NewReader(rawHTML)); err != nil {
+		return
+	}
+	post := doc.Find("article.message--post").First()
+	if post.Length() == 0 {
+		return comment, errors.New("No article.message--post element in HTML")
+	}
+	resp := &colly.Response{Request: &colly.Request{URL: pageURL}}
+	e := colly.NewHTMLElementFromSelectionNode(resp, post, post.Nodes[0], 0)
+	return extractComment(e, threadURL).Comment, nil
+}
+
+// Parses the member details shown beside a post. Returns nil for guests,
+// who have no member id.
+func parseAuthorProfile(post *colly.HTMLElement) (profile *model.AuthorProfile) {
+	post.ForEach("section.message-user", func(_ int, e *colly.HTMLElement) {
+		memberId := e.ChildAttr("a.username", "data-user-id")
+		if memberId == "" {
+			return
+		}
+		profile = &model.AuthorProfile{
+			MemberId: memberId,
+			Username: e.ChildText("a.username"),
+			Title:    e.ChildText(".userTitle"),
+		}
+		e.ForEach("div.message-userExtras dl.pairs", func(_ int, e *colly.HTMLElement) {
+			dd := e.ChildText("dd")
+			switch e.ChildText("dt") {
+			case "Joined":
+				if joined, err := time.Parse("Jan 2, 2006", dd); err == nil {
+					profile.Joined = joined
+				}
+			case "Messages":
+				profile.Messages = parseCompactCount(dd)
+			case "Reaction score":
+				profile.ReactionScore = parseCompactCount(dd)
+			case "Location":
+				profile.Location = dd
+			}
+		})
+	})
+	return
+}
+
+var othersPat = regexp.MustCompile(`([\d,.]+[KM]?) others?`)
+
+// Parses a post's reactions bar, which names a few of the members who reacted
+// followed by "and N others", and shows the kinds given but not how often.
+// The count of a kind is known only when it is the only one given.
+func parseReactions(post *colly.HTMLElement) (count uint, reactions []model.Reaction) {
+	post.ForEach("div.reactionsBar", func(_ int, e *colly.HTMLElement) {
+		e.ForEach("ul.reactionSummary img.reaction-sprite", func(_ int, e *colly.HTMLElement) {
+			kind := e.Attr("title")
+			if kind == "" {
+				kind = e.Attr("alt")
+			}
+			reactions = append(reactions, model.Reaction{Kind: kind})
+		})
+		link := e.DOM.Find("a.reactionsBar-link")
+		count = uint(link.Find("bdi").Length())
+		text := strings.TrimSpace(link.Text())
+		// The logged in member is shown as an unmarked "You"
+		if strings.HasPrefix(text, "You") {
+			count++
+		}
+		if m := othersPat.FindStringSubmatch(text); m != nil {
+			count += parseCompactCount(m[1])
+		}
+	})
+	if len(reactions) == 1 {
+		reactions[0].Count = count
+	}
+	return
+}
+
+// Parses a quote block's attribution, such as
+// <blockquote data-quote="alice" data-source="post: 101" data-attributes="member: 1">.
+// XenForo post URLs are the thread URL followed by post-<id>, so the quoted
+// post's URL is assumed to be in the same thread.
+func parseQuote(e *colly.HTMLElement, threadURL *url.URL) (quote model.Quote) {
+	quote.Author = e.Attr("data-quote")
+	if postId, found := strings.CutPrefix(e.Attr("data-source"), "post:"); found {
+		quote.PostId = strings.TrimSpace(postId)
+		if quote.PostId != "" && threadURL != nil {
+			quote.URL = threadURL.JoinPath("post-" + quote.PostId)
+		}
+	}
+	if memberId, found := strings.CutPrefix(e.Attr("data-attributes"), "member:"); found {
+		quote.MemberId = strings.TrimSpace(memberId)
+	}
+	return
+}
+
+// Returns true if the element is inside a quote of another post, whose links
+// and media belong to the quoted post.
+func inQuote(e *colly.HTMLElement) bool {
+	return e.DOM.ParentsFiltered("blockquote").Length() > 0
+}
+
+func absoluteURL(e *colly.HTMLElement, ref string) *url.URL {
+	if ref == "" || strings.HasPrefix(ref, "data:") {
+		return nil
+	}
+	if u, err := url.Parse(e.Request.AbsoluteURL(ref)); err == nil && u.Scheme != "" {
+		return u
+	}
+	return nil
+}
+
+// Collects the links in a post body, skipping member mentions and quotes.
+func parseLinks(body *colly.HTMLElement) (links []model.Link) {
+	body.ForEach("a[href]", func(_ int, e *colly.HTMLElement) {
+		if inQuote(e) || e.Attr("data-user-id") != "" || e.DOM.Find("img").Length() > 0 {
+			return
+		}
+		if u := absoluteURL(e, e.Attr("href")); u != nil && (u.Scheme == "http" || u.Scheme == "https") {
+			links = append(links, model.Link{URL: u, Text: strings.TrimSpace(e.Text)})
+		}
+	})
+	return
+}
+
+// Collects images, videos and embedded players from a post body, and files
+// from the post's attachment list.
+func parseMedia(post *colly.HTMLElement) (media []model.Media) {
+	add := func(kind model.MediaKind, e *colly.HTMLElement, ref, text string) {
+		if u := absoluteURL(e, ref); u != nil {
+			media = append(media, model.Media{Kind: kind, URL: u, Text: strings.TrimSpace(text)})
+		}
+	}
+
+	post.ForEach("article.message-body", func(_ int, body *colly.HTMLElement) {
+		body.ForEach("img.bbImage", func(_ int, e *colly.HTMLElement) {
+			if !inQuote(e) {
+				// Lazy-loaded images keep their source in data-src
+				src := e.Attr("data-src")
+				if src == "" {
+					src = e.Attr("src")
+				}
+				add(model.Image, e, src, e.Attr("alt"))
+			}
+		})
+		body.ForEach("video", func(_ int, e *colly.HTMLElement) {
+			if !inQuote(e) {
+				src := e.Attr("src")
+				if src == "" {
+					src = e.ChildAttr("source", "src")
+				}
+				add(model.Video, e, src, "")
+			}
+		})
+		body.ForEach("[data-s9e-mediaembed]", func(_ int, e *colly.HTMLElement) {
+			if !inQuote(e) {
+				add(model.Embed, e, e.ChildAttr("iframe", "src"), e.Attr("data-s9e-mediaembed"))
+			}
+		})
+		body.ForEach("iframe", func(_ int, e *colly.HTMLElement) {
+			if !inQuote(e) && e.DOM.ParentsFiltered("[data-s9e-mediaembed]").Length() == 0 {
+				add(model.Embed, e, e.Attr("src"), "")
+			}
+		})
+	})
+
+	post.ForEach("ul.attachmentList li.attachment", func(_ int, e *colly.HTMLElement) {
+		href := e.ChildAttr("a.file-preview", "href")
+		if href == "" {
+			href = e.ChildAttr("a", "href")
+		}
+		add(model.Attachment, e, href, e.ChildText(".file-name"))
+	})
+	return
+}
@@ -0,0 +1,126 @@
+package xf_scraper
+
+import (
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/zvonler/espy/database"
+)
+
+// Creates a crawl of the forum at forumURL and runs it to completion.
+func StartCrawl(db *database.ScraperDB, forumURL *url.URL, cutoff time.Time, subforums bool) (err error) {
+	var crawl database.Crawl
+	if crawl, err = db.StartCrawl(forumURL, cutoff, subforums); err == nil {
+		_, forumId, ierr := db.InsertOrUpdateForum(forumURL)
+		if ierr != nil {
+			return ierr
+		}
+		db.AddCrawlTask(crawl.Id, database.CrawlTask{
+			Kind: database.CrawlForumPage, URL: forumURL, Page: 1, ForumId: forumId})
+		err = RunCrawl(db, crawl)
+	}
+	return
+}
+
+// Processes the pending tasks of a crawl until none remain. A task that fails
+// is recorded and skipped, and the crawl is left unfinished so that resuming
+// it retries the failures.
+func RunCrawl(db *database.ScraperDB, crawl database.Crawl) error {
+	fmt.Printf("Running crawl %d of %s\n", crawl.Id, crawl.URL)
+	for {
+		task, ok := db.NextCrawlTask(crawl.Id)
+		if !ok {
+			break
+		}
+		if err := runCrawlTask(db, crawl, task); err != nil {
+			fmt.Printf("Crawl task %s %s failed: %v\n", task.Kind, task.URL, err)
+			db.SetCrawlTaskState(task.Id, database.CrawlFailed, err)
+		} else {
+			db.SetCrawlTaskState(task.Id, database.CrawlDone, nil)
+		}
+	}
+
+	if failed := db.CrawlTaskCounts(crawl.Id)[database.CrawlFailed]; failed > 0 {
+		return fmt.Errorf("Crawl %d has %d failed tasks; use --resume to retry them", crawl.Id, failed)
+	}
+	db.FinishCrawl(crawl.Id)
+	return nil
+}
+
+func runCrawlTask(db *database.ScraperDB, crawl database.Crawl, task database.CrawlTask) (err error) {
+	// The database helpers panic on errors, which should fail only this task.
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+
+	switch task.Kind {
+	case database.CrawlForumPage:
+		err = crawlForumPage(db, crawl, task)
+	case database.CrawlThread:
+		err = crawlThread(db, crawl, task)
+	default:
+		err = fmt.Errorf("Unknown crawl task kind %q", task.Kind)
+	}
+	return
+}
+
+func crawlForumPage(db *database.ScraperDB, crawl database.Crawl, task database.CrawlTask) error {
+	forumURL := task.URL
+	pageURL := forumURL
+	if task.Page > 1 {
+		pageURL = forumURL.JoinPath(fmt.Sprintf("page-%d", task.Page))
+	}
+
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	if err != nil {
+		return err
+	}
+
+	fs := NewForumScraper(forumURL)
+	if err = fs.Visit(pageURL.String()); err != nil {
+		return err
+	}
+
+	for _, thread := range fs.Threads {
+		threadId, err := db.InsertOrUpdateThread(siteId, forumId, thread.Thread)
+		if err != nil {
+			return err
+		}
+		if thread.Latest.After(crawl.Cutoff) {
+			db.AddCrawlTask(crawl.Id, database.CrawlTask{
+				Kind: database.CrawlThread, URL: thread.URL, ForumId: forumId, ThreadId: threadId})
+		}
+	}
+
+	if len(fs.Threads) > 0 && fs.Threads[len(fs.Threads)-1].Latest.After(crawl.Cutoff) {
+		db.AddCrawlTask(crawl.Id, database.CrawlTask{
+			Kind: database.CrawlForumPage, URL: forumURL, Page: task.Page + 1, ForumId: forumId})
+	}
+
+	if crawl.SubForums && task.Page == 1 {
+		for _, subForumURL := range fs.SubForums {
+			_, subForumId, err := db.InsertOrUpdateForum(subForumURL)
+			if err != nil {
+				return err
+			}
+			db.AddCrawlTask(crawl.Id, database.CrawlTask{
+				Kind: database.CrawlForumPage, URL: subForumURL, Page: 1, ForumId: subForumId})
+		}
+	}
+	return nil
+}
+
+func crawlThread(db *database.ScraperDB, crawl database.Crawl, task database.CrawlTask) error {
+	thread, err := db.GetThreadById(task.ThreadId)
+	if err != nil {
+		return err
+	}
+	ts := NewThreadScraper(thread.Id, XFThread{thread})
+	if err = ts.LoadCommentsSince(db, crawl.Cutoff); err != nil {
+		return err
+	}
+	return ts.StoreComments(db)
+}
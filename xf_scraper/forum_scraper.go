@@ -3,14 +3,14 @@ package xf_scraper
 import (
 	"fmt"
 	"log"
-	"math/rand"
+	"math"
 	"net/url"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/gocolly/colly"
 	"github.com/zvonler/espy/database"
-	"github.com/zvonler/espy/model"
 )
 
 type ForumScraper struct {
@@ -18,6 +18,7 @@ type ForumScraper struct {
 	Threads   []XFThread
 	SubForums []*url.URL
 	Collector *colly.Collector
+	errs      visitErrors
 }
 
 func NewForumScraper(forumURL *url.URL) *ForumScraper {
@@ -39,6 +40,7 @@ func NewForumScraper(forumURL *url.URL) *ForumScraper {
 	processThread := func(e *colly.HTMLElement) {
 		temp := XFThread{}
 		temp.Author = e.Attr("data-author")
+		temp.AuthorExternalId = e.ChildAttr("ul.structItem-parts a.username", "data-user-id")
 
 		e.ForEach("div.structItem-title", func(_ int, e *colly.HTMLElement) {
 			e.ForEach("a", func(_ int, e *colly.HTMLElement) {
@@ -97,63 +99,37 @@ func NewForumScraper(forumURL *url.URL) *ForumScraper {
 
 	fs.Collector.OnError(func(r *colly.Response, err error) {
 		fmt.Printf("ForumScraper got %v for %s\n", err, r.Request.URL)
+		fs.errs.onError(r, err)
 	})
 
 	return fs
 }
 
-func (fs *ForumScraper) LoadThreadsWithActivitySince(db *database.ScraperDB, cutoff time.Time, subthreads bool) {
-	siteId, forumId, err := db.InsertOrUpdateForum(fs.forumURL)
-	if err != nil {
-		panic(err)
-	}
-
-	fs.Collector.Visit(fs.forumURL.String())
-	time.Sleep(1 + time.Duration(rand.Intn(3))*time.Second)
-
-	if len(fs.Threads) > 0 {
-		for pageNum := 2; fs.Threads[len(fs.Threads)-1].Latest.After(cutoff); pageNum++ {
-			time.Sleep(1 + time.Duration(rand.Intn(4))*time.Second)
-			next := fs.forumURL.JoinPath(fmt.Sprintf("page-%d", pageNum))
-			fs.Collector.Visit(next.String())
-		}
-
-		for _, thread := range fs.Threads {
-			threadId, err := db.InsertOrUpdateThread(siteId, forumId, thread.Thread)
-			if err != nil {
-				panic(err)
-			}
-			ts := NewThreadScraper(threadId, thread)
-			ts.LoadCommentsSince(db, cutoff)
-
-			comments := make([]model.Comment, len(ts.Comments), len(ts.Comments))
-			for i := range ts.Comments {
-				comments[i] = ts.Comments[i].Comment
-			}
-			db.AddComments(siteId, threadId, comments)
-		}
-	}
-
-	if subthreads && len(fs.SubForums) > 0 {
-		for _, subForumURL := range fs.SubForums {
-			sfs := NewForumScraper(subForumURL)
-			sfs.LoadThreadsWithActivitySince(db, cutoff, subthreads)
-		}
-	}
+// Scrapes the forum page at u, returning the first error fetching it.
+func (fs *ForumScraper) Visit(u string) error {
+	fs.errs.visit(fs.Collector, u)
+	return fs.errs.err
+}
 
-	db.SetForumLastScraped(forumId, time.Now())
+// Scrapes the forum as a crawl whose progress is kept in the database, so an
+// interrupted scrape can be resumed with RunCrawl.
+func (fs *ForumScraper) LoadThreadsWithActivitySince(db *database.ScraperDB, cutoff time.Time, subthreads bool) error {
+	return StartCrawl(db, fs.forumURL, cutoff, subthreads)
 }
 
+// Parses counts as XenForo displays them, e.g. "17", "1,234", "2K" or "5.6K".
 func parseCompactCount(c string) (res uint) {
+	c = strings.ReplaceAll(strings.TrimSpace(c), ",", "")
 	if len(c) > 0 {
+		multiplier := 1.0
 		switch c[len(c)-1] {
 		case 'K':
-			c = c[:len(c)-1] + "000"
+			c, multiplier = c[:len(c)-1], 1e3
 		case 'M':
-			c = c[:len(c)-1] + "000000"
+			c, multiplier = c[:len(c)-1], 1e6
 		}
-		if val, err := strconv.ParseUint(c, 10, 32); err == nil {
-			res = uint(val)
+		if val, err := strconv.ParseFloat(c, 64); err == nil && val >= 0 {
+			res = uint(math.Round(val * multiplier))
 		}
 	}
 	return
@@ -0,0 +1,138 @@
+package xf_scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/session"
+)
+
+// How long a session found to be signed in is trusted before the site is
+// asked again.
+const sessionCheckInterval = 15 * time.Minute
+
+var (
+	sessionMutex   sync.Mutex
+	sessionChecked = make(map[string]time.Time)
+)
+
+// Restores the session stored for u's site and, if a login is configured for
+// the site, signs in again when the session has expired or there is none.
+func ensureSession(db *database.ScraperDB, u *url.URL) (err error) {
+	if err = session.Restore(db, u); err != nil {
+		return
+	}
+	hostname := u.Hostname()
+	credentials, found := session.CredentialsFor(hostname)
+	if !found {
+		return
+	}
+
+	sessionMutex.Lock()
+	defer sessionMutex.Unlock()
+
+	if time.Since(sessionChecked[hostname]) < sessionCheckInterval {
+		return
+	}
+
+	client := newHTTPClient()
+	root := siteRoot(u)
+	var loggedIn bool
+	if loggedIn, err = isLoggedIn(client, root); err == nil && !loggedIn {
+		fmt.Printf("Signing in to %s as %s\n", root, credentials.Username)
+		if err = login(client, root, credentials); err == nil {
+			err = session.Save(db, hostname)
+		}
+	}
+	if err == nil {
+		sessionChecked[hostname] = time.Now()
+	}
+	return
+}
+
+// Returns the URL XenForo is installed at, which is the part of u before its
+// forums or threads.
+func siteRoot(u *url.URL) *url.URL {
+	path := "/"
+	for _, part := range []string{"/forums/", "/threads/"} {
+		if i := strings.Index(u.Path, part); i >= 0 {
+			path = u.Path[:i+1]
+			break
+		}
+	}
+	return &url.URL{Scheme: u.Scheme, Host: u.Host, Path: path}
+}
+
+func getDocument(client *http.Client, req *http.Request) (doc *goquery.Document, err error) {
+	var resp *http.Response
+	if resp, err = client.Do(req); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	// Pages with errors are still parsed, since they say what went wrong
+	if doc, err = goquery.NewDocumentFromReader(resp.Body); err == nil && resp.StatusCode != http.StatusOK {
+		err = fmt.Errorf("%s %s: %s", req.Method, req.URL, resp.Status)
+	}
+	return
+}
+
+// XenForo marks every page with whether it was shown to a member.
+func loggedInPage(doc *goquery.Document) bool {
+	return doc.Find("html").AttrOr("data-logged-in", "") == "true"
+}
+
+func isLoggedIn(client *http.Client, root *url.URL) (loggedIn bool, err error) {
+	var req *http.Request
+	var doc *goquery.Document
+	if req, err = http.NewRequest(http.MethodGet, root.String(), nil); err == nil {
+		if doc, err = getDocument(client, req); err == nil {
+			loggedIn = loggedInPage(doc)
+		}
+	}
+	return
+}
+
+// Submits XenForo's login form, with the CSRF token from the form page.
+func login(client *http.Client, root *url.URL, credentials session.Credentials) (err error) {
+	var req *http.Request
+	var doc *goquery.Document
+	if req, err = http.NewRequest(http.MethodGet, root.JoinPath("login/").String(), nil); err != nil {
+		return
+	}
+	if doc, err = getDocument(client, req); err != nil {
+		return
+	}
+	token := doc.Find(`form input[name="_xfToken"]`).AttrOr("value", "")
+	if token == "" {
+		return fmt.Errorf("No login form found at %s", req.URL)
+	}
+
+	form := url.Values{
+		"login":       {credentials.Username},
+		"password":    {credentials.Password},
+		"remember":    {"1"},
+		"_xfToken":    {token},
+		"_xfRedirect": {root.String()},
+	}
+	if req, err = http.NewRequest(http.MethodPost, root.JoinPath("login/login").String(), strings.NewReader(form.Encode())); err != nil {
+		return
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	if doc, err = getDocument(client, req); doc == nil {
+		return
+	} else if loggedInPage(doc) {
+		return nil
+	}
+	reason := strings.TrimSpace(doc.Find(".blockMessage--error").First().Text())
+	if reason == "" {
+		reason = "still signed out"
+	}
+	return fmt.Errorf("Signing in to %s as %s failed: %s", root, credentials.Username, reason)
+}
@@ -0,0 +1,117 @@
+package xf_scraper
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/scheduler"
+	"github.com/zvonler/espy/session"
+)
+
+// A XenForo site installed under /community/ that only accepts logins
+// carrying the token from its login form.
+type xfStub struct {
+	*httptest.Server
+	session string
+	logins  int
+}
+
+func newXFStub(t *testing.T) *xfStub {
+	stub := &xfStub{session: "s1"}
+	page := func(w http.ResponseWriter, loggedIn bool, body string) {
+		fmt.Fprintf(w, `<html data-logged-in="%t"><body>%s</body></html>`, loggedIn, body)
+	}
+	stub.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		cookie, _ := r.Cookie("xf_user")
+		loggedIn := cookie != nil && cookie.Value == stub.session
+		switch r.URL.Path {
+		case "/community/":
+			page(w, loggedIn, "")
+		case "/community/login/":
+			http.SetCookie(w, &http.Cookie{Name: "xf_csrf", Value: "c1", Path: "/"})
+			page(w, false, `<form action="/community/login/login" method="post">
+				<input type="hidden" name="_xfToken" value="1700000000,token" /></form>`)
+		case "/community/login/login":
+			csrf, _ := r.Cookie("xf_csrf")
+			if csrf == nil || r.PostFormValue("_xfToken") != "1700000000,token" {
+				http.Error(w, "Security error", http.StatusBadRequest)
+			} else if r.PostFormValue("login") != "alice" || r.PostFormValue("password") != "secret" {
+				w.WriteHeader(http.StatusBadRequest)
+				page(w, false, `<div class="blockMessage blockMessage--error">Incorrect password.</div>`)
+			} else {
+				stub.logins++
+				http.SetCookie(w, &http.Cookie{Name: "xf_user", Value: stub.session, Path: "/", MaxAge: 86400})
+				http.Redirect(w, r, r.PostFormValue("_xfRedirect"), http.StatusSeeOther)
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(stub.Close)
+
+	scheduler.SetDefaultLimits(scheduler.Limits{})
+	t.Cleanup(func() { scheduler.SetDefaultLimits(scheduler.DefaultLimits) })
+	return stub
+}
+
+func TestEnsureSession(t *testing.T) {
+	stub := newXFStub(t)
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL, _ := url.Parse(stub.URL + "/community/forums/members.3/")
+	require.Equal(t, stub.URL+"/community/", siteRoot(forumURL).String())
+	hostname := forumURL.Hostname()
+	newRun := func() {
+		session.Forget(forumURL)
+		delete(sessionChecked, hostname)
+	}
+	t.Cleanup(newRun)
+	if previous, found := session.CredentialsFor(hostname); found {
+		t.Cleanup(func() { session.SetCredentials(hostname, previous) })
+	} else {
+		t.Cleanup(func() { session.ForgetCredentials(hostname) })
+	}
+
+	// Without a login the site is scraped anonymously
+	require.Nil(t, ensureSession(db, forumURL))
+	require.Equal(t, 0, stub.logins)
+
+	session.SetCredentials(hostname, session.Credentials{Username: "alice", Password: "secret"})
+	require.Nil(t, ensureSession(db, forumURL))
+	require.Equal(t, 1, stub.logins)
+	loggedIn, err := isLoggedIn(newHTTPClient(), siteRoot(forumURL))
+	require.Nil(t, err)
+	require.True(t, loggedIn)
+
+	// A later run uses the stored session
+	newRun()
+	require.Nil(t, ensureSession(db, forumURL))
+	require.Equal(t, 1, stub.logins)
+
+	// and signs in again once it has expired
+	stub.session = "s2"
+	newRun()
+	require.Nil(t, ensureSession(db, forumURL))
+	require.Equal(t, 2, stub.logins)
+	cookies, _, err := db.GetSessionCookies(hostname)
+	require.Nil(t, err)
+	stored := make(map[string]string)
+	for _, c := range cookies {
+		stored[c.Name] = c.Value
+	}
+	require.Equal(t, map[string]string{"xf_csrf": "c1", "xf_user": "s2"}, stored)
+
+	stub.session = "s3"
+	newRun()
+	session.SetCredentials(hostname, session.Credentials{Username: "alice", Password: "wrong"})
+	err = ensureSession(db, forumURL)
+	require.NotNil(t, err)
+	require.Contains(t, err.Error(), "Incorrect password.")
+}
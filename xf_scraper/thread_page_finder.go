@@ -3,7 +3,6 @@ package xf_scraper
 import (
 	"fmt"
 	"log"
-	"math/rand"
 	"strconv"
 	"time"
 
@@ -13,6 +12,7 @@ import (
 type ThreadPageFinder struct {
 	thread          XFThread
 	pageNumFinder   *colly.Collector
+	errs            visitErrors
 	earliestScraped time.Time
 	latestScraped   time.Time
 }
@@ -45,6 +45,7 @@ func NewThreadPageFinder(t XFThread) *ThreadPageFinder {
 
 	tpf.pageNumFinder.OnError(func(r *colly.Response, err error) {
 		fmt.Printf("ThreadPageFinder got %v with body %s\n", err, r.Body)
+		tpf.errs.onError(r, err)
 	})
 
 	return tpf
@@ -54,7 +55,7 @@ func NewThreadPageFinder(t XFThread) *ThreadPageFinder {
 func (tpf *ThreadPageFinder) FindCommentsBefore(target time.Time, pages uint) uint {
 	if pages == 1 {
 		tpf.earliestScraped, tpf.latestScraped = time.Time{}, time.Time{}
-		tpf.pageNumFinder.Visit(tpf.thread.pageURL(1).String())
+		tpf.errs.visit(tpf.pageNumFinder, tpf.thread.pageURL(1).String())
 		if tpf.earliestScraped.Before(target) {
 			return 1
 		}
@@ -63,10 +64,10 @@ func (tpf *ThreadPageFinder) FindCommentsBefore(target time.Time, pages uint) ui
 
 	// Binary search between the endpoints
 	left, right := uint(1), pages
-	for left < right {
+	for left < right && tpf.errs.err == nil {
 		mid := left + (right-left)/2
 		tpf.earliestScraped, tpf.latestScraped = time.Time{}, time.Time{}
-		tpf.pageNumFinder.Visit(tpf.thread.pageURL(mid).String())
+		tpf.errs.visit(tpf.pageNumFinder, tpf.thread.pageURL(mid).String())
 		if tpf.earliestScraped.Before(target) && tpf.latestScraped.After(target) {
 			return mid
 		}
@@ -75,7 +76,6 @@ func (tpf *ThreadPageFinder) FindCommentsBefore(target time.Time, pages uint) ui
 		} else {
 			right = mid - 1
 		}
-		time.Sleep(1 + time.Duration(rand.Intn(6))*time.Second)
 	}
 	return left
 }
@@ -2,19 +2,12 @@ package xf_scraper
 
 import (
 	"fmt"
-	"log"
-	"math/rand"
-	"net/url"
-	"regexp"
 	"strconv"
-	"strings"
 	"time"
 
-	"github.com/PuerkitoBio/goquery"
 	"github.com/gocolly/colly"
 	"github.com/zvonler/espy/database"
 	"github.com/zvonler/espy/model"
-	"golang.org/x/net/html"
 )
 
 type ThreadScraper struct {
@@ -24,8 +17,13 @@ type ThreadScraper struct {
 	PageCount       uint
 	CommentScraper  *colly.Collector
 	PageNumScraper  *colly.Collector
+	errs            visitErrors
 	earliestScraped time.Time
 	latestScraped   time.Time
+
+	// Time range of the comments on each page CommentScraper fetched.
+	pageRanges [][2]time.Time
+	pageStart  int
 }
 
 func NewThreadScraper(threadId model.ThreadID, thread XFThread) *ThreadScraper {
@@ -67,89 +65,44 @@ func NewThreadScraper(threadId model.ThreadID, thread XFThread) *ThreadScraper {
 
 	ts.PageNumScraper.OnError(func(r *colly.Response, err error) {
 		fmt.Printf("PageNumScraper got error %v for url %s\n", err, r.Request.URL.String())
+		ts.errs.onError(r, err)
 	})
 
 	ts.CommentScraper = newCollectorWithCFRoundtripper()
 	ts.CommentScraper.OnHTML("article.message--post", func(e *colly.HTMLElement) {
-		temp := XFComment{}
-		temp.Author = e.Attr("data-author")
-		e.ForEach("article.message-body", func(_ int, e *colly.HTMLElement) {
-			// These get just the content of the blockquote
-			// temp.Content = e.DOM.ChildrenFiltered(".bbCodeBlock--quote").Text()
-			// temp.Content = e.ChildText(".bbCodeBlock--quote")
-
-			// These approaches return the full text including blockquotes
-			// temp.Content = e.DOM.ChildrenFiltered("*").Text()
-			// temp.Content = e.DOM.ChildrenFiltered("*:not(.bbCodeBlock--quote)").Text()
-			// temp.Content = e.DOM.Not("div").Text()
-			// temp.Content = e.DOM.Not(".bbCodeBlock--quote").Text()
-			// temp.Content = e.Text
-
-			// These double up the text including the blockquote contents
-			// temp.Content = e.ChildText("*:not(.bbCodeBlock--quote)")
-			// temp.Content = e.ChildText("*")
-
-			// This returns no text
-			// temp.Content = e.DOM.Not("*").Text()
-
-			// Approaches above don't work so filter blockquotes manually
-			outer, _ := goquery.OuterHtml(e.DOM)
-			doc, _ := html.Parse(strings.NewReader(outer))
-			var collectText func(*html.Node)
-			collectText = func(n *html.Node) {
-				if n.Type == html.ElementNode && n.Data == "blockquote" {
-					return
-				}
-				if n.Type == html.TextNode {
-					temp.Content += n.Data
-				}
-				for c := n.FirstChild; c != nil; c = c.NextSibling {
-					collectText(c)
-				}
+		temp := extractComment(e, ts.thread.URL)
+		if !temp.Published.IsZero() {
+			if temp.Published.After(ts.latestScraped) {
+				ts.latestScraped = temp.Published
 			}
-			collectText(doc)
-		})
-
-		// Replace non-breaking spaces with regular spaces
-		temp.Content = strings.ReplaceAll(temp.Content, "\u00a0", " ")
-
-		// Remove whitespace-only lines
-		wsLinePat := regexp.MustCompile("\n[ \t]+\n")
-		temp.Content = string(wsLinePat.ReplaceAll([]byte(temp.Content), []byte("\n")))
-
-		// Replace repeated newlines with singles
-		nlPat := regexp.MustCompile("\n\n+")
-		temp.Content = string(nlPat.ReplaceAll([]byte(temp.Content), []byte("\n")))
+			if ts.earliestScraped.IsZero() || temp.Published.Before(ts.earliestScraped) {
+				ts.earliestScraped = temp.Published
+			}
+		}
+		ts.Comments = append(ts.Comments, temp)
+	})
 
-		// Trim leading and trailing newlines
-		temp.Content = strings.TrimLeft(temp.Content, "\n")
-		temp.Content = strings.TrimRight(temp.Content, "\n")
+	ts.CommentScraper.OnScraped(func(r *colly.Response) {
+		page := ts.Comments[ts.pageStart:]
+		ts.pageStart = len(ts.Comments)
 
-		getUrl := func(_ int, e *colly.HTMLElement) {
-			commentHref, err := url.Parse(e.ChildAttr("a", "href"))
-			if err != nil {
-				panic(err)
+		// A comment whose time couldn't be parsed would stretch the range
+		// back to the zero time and mark every older comment removed
+		var pageRange [2]time.Time
+		for _, c := range page {
+			if c.Published.IsZero() {
+				continue
 			}
-			temp.URL = e.Request.URL.ResolveReference(commentHref)
-			dataTime := e.ChildAttr("time.u-dt", "data-time")
-			if tm, err := strconv.Atoi(dataTime); err != nil {
-				log.Printf("Unparseable data-time '%v' for %s", dataTime, temp.Author)
-			} else {
-				temp.Published = time.Unix(int64(tm), 0)
-				if temp.Published.After(ts.latestScraped) {
-					ts.latestScraped = temp.Published
-				}
-				if ts.earliestScraped.IsZero() || temp.Published.Before(ts.earliestScraped) {
-					ts.earliestScraped = temp.Published
-				}
+			if pageRange[0].IsZero() || c.Published.Before(pageRange[0]) {
+				pageRange[0] = c.Published
+			}
+			if c.Published.After(pageRange[1]) {
+				pageRange[1] = c.Published
 			}
 		}
-		e.ForEach("ul.message-attribution-main", getUrl)
-		if temp.URL == nil {
-			e.ForEach("div.message-attribution-main", getUrl)
+		if !pageRange[0].IsZero() {
+			ts.pageRanges = append(ts.pageRanges, pageRange)
 		}
-
-		ts.Comments = append(ts.Comments, temp)
 	})
 
 	ts.CommentScraper.OnRequest(func(r *colly.Request) {
@@ -158,12 +111,39 @@ func NewThreadScraper(threadId model.ThreadID, thread XFThread) *ThreadScraper {
 
 	ts.CommentScraper.OnError(func(r *colly.Response, err error) {
 		fmt.Printf("CommentScraper (%d) got %v with body %s\n", ts.threadId, err, r.Body)
+		ts.errs.onError(r, err)
 	})
 
 	return ts
 }
 
-func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.Time) {
+// Returns the scraped comments as model comments.
+func (ts *ThreadScraper) comments() []model.Comment {
+	comments := make([]model.Comment, len(ts.Comments), len(ts.Comments))
+	for i := range ts.Comments {
+		comments[i] = ts.Comments[i].Comment
+	}
+	return comments
+}
+
+// Stores the scraped comments, then marks as removed any stored comment that
+// falls within a scraped page's time range but was not on the page.
+func (ts *ThreadScraper) StoreComments(db *database.ScraperDB) (err error) {
+	started := time.Now()
+	if err = db.AddComments(ts.thread.SiteId, ts.threadId, ts.comments()); err == nil {
+		for _, pageRange := range ts.pageRanges {
+			if removed := db.MarkRemovedComments(ts.threadId, pageRange[0], pageRange[1], started); removed > 0 {
+				fmt.Printf("CommentScraper (%d) found %d removed comments\n", ts.threadId, removed)
+			}
+		}
+	}
+	return
+}
+
+// Scrapes the thread's comments since cutoff, skipping those already in the
+// database. Returns the first error fetching a page; the comments scraped
+// before it are kept.
+func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.Time) error {
 	if timeRange := db.CommentTimeRange(ts.threadId); timeRange != nil {
 		// If the database already has some comments for this thread, avoid
 		// re-loading them.
@@ -171,14 +151,12 @@ func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.T
 
 		if ts.thread.Latest != latest {
 			// Loading the first page of the thread gets us the last page number
-			ts.PageNumScraper.Visit(ts.thread.URL.String())
-			time.Sleep(1 + time.Duration(rand.Intn(2))*time.Second)
+			ts.errs.visit(ts.PageNumScraper, ts.thread.URL.String())
 
 			// Load from last page until earlier than the latest already loaded
-			for pageNum := ts.PageCount; pageNum >= 1; pageNum-- {
-				time.Sleep(1 + time.Duration(rand.Intn(4))*time.Second)
+			for pageNum := ts.PageCount; pageNum >= 1 && ts.errs.err == nil; pageNum-- {
 				next := ts.thread.pageURL(pageNum)
-				ts.CommentScraper.Visit(next.String())
+				ts.errs.visit(ts.CommentScraper, next.String())
 				if ts.earliestScraped.Before(latest) {
 					break
 				}
@@ -191,15 +169,17 @@ func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.T
 			if !db.FirstCommentLoaded(ts.threadId) {
 
 				// Loading the first page of the thread gets us the last page number
-				ts.PageNumScraper.Visit(ts.thread.URL.String())
-				time.Sleep(1 + time.Duration(rand.Intn(2))*time.Second)
+				ts.errs.visit(ts.PageNumScraper, ts.thread.URL.String())
 
 				// Binary search to page containing posts older than earliest then load if before cutoff
 				tpf := NewThreadPageFinder(ts.thread)
-				for pageNum := tpf.FindCommentsBefore(earliest, ts.PageCount); pageNum >= 1; pageNum-- {
-					time.Sleep(1 + time.Duration(rand.Intn(4))*time.Second)
+				firstPage := tpf.FindCommentsBefore(earliest, ts.PageCount)
+				if ts.errs.err == nil {
+					ts.errs.err = tpf.errs.err
+				}
+				for pageNum := firstPage; pageNum >= 1 && ts.errs.err == nil; pageNum-- {
 					next := ts.thread.pageURL(pageNum)
-					ts.CommentScraper.Visit(next.String())
+					ts.errs.visit(ts.CommentScraper, next.String())
 					if ts.earliestScraped.Before(cutoff) {
 						break
 					}
@@ -208,16 +188,16 @@ func (ts *ThreadScraper) LoadCommentsSince(db *database.ScraperDB, cutoff time.T
 		}
 	} else {
 		// Loading the first page of the thread gets us the last page number
-		ts.PageNumScraper.Visit(ts.thread.URL.String())
+		ts.errs.visit(ts.PageNumScraper, ts.thread.URL.String())
 
 		// Load from last page until earlier than cutoff or out of comments
-		for pageNum := ts.PageCount; pageNum >= 1; pageNum-- {
-			time.Sleep(1 + time.Duration(rand.Intn(4))*time.Second)
+		for pageNum := ts.PageCount; pageNum >= 1 && ts.errs.err == nil; pageNum-- {
 			next := ts.thread.pageURL(pageNum)
-			ts.CommentScraper.Visit(next.String())
+			ts.errs.visit(ts.CommentScraper, next.String())
 			if ts.earliestScraped.Before(cutoff) {
 				break
 			}
 		}
 	}
+	return ts.errs.err
 }
@@ -1,6 +1,7 @@
 package xf_scraper
 
 import (
+	"errors"
 	"fmt"
 	"log"
 	"net"
@@ -11,6 +12,9 @@ import (
 	"github.com/caffix/cloudflare-roundtripper/cfrt"
 	"github.com/gocolly/colly"
 	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/replay"
+	"github.com/zvonler/espy/scheduler"
+	"github.com/zvonler/espy/session"
 )
 
 /*---------------------------------------------------------------------------*/
@@ -34,13 +38,53 @@ type XFComment struct {
 
 /*---------------------------------------------------------------------------*/
 
-func newCollectorWithCFRoundtripper() *colly.Collector {
-	collector := colly.NewCollector()
+// Keeps the first error from a scraper's visits. Colly reports failed
+// responses, such as a 403 or a timeout, to OnError callbacks rather than
+// stopping the scrape, so a scraper records them here and returns the error
+// once it's done.
+type visitErrors struct {
+	err error
+}
+
+func (v *visitErrors) record(u string, err error) {
+	if err != nil && v.err == nil && !errors.Is(err, colly.ErrAlreadyVisited) {
+		v.err = fmt.Errorf("Fetching %s: %w", u, err)
+	}
+}
 
-	collector = colly.NewCollector(
+// Visits u with c, recording the error if the visit fails.
+func (v *visitErrors) visit(c *colly.Collector, u string) {
+	v.record(u, c.Visit(u))
+}
+
+// For use in OnError callbacks.
+func (v *visitErrors) onError(r *colly.Response, err error) {
+	v.record(r.Request.URL.String(), err)
+}
+
+/*---------------------------------------------------------------------------*/
+
+func newCollectorWithCFRoundtripper() *colly.Collector {
+	collector := colly.NewCollector(
 		colly.IgnoreRobotsTxt(),
 		colly.UserAgent("Mozilla"),
 	)
+	collector.WithTransport(newTransport())
+	collector.SetCookieJar(session.Jar())
+	return collector
+}
+
+// Returns a client for requests made outside of a collector, such as signing
+// in, that shares the collectors' transport and cookies.
+func newHTTPClient() *http.Client {
+	return &http.Client{
+		Transport: newTransport(),
+		Jar:       session.Jar(),
+		Timeout:   30 * time.Second,
+	}
+}
+
+func newTransport() http.RoundTripper {
 	transport, err :=
 		cfrt.New(&http.Transport{
 			DialContext: (&net.Dialer{
@@ -56,10 +100,5 @@ func newCollectorWithCFRoundtripper() *colly.Collector {
 	if err != nil {
 		log.Fatal(err)
 	}
-	collector.WithTransport(transport)
-	collector.Limit(&colly.LimitRule{
-		Parallelism: 1,
-		RandomDelay: 10 * time.Second,
-	})
-	return collector
+	return session.Transport(replay.Wrap(scheduler.Transport(transport)))
 }
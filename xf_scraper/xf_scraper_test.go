@@ -0,0 +1,326 @@
+package xf_scraper
+
+import (
+	"bufio"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/zvonler/espy/adapter"
+	"github.com/zvonler/espy/database"
+	"github.com/zvonler/espy/model"
+	"github.com/zvonler/espy/replay"
+)
+
+// Pages under testdata/replay were saved with --record; new ones can be added
+// by scraping with --record and copying the files in.
+func useCorpus(t *testing.T) {
+	replay.Replay("testdata/replay")
+	t.Cleanup(replay.Disable)
+}
+
+func mustParse(t *testing.T, s string) *url.URL {
+	u, err := url.Parse(s)
+	require.Nil(t, err)
+	return u
+}
+
+func TestForumScraper(t *testing.T) {
+	useCorpus(t)
+
+	forumURL := mustParse(t, "https://forum.example.com/forums/vehicles.2/")
+	fs := NewForumScraper(forumURL)
+	fs.Collector.Visit(forumURL.String())
+
+	require.Equal(t, 1, len(fs.SubForums))
+	require.Equal(t, "https://forum.example.com/forums/electric.5/", fs.SubForums[0].String())
+
+	require.Equal(t, 2, len(fs.Threads))
+	thread := fs.Threads[0]
+	require.Equal(t, "Battery warranty experiences", thread.Title)
+	require.Equal(t, "https://forum.example.com/threads/battery-warranty.10/", thread.URL.String())
+	require.Equal(t, "alice", thread.Author)
+	require.Equal(t, "1", thread.AuthorExternalId)
+	require.Equal(t, time.Unix(1696161600, 0), thread.StartDate)
+	require.Equal(t, time.Unix(1696235400, 0), thread.Latest)
+	require.Equal(t, uint(21), thread.Replies)
+	require.Equal(t, uint(1000), thread.Views)
+	require.Equal(t, "carol", fs.Threads[1].Author)
+	require.Equal(t, "3", fs.Threads[1].AuthorExternalId)
+}
+
+func TestThreadScraper(t *testing.T) {
+	useCorpus(t)
+
+	threadURL := mustParse(t, "https://forum.example.com/threads/battery-warranty.10/")
+	thread := XFThread{model.Thread{URL: threadURL}}
+	ts := NewThreadScraper(0, thread)
+
+	ts.PageNumScraper.Visit(threadURL.String())
+	require.Equal(t, uint(2), ts.PageCount)
+
+	ts.CommentScraper.Visit(thread.pageURL(1).String())
+	require.Equal(t, 2, len(ts.Comments))
+
+	first := ts.Comments[0]
+	require.Equal(t, "alice", first.Author)
+	require.Equal(t, "https://forum.example.com/threads/battery-warranty.10/post-101", first.URL.String())
+	require.Equal(t, time.Unix(1696161600, 0), first.Published)
+	// Indentation around the post body is kept
+	require.Equal(t, "Has anyone had a battery pack replaced under warranty?\nMine failed at 70k miles.", strings.TrimSpace(first.Content))
+
+	// Quoted text is excluded from the reply
+	require.Equal(t, "Same here, it took three weeks.", strings.TrimSpace(ts.Comments[1].Content))
+	require.Empty(t, first.Quotes)
+	require.Equal(t, &model.AuthorProfile{
+		MemberId:      "1",
+		Username:      "alice",
+		Title:         "Well-known member",
+		Joined:        time.Date(2020, 1, 5, 0, 0, 0, 0, time.UTC),
+		Messages:      1234,
+		ReactionScore: 5600,
+		Location:      "Denver",
+	}, first.AuthorProfile)
+	require.Equal(t, "1", first.AuthorExternalId)
+	require.Equal(t, uint(5), *first.ReactionCount)
+	require.Equal(t, []model.Reaction{{Kind: "Like"}, {Kind: "Love"}}, first.Reactions)
+	require.Equal(t, uint(0), *ts.Comments[1].ReactionCount)
+	require.Nil(t, first.Score)
+	require.Equal(t, "2", ts.Comments[1].AuthorProfile.MemberId)
+	require.Equal(t, 1, len(ts.Comments[1].Quotes))
+	quote := ts.Comments[1].Quotes[0]
+	require.Equal(t, "alice", quote.Author)
+	require.Equal(t, "101", quote.PostId)
+	require.Equal(t, "1", quote.MemberId)
+	require.Equal(t, first.URL.String(), quote.URL.String())
+
+	ts.CommentScraper.Visit(thread.pageURL(2).String())
+	require.Equal(t, 3, len(ts.Comments))
+
+	// Links and media come from the post and its attachments, not from
+	// mentions, smilies or quoted posts
+	third := ts.Comments[2]
+	require.Equal(t, 1, len(third.Links))
+	require.Equal(t, "https://www.example.org/warranty", third.Links[0].URL.String())
+	require.Equal(t, "warranty terms", third.Links[0].Text)
+	require.Equal(t, []model.Media{
+		{Kind: model.Image, URL: mustParse(t, "https://forum.example.com/data/attachments/pack.jpg"), Text: "New pack"},
+		{Kind: model.Embed, URL: mustParse(t, "https://www.youtube.com/embed/abc123"), Text: "youtube"},
+		{Kind: model.Attachment, URL: mustParse(t, "https://forum.example.com/attachments/invoice-pdf.55/"), Text: "invoice.pdf"},
+	}, third.Media)
+	require.Equal(t, time.Unix(1696161600, 0), ts.earliestScraped)
+	require.Equal(t, time.Unix(1696235400, 0), ts.latestScraped)
+}
+
+func TestParseCompactCount(t *testing.T) {
+	require.Equal(t, uint(0), parseCompactCount(""))
+	require.Equal(t, uint(17), parseCompactCount("17"))
+	require.Equal(t, uint(2000), parseCompactCount("2K"))
+	require.Equal(t, uint(3000000), parseCompactCount("3M"))
+	require.Equal(t, uint(1234), parseCompactCount("1,234"))
+	require.Equal(t, uint(5600), parseCompactCount("5.6K"))
+	require.Equal(t, uint(0), parseCompactCount("many"))
+}
+
+func TestStoreCommentsMarksRemoved(t *testing.T) {
+	useCorpus(t)
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL := mustParse(t, "https://forum.example.com/forums/vehicles.2/")
+	threadURL := mustParse(t, "https://forum.example.com/threads/battery-warranty.10/")
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+	thread := model.Thread{URL: threadURL, Author: "alice", SiteId: siteId}
+	thread.Id, err = db.InsertOrUpdateThread(siteId, forumId, thread)
+	require.Nil(t, err)
+
+	// A post between the two on the first page that has since been deleted,
+	// and one after the last page that was not re-fetched
+	deleted := model.Comment{URL: mustParse(t, threadURL.String()+"post-150"), Author: "dave",
+		Published: time.Unix(1696170000, 0), Content: "Spam"}
+	later := model.Comment{URL: mustParse(t, threadURL.String()+"post-300"), Author: "erin",
+		Published: time.Unix(1696300000, 0), Content: "Later"}
+	require.Nil(t, db.AddComments(siteId, thread.Id, []model.Comment{deleted, later}))
+
+	// Last-seen times have one-second resolution
+	time.Sleep(1100 * time.Millisecond)
+
+	ts := NewThreadScraper(thread.Id, XFThread{thread})
+	ts.CommentScraper.Visit(XFThread{thread}.pageURL(1).String())
+	ts.CommentScraper.Visit(XFThread{thread}.pageURL(2).String())
+	require.Equal(t, 2, len(ts.pageRanges))
+	require.Nil(t, ts.StoreComments(db))
+
+	removed, err := db.RemovedComments(thread.Id, time.Time{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(removed))
+	require.Equal(t, deleted.URL.String(), removed[0].URL.String())
+
+	// A removed post that reappears is no longer reported
+	require.Nil(t, db.AddComments(siteId, thread.Id, []model.Comment{deleted}))
+	removed, err = db.RemovedComments(thread.Id, time.Time{})
+	require.Nil(t, err)
+	require.Empty(t, removed)
+}
+
+func TestUnparseableTimeMarksNothingRemoved(t *testing.T) {
+	threadURL := mustParse(t, "https://forum.example.com/threads/battery-warranty.10/")
+	thread := model.Thread{URL: threadURL, Author: "alice"}
+	pageURL := XFThread{thread}.pageURL(2)
+
+	// The corpus page with a second post whose time can't be parsed
+	dump, err := os.ReadFile(replay.Path("testdata/replay", http.MethodGet, pageURL))
+	require.Nil(t, err)
+	resp, err := http.ReadResponse(bufio.NewReader(bytes.NewReader(dump)), nil)
+	require.Nil(t, err)
+	body, err := io.ReadAll(resp.Body)
+	require.Nil(t, err)
+	page := string(body)
+	start := strings.Index(page, `<article class="message message--post`)
+	end := strings.Index(page, "\n</article>") + len("\n</article>")
+	post := strings.ReplaceAll(page[start:end], "post-103", "post-104")
+	post = strings.Replace(post, `data-time="1696235400"`, `data-time="soon"`, 1)
+	page = page[:end] + "\n" + post + page[end:]
+	resp.Body = io.NopCloser(strings.NewReader(page))
+	resp.ContentLength = int64(len(page))
+	resp.Header.Del("Content-Length")
+	dump, err = httputil.DumpResponse(resp, true)
+	require.Nil(t, err)
+	dir := t.TempDir()
+	path := replay.Path(dir, http.MethodGet, pageURL)
+	require.Nil(t, os.MkdirAll(filepath.Dir(path), 0755))
+	require.Nil(t, os.WriteFile(path, dump, 0644))
+	replay.Replay(dir)
+	t.Cleanup(replay.Disable)
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+	siteId, forumId, err := db.InsertOrUpdateForum(mustParse(t, "https://forum.example.com/forums/vehicles.2/"))
+	require.Nil(t, err)
+	thread.SiteId = siteId
+	thread.Id, err = db.InsertOrUpdateThread(siteId, forumId, thread)
+	require.Nil(t, err)
+
+	// A post from an earlier page that wasn't fetched this time
+	earlier := model.Comment{URL: mustParse(t, threadURL.String()+"post-101"), Author: "alice",
+		Published: time.Unix(1696100000, 0), Content: "Earlier"}
+	require.Nil(t, db.AddComments(siteId, thread.Id, []model.Comment{earlier}))
+	time.Sleep(1100 * time.Millisecond)
+
+	ts := NewThreadScraper(thread.Id, XFThread{thread})
+	require.Nil(t, ts.CommentScraper.Visit(pageURL.String()))
+	require.Equal(t, 2, len(ts.Comments))
+	require.True(t, ts.Comments[1].Published.IsZero())
+	require.Equal(t, [][2]time.Time{{time.Unix(1696235400, 0), time.Unix(1696235400, 0)}}, ts.pageRanges)
+	require.Nil(t, ts.StoreComments(db))
+
+	removed, err := db.RemovedComments(thread.Id, time.Time{})
+	require.Nil(t, err)
+	require.Empty(t, removed)
+}
+
+func TestExtractComment(t *testing.T) {
+	useCorpus(t)
+
+	threadURL := mustParse(t, "https://forum.example.com/threads/battery-warranty.10/")
+	thread := XFThread{model.Thread{URL: threadURL}}
+	ts := NewThreadScraper(0, thread)
+	ts.CommentScraper.Visit(thread.pageURL(2).String())
+	require.Equal(t, 1, len(ts.Comments))
+
+	// Extracting from the kept HTML gives the same comment as scraping
+	scraped := ts.Comments[0].Comment
+	require.Contains(t, scraped.HTML, `data-content="post-103"`)
+	extracted, err := ExtractComment(scraped.HTML, thread.pageURL(2), threadURL)
+	require.Nil(t, err)
+	require.Equal(t, scraped, extracted)
+
+	_, err = ExtractComment("<p>Not a post</p>", thread.pageURL(2), threadURL)
+	require.NotNil(t, err)
+}
+
+func TestParseReactions(t *testing.T) {
+	threadURL := mustParse(t, "https://forum.example.com/threads/battery-warranty.10/")
+	post := `<article class="message message--post" data-author="bob" data-content="post-9">
+		<div class="reactionsBar">
+			<ul class="reactionSummary"><li><img class="reaction-sprite" alt="Like" /></li></ul>
+			<a class="reactionsBar-link">You, <bdi>alice</bdi> and 1,200 others</a>
+		</div></article>`
+	comment, err := ExtractComment(post, threadURL, threadURL)
+	require.Nil(t, err)
+	require.Equal(t, uint(1202), *comment.ReactionCount)
+	require.Equal(t, []model.Reaction{{Kind: "Like", Count: 1202}}, comment.Reactions)
+}
+
+func TestCrawlKeepsFailedTasks(t *testing.T) {
+	useCorpus(t)
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	// The cutoff leaves one thread to scrape, and the corpus has the forum
+	// page but no page at the URL the thread is fetched from
+	forumURL := mustParse(t, "https://forum.example.com/forums/vehicles.2/")
+	err = StartCrawl(db, forumURL, time.Unix(1695000000, 0), false)
+	require.ErrorContains(t, err, "1 failed tasks")
+
+	crawl, err := db.FindUnfinishedCrawl(forumURL)
+	require.Nil(t, err)
+	counts := db.CrawlTaskCounts(crawl.Id)
+	require.Equal(t, uint(1), counts[database.CrawlDone])
+	// Made pending again, so resuming retries it
+	require.Equal(t, uint(1), counts[database.CrawlPending])
+
+	// A forum page that can't be fetched fails too
+	missingURL := mustParse(t, "https://forum.example.com/forums/missing.9/")
+	require.NotNil(t, StartCrawl(db, missingURL, time.Unix(0, 0), false))
+	crawl, err = db.FindUnfinishedCrawl(missingURL)
+	require.Nil(t, err)
+	require.Equal(t, map[database.CrawlTaskState]uint{database.CrawlPending: 1}, db.CrawlTaskCounts(crawl.Id))
+}
+
+func TestStoreThreadMarksRemoved(t *testing.T) {
+	useCorpus(t)
+
+	db, err := database.OpenScraperDB(t.TempDir() + "/test.db")
+	require.Nil(t, err)
+	defer db.Close()
+
+	forumURL := mustParse(t, "https://forum.example.com/forums/vehicles.2/")
+	threadURL := mustParse(t, "https://forum.example.com/threads/battery-warranty.10/")
+	siteId, forumId, err := db.InsertOrUpdateForum(forumURL)
+	require.Nil(t, err)
+	thread := model.Thread{URL: threadURL, Author: "alice", SiteId: siteId, Latest: time.Unix(1696235400, 0)}
+	thread.Id, err = db.InsertOrUpdateThread(siteId, forumId, thread)
+	require.Nil(t, err)
+
+	// A post on the first page that has since been deleted
+	deleted := model.Comment{URL: mustParse(t, threadURL.String()+"post-150"), Author: "dave",
+		Published: time.Unix(1696170000, 0), Content: "Spam"}
+	require.Nil(t, db.AddComments(siteId, thread.Id, []model.Comment{deleted}))
+	time.Sleep(1100 * time.Millisecond)
+
+	// Rescraping just the thread notices it, as a forum crawl would
+	require.Nil(t, adapter.StoreThread(db, xenForoAdapter{}, thread, time.Unix(0, 0)))
+	removed, err := db.RemovedComments(thread.Id, time.Time{})
+	require.Nil(t, err)
+	require.Equal(t, 1, len(removed))
+	require.Equal(t, deleted.URL.String(), removed[0].URL.String())
+
+	comments, err := db.ThreadComments(thread.Id)
+	require.Nil(t, err)
+	require.Equal(t, 4, len(comments))
+}